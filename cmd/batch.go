@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rorycl/timeaway/web"
+)
+
+// batchOptions are the options for the batch subcommand, which also uses
+// the rule option as the rule of sets which do not provide one
+var batchOptions struct {
+	Workers int `short:"w" long:"workers" description:"number of sets to calculate concurrently (default: the number of cpus)" default:"0"`
+	Args    struct {
+		File string `positional-arg-name:"file" description:"file of trip sets to read, or - for stdin (the default)"`
+	} `positional-args:"yes"`
+}
+
+// openInput opens the file at path, or stdin if path is empty or "-".
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "" || path == "-" {
+		return io.NopCloser(stdin), nil
+	}
+	return os.Open(path)
+}
+
+// batch streams newline delimited json trip sets from a file or stdin,
+// writing a json result line to stdout for each set as soon as it is
+// calculated, as for the /api/v1/stream endpoint. It returns exitError if
+// the input could not be read or any set could not be calculated, or
+// else exitBreach if any set breaches its rule.
+func batch() int {
+	in, err := openInput(batchOptions.Args.File)
+	if err != nil {
+		fmt.Fprintf(stderr, "input error: %v\n", err)
+		return exitError
+	}
+	defer in.Close()
+
+	workers := batchOptions.Workers
+	if workers < 1 {
+		workers = web.BatchWorkers
+	}
+	stats, err := web.StreamBatch(context.Background(), in, stdout, workers)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	fmt.Fprintf(stderr, "%d sets: %d breached, %d not calculated\n", stats.Sets, stats.Breaches, stats.Errors)
+	switch {
+	case stats.Errors > 0:
+		return exitError
+	case stats.Breaches > 0:
+		return exitBreach
+	}
+	return exitOK
+}
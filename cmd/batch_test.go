@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "sets.ndjson")
+	err := os.WriteFile(file, []byte(`{"name":"alice","trips":[{"start":"2024-01-01","end":"2024-01-10"}]}`+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		code   int
+		output []string
+	}{
+		{
+			name: "sets",
+			args: []string{"prog", "batch", "-w", "2"},
+			stdin: `{"name":"alice","trips":[{"start":"2024-01-01","end":"2024-01-10"}]}` + "\n" +
+				`{"name":"bob","trips":[{"start":"2024-02-01","end":"2024-02-14"}]}` + "\n",
+			code: exitOK,
+			output: []string{
+				`{"line":1,"name":"alice","result":{"rule":{"name":"schengen"`,
+				`{"line":2,"name":"bob","result":{"rule":{"name":"schengen"`,
+			},
+		},
+		{
+			name:   "default rule",
+			args:   []string{"prog", "-r", "uk-tax-year", "batch"},
+			stdin:  `{"name":"alice","trips":[{"start":"2024-01-01","end":"2024-01-10"}]}`,
+			code:   exitOK,
+			output: []string{`"rule":{"name":"uk-tax-year"`},
+		},
+		{
+			name:   "breach",
+			args:   []string{"prog", "batch"},
+			stdin:  `{"name":"alice","trips":[{"start":"2024-01-01","end":"2024-04-10"}]}`,
+			code:   exitBreach,
+			output: []string{`"breach":true`},
+		},
+		{
+			name: "set error",
+			args: []string{"prog", "batch"},
+			stdin: `{"name":"alice","trips":[{"start":"2024-01-01","end":"2024-04-10"}]}` + "\n" +
+				`{"name":"bob","trips":[]}`,
+			code:   exitError,
+			output: []string{`{"line":2,"name":"bob","error":{"status":422,"code":"invalid_trips","message":"no trips were provided"}}`},
+		},
+		{
+			name:   "file",
+			args:   []string{"prog", "batch", file},
+			code:   exitOK,
+			output: []string{`{"line":1,"name":"alice"`},
+		},
+		{
+			name: "missing file",
+			args: []string{"prog", "batch", filepath.Join(dir, "missing")},
+			code: exitError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options.Rule = "schengen"
+			batchOptions.Workers = 0
+			batchOptions.Args.File = ""
+
+			var out, errOut bytes.Buffer
+			stdin = strings.NewReader(tt.stdin)
+			stdout = &out
+			stderr = &errOut
+			serve = func(address, port, baseUrl string) {
+				t.Fatal("server run for a subcommand")
+			}
+			exitCode := -1
+			exit = func(i int) {
+				if exitCode < 0 {
+					exitCode = i
+				}
+			}
+			os.Args = tt.args
+			main()
+
+			if got, want := exitCode, tt.code; got != want {
+				t.Errorf("exit code got %d want %d (%s)", got, want, errOut.String())
+			}
+			for _, want := range tt.output {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output does not contain %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
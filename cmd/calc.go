@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/rorycl/timeaway/trips"
+)
+
+// exit codes for subcommands
+const (
+	exitOK     = 0 // the trips comply with the rule
+	exitError  = 1 // the trips could not be read or calculated
+	exitBreach = 2 // the trips breach the rule
+)
+
+// calcOptions are the options for the calc subcommand, which also uses
+// the rule and merge options
+var calcOptions struct {
+	Format string `short:"f" long:"format" description:"input format" choice:"auto" choice:"json" choice:"query" choice:"lines" choice:"csv" choice:"ics" default:"auto"`
+	Output string `short:"o" long:"output" description:"output format" choice:"table" choice:"json" choice:"ics" default:"table"`
+	Args   struct {
+		File string `positional-arg-name:"file" description:"file of trips to read, or - for stdin (the default)"`
+	} `positional-args:"yes"`
+}
+
+// calculateInput reads trips from the file at path, or stdin, in the
+// provided format and calculates them with the selected rule, reporting
+// the results of every registered rule and merging overlapping trips if
+// the merge option is set.
+func calculateInput(path, format string) (*trips.Trips, error) {
+	input, err := readInput(path)
+	if err != nil {
+		return nil, fmt.Errorf("input error: %w", err)
+	}
+	holidays, err := decodeHolidays(input, format)
+	var invalid trips.ValidationErrors
+	if options.Merge && errors.As(err, &invalid) && invalid.Mergeable() {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("trip decoding error: %w", err)
+	}
+
+	rule, err := trips.RuleByName(options.Rule)
+	if err != nil {
+		return nil, fmt.Errorf("rule error: %w", err)
+	}
+	calcOpts := []trips.Option{trips.WithRule(rule), trips.WithRules(trips.Rules()...)}
+	if options.Merge {
+		calcOpts = append(calcOpts, trips.WithMergeOverlaps())
+	}
+	trs, err := trips.Calculate(holidays, calcOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("calculation error: %w", err)
+	}
+	return trs, nil
+}
+
+// calc reads trips from a file or stdin, calculates them with the
+// selected rule and writes the results to stdout as a table, json or an
+// iCalendar file, returning exitBreach if the trips breach the rule.
+func calc() int {
+	trs, err := calculateInput(calcOptions.Args.File, calcOptions.Format)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	switch calcOptions.Output {
+	case "json":
+		err = writeJSON(stdout, trs)
+	case "ics":
+		err = trips.TripsAsICS(trs, stdout)
+	default:
+		err = writeTable(stdout, trs)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "output error: %v\n", err)
+		return exitError
+	}
+	if trs.Breach {
+		return exitBreach
+	}
+	return exitOK
+}
+
+// writeJSON writes the trips as indented json, as reported by the
+// /trips endpoint.
+func writeJSON(w io.Writer, trs *trips.Trips) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(trs)
+}
+
+// writeTable writes the trips and the results of each rule as human
+// readable tables.
+func writeTable(w io.Writer, trs *trips.Trips) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "rule\t%s: %s\n", trs.Rule, trs.Description)
+	fmt.Fprintf(tw, "days away\t%d of %d\n", trs.DaysAway, trs.MaxStay)
+	fmt.Fprintf(tw, "days remaining\t%d on %s\n", trs.DaysRemaining(trs.End), trs.End.Format("02/01/2006"))
+	switch len(trs.Breaches) {
+	case 0:
+		fmt.Fprintf(tw, "breach\tno\n")
+	case 1:
+		fmt.Fprintf(tw, "breach\tyes, once\n")
+	default:
+		fmt.Fprintf(tw, "breach\tyes, %d times\n", len(trs.Breaches))
+	}
+
+	fmt.Fprintf(tw, "\ntrip\tstart\tend\tdays\tcountry\n")
+	for i, h := range trs.OriginalHolidays {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\n", i+1, h.Start.Format("02/01/2006"), h.End.Format("02/01/2006"), h.Duration, h.Country)
+	}
+	for _, h := range trs.Exemptions {
+		fmt.Fprintf(tw, "exempt\t%s\t%s\t%d\t%s\n", h.Start.Format("02/01/2006"), h.End.Format("02/01/2006"), h.Duration, h.Country)
+	}
+
+	if len(trs.Breaches) > 0 {
+		fmt.Fprintf(tw, "\nbreach\tstart\tend\tpeak days away\n")
+		for i, b := range trs.Breaches {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%d\n", i+1, b.Start.Format("02/01/2006"), b.End.Format("02/01/2006"), b.DaysAway)
+		}
+	}
+	if len(trs.Exclusions) > 0 {
+		fmt.Fprintf(tw, "\nnot counted\n")
+		for _, e := range trs.Exclusions {
+			fmt.Fprintf(tw, "%s\n", e)
+		}
+	}
+	if len(trs.Merges) > 0 {
+		fmt.Fprintf(tw, "\nmerged\n")
+		for _, m := range trs.Merges {
+			fmt.Fprintf(tw, "%s\n", m)
+		}
+	}
+
+	if len(trs.Results) > 1 {
+		fmt.Fprintf(tw, "\nrule\tlimit\tdays away\tremaining\tbreach\n")
+		for _, r := range trs.Results {
+			breach := "no"
+			if r.Breach {
+				breach = "yes"
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", r.Rule, r.Limit, r.DaysAway, r.DaysRemaining, breach)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeHolidays(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		input  string
+		format string
+		trips  int
+		isErr  bool
+	}{
+		{"json", `[{"Start":"2024-01-01","End":"2024-01-10"}]`, "auto", 1, false},
+		{"query", "Start=2024-01-01&End=2024-01-10&Start=2024-02-01&End=2024-02-02", "auto", 2, false},
+		{"url", "http://127.0.0.1:8000/?Start=2024-01-01&End=2024-01-10\n", "auto", 1, false},
+		{"lines", "# trips\n2024-01-01 2024-01-10 FR\n\n2024-02-01 2024-03-01 exempt\n", "auto", 2, false},
+		{"forced lines", "2024-01-01 2024-01-10", "lines", 1, false},
+		{"csv", "Start,End,Country\n01/01/2024,10/01/2024,FR\n", "auto", 1, false},
+		{"ics", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20240101\r\nDTEND;VALUE=DATE:20240111\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", "auto", 1, false},
+		{"bad line", "2024-01-01", "lines", 0, true},
+		{"no lines", "# nothing\n", "lines", 0, true},
+		{"bad country", "2024-01-01 2024-01-10 France", "lines", 0, true},
+		{"reversed", "2024-01-10 2024-01-01", "auto", 0, true},
+		{"bad format", "2024-01-10 2024-01-11", "xml", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hols, err := decodeHolidays([]byte(tt.input), tt.format)
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Fatalf("error got %v want error %t", err, want)
+			}
+			if err != nil {
+				return
+			}
+			if got, want := len(hols), tt.trips; got != want {
+				t.Errorf("trips got %d want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestCalc(t *testing.T) {
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "trips.txt")
+	err := os.WriteFile(file, []byte("2024-01-01 2024-03-31\n2024-04-01 2024-04-02\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		code   int
+		output string
+	}{
+		{
+			name:   "table",
+			args:   []string{"prog", "calc"},
+			stdin:  "2024-01-01 2024-01-10 FR",
+			code:   exitOK,
+			output: "1     01/01/2024  10/01/2024  10    FR",
+		},
+		{
+			name:   "json",
+			args:   []string{"prog", "calc", "-o", "json"},
+			stdin:  `[{"Start":"2024-01-01","End":"2024-01-10"}]`,
+			code:   exitOK,
+			output: `"daysAway": 10,`,
+		},
+		{
+			name:   "breach from file",
+			args:   []string{"prog", "calc", file},
+			code:   exitBreach,
+			output: "breach          yes, once",
+		},
+		{
+			name:   "rule",
+			args:   []string{"prog", "calc", "-r", "uk-tax-year", file},
+			code:   exitOK,
+			output: "rule            uk-tax-year: 183 days in a year starting 6 April",
+		},
+		{
+			name:  "overlap",
+			args:  []string{"prog", "calc"},
+			stdin: "2024-01-01 2024-01-10\n2024-01-10 2024-01-12",
+			code:  exitError,
+		},
+		{
+			name:   "merged overlap",
+			args:   []string{"prog", "calc", "--merge"},
+			stdin:  "2024-01-01 2024-01-10\n2024-01-10 2024-01-12",
+			code:   exitOK,
+			output: "01/01/2024 to 12/01/2024 (12 days) merged from 2 trips",
+		},
+		{
+			name:   "ics output",
+			args:   []string{"prog", "calc", "-o", "ics"},
+			stdin:  "2024-01-01 2024-01-10 FR",
+			code:   exitOK,
+			output: "SUMMARY:Trip (FR)\r\n",
+		},
+		{
+			name:   "ics inclusive end",
+			args:   []string{"prog", "--ics-inclusive-end", "calc"},
+			stdin:  "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20240101\r\nDTEND;VALUE=DATE:20240110\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			code:   exitOK,
+			output: "1     01/01/2024  10/01/2024  10",
+		},
+		{
+			name: "missing file",
+			args: []string{"prog", "calc", filepath.Join(dir, "missing")},
+			code: exitError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options.Rule = "schengen"
+			options.Merge = false
+			options.InclusiveEnd = false
+			calcOptions.Args.File = ""
+
+			var out, errOut bytes.Buffer
+			stdin = strings.NewReader(tt.stdin)
+			stdout = &out
+			stderr = &errOut
+			serve = func(address, port, baseUrl string) {
+				t.Fatal("server run for a subcommand")
+			}
+			exitCode := -1
+			exit = func(i int) {
+				if exitCode < 0 {
+					exitCode = i
+				}
+			}
+			os.Args = tt.args
+			main()
+
+			if got, want := exitCode, tt.code; got != want {
+				t.Errorf("exit code got %d want %d (%s)", got, want, errOut.String())
+			}
+			if !strings.Contains(out.String(), tt.output) {
+				t.Errorf("output does not contain %q:\n%s", tt.output, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/rorycl/timeaway/trips"
+)
+
+// readInput reads the contents of the file at path, or stdin if path is
+// empty or "-".
+func readInput(path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// detectFormat guesses the format of input: json for a json array, ics
+// for an iCalendar file, query for url query parameters (optionally in a
+// full url), csv for a first line of comma separated column names
+// without digits, or else lines.
+func detectFormat(input []byte) string {
+	trimmed := bytes.TrimSpace(input)
+	first, _, _ := bytes.Cut(trimmed, []byte("\n"))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return "json"
+	case bytes.HasPrefix(trimmed, []byte("BEGIN:VCALENDAR")):
+		return "ics"
+	case bytes.Contains(trimmed, []byte("Start=")):
+		return "query"
+	case bytes.Contains(first, []byte(",")) && !bytes.ContainsAny(first, "0123456789"):
+		return "csv"
+	}
+	return "lines"
+}
+
+// decodeHolidays decodes holidays from input in the provided format,
+// one of json, query, lines, csv or ics, or auto to detect it. The input
+// may be
+//
+//   - json, as for the /trips endpoint, such as
+//     `[{"Start":"2024-01-01","End":"2024-01-10"}]`
+//   - query, url query parameters as used by the web form, such as
+//     `Start=2024-01-01&End=2024-01-10`, or a url containing them
+//   - lines, a trip on each line of the form "start end", optionally
+//     followed by a country code and "exempt" for an exempt period, such
+//     as "2024-01-01 2024-01-10 FR". Blank lines and lines starting with
+//     "#" are ignored.
+//   - csv, with a header row naming the start and end date columns and
+//     optionally country and type columns, as read by
+//     trips.HolidaysCSVDecoder
+//   - ics, an iCalendar file of events, as read by
+//     trips.HolidaysICSDecoder, treating the end dates of all-day events
+//     as the last day away if the ics-inclusive-end option is set
+//
+// As for the web decoders, any validation problems are returned with the
+// holidays as trips.ValidationErrors.
+func decodeHolidays(input []byte, format string) ([]trips.Holiday, error) {
+	if format == "auto" {
+		format = detectFormat(input)
+	}
+	switch format {
+	case "json":
+		return trips.HolidaysJSONDecoder(input)
+	case "query":
+		q := strings.TrimSpace(string(input))
+		if _, after, ok := strings.Cut(q, "?"); ok {
+			q = after
+		}
+		vals, err := url.ParseQuery(q)
+		if err != nil {
+			return nil, fmt.Errorf("query parsing error: %w", err)
+		}
+		return trips.HolidaysURLDecoder(vals)
+	case "csv":
+		return trips.HolidaysCSVDecoder(input)
+	case "ics":
+		var icsOpts []trips.ICSOption
+		if options.InclusiveEnd {
+			icsOpts = append(icsOpts, trips.WithICSInclusiveEnd())
+		}
+		return trips.HolidaysICSDecoder(input, icsOpts...)
+	case "lines":
+		vals, err := linesAsQuery(input)
+		if err != nil {
+			return nil, err
+		}
+		return trips.HolidaysURLDecoder(vals)
+	}
+	return nil, fmt.Errorf("input format %q not known", format)
+}
+
+// linesAsQuery converts "start end [country] [exempt]" lines into the
+// equivalent url query parameters.
+func linesAsQuery(input []byte) (url.Values, error) {
+	vals := url.Values{}
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 4 {
+			return nil, fmt.Errorf("line %d: expected \"start end [country] [exempt]\", got %q", lineNo, line)
+		}
+		country, tripType := "", "trip"
+		for _, f := range fields[2:] {
+			switch strings.ToLower(f) {
+			case "trip", "exempt":
+				tripType = strings.ToLower(f)
+			default:
+				country = f
+			}
+		}
+		vals.Add("Start", fields[0])
+		vals.Add("End", fields[1])
+		vals.Add("Country", country)
+		vals.Add("Type", tripType)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(vals) == 0 {
+		return nil, errors.New("no trips were found")
+	}
+	return vals, nil
+}
@@ -3,12 +3,14 @@ package main
 import (
 	_ "embed"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
 	"strconv"
 
 	flags "github.com/jessevdk/go-flags"
+	"github.com/rorycl/timeaway/trips"
 	"github.com/rorycl/timeaway/web"
 )
 
@@ -16,18 +18,80 @@ var options struct {
 	Port    string `short:"p" long:"port" description:"port to run on" default:"8000"`
 	Addr    string `short:"a" long:"address" description:"network address to run on" default:"127.0.0.1"`
 	BaseURL string `short:"b" long:"baseurl" description:"web server base URL" default:""`
+	Rule    string `short:"r" long:"rule" description:"default calculation rule, such as schengen, uk-tax-year, calendar-year or consecutive-90" default:"schengen"`
+	Merge   bool   `short:"m" long:"merge" description:"merge overlapping trips by default rather than reporting them as errors"`
+
+	InclusiveEnd bool   `long:"ics-inclusive-end" description:"treat the end dates of all-day events in ics input as the last day away"`
+	Store        string `long:"store" description:"directory in which to save named trip sets; saving is disabled if not set" default:""`
 }
 
 var serve func(string, string, string) = web.Serve
 var exit func(int) = os.Exit
 
+// subcommand input and output, which may be swapped out for testing
+var stdin io.Reader = os.Stdin
+var stdout io.Writer = os.Stdout
+var stderr io.Writer = os.Stderr
+
+// command is the name of the subcommand to run, if any, instead of
+// the web server
+var command string
+
+// commands are the subcommands, by name
+var commands = map[string]func() int{
+	"calc":  calc,
+	"svg":   svgCalendar,
+	"batch": batch,
+}
+
 func getOptions() (string, string, string) {
 	log.SetOutput(os.Stderr)
-	_, err := flags.Parse(&options)
+	parser := flags.NewParser(&options, flags.Default)
+	parser.SubcommandsOptional = true
+	_, err := parser.AddCommand(
+		"calc",
+		"calculate trips from a file or stdin",
+		"Calculate trips read from a file or stdin as json, url query parameters, \"start end\" lines, csv or ics, "+
+			"printing the results as a table, json or an ics calendar. The exit code is 2 if the trips breach the rule.",
+		&calcOptions,
+	)
+	if err != nil {
+		fmt.Printf("command error: %v\n", err)
+		exit(1)
+	}
+	_, err = parser.AddCommand(
+		"svg",
+		"write the svg calendar of trips from a file or stdin",
+		"Calculate trips read from a file or stdin as for the calc command, writing the svg calendar of the trips "+
+			"to a file or stdout. The exit code is 2 if the trips breach the rule.",
+		&svgOptions,
+	)
+	if err != nil {
+		fmt.Printf("command error: %v\n", err)
+		exit(1)
+	}
+	_, err = parser.AddCommand(
+		"batch",
+		"stream newline delimited json trip sets from a file or stdin",
+		"Calculate newline delimited json trip sets read from a file or stdin, each of the form "+
+			"{\"name\":\"alice\",\"trips\":[{\"start\":\"2024-01-01\",\"end\":\"2024-01-10\"}]} as for the /api/v1/batch endpoint, "+
+			"writing a json result line for each set as soon as it is calculated. The exit code is 1 if any set could not "+
+			"be calculated, or else 2 if any set breaches its rule.",
+		&batchOptions,
+	)
+	if err != nil {
+		fmt.Printf("command error: %v\n", err)
+		exit(1)
+	}
+	_, err = parser.Parse()
 	if err != nil {
 		fmt.Printf("flag parsing error: %v\n", err)
 		exit(1)
 	}
+	command = ""
+	if parser.Active != nil {
+		command = parser.Active.Name
+	}
 
 	// verify options
 	port, err := strconv.Atoi(options.Port)
@@ -39,10 +103,30 @@ func getOptions() (string, string, string) {
 		fmt.Printf("address %s invalid; exiting\n", options.Addr)
 		exit(1)
 	}
+	if _, err := trips.RuleByName(options.Rule); err != nil {
+		fmt.Printf("%v; exiting\n", err)
+		exit(1)
+	}
+	web.DefaultRule = options.Rule
+	web.DefaultMerge = options.Merge
+	if options.Store != "" && command == "" {
+		store, err := web.NewFileStore(options.Store)
+		if err != nil {
+			fmt.Printf("%v; exiting\n", err)
+			exit(1)
+		}
+		web.TripStore = store
+	}
 	return options.Addr, options.Port, options.BaseURL
 }
 
 func main() {
+	addr, port, baseURL := getOptions()
+	// run a subcommand if one was chosen
+	if run, ok := commands[command]; ok {
+		exit(run())
+		return
+	}
 	// run the server
-	serve(getOptions())
+	serve(addr, port, baseURL)
 }
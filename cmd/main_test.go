@@ -3,11 +3,19 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
 func TestGetOptions(t *testing.T) {
 
+	// a store directory cannot be made under a file
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
 		args []string
 		ok   int
@@ -24,6 +32,26 @@ func TestGetOptions(t *testing.T) {
 			args: []string{"prog", "-a", "127.0.0.1", "-p", "8000", "-b", "/baseurl"},
 			ok:   0,
 		},
+		{
+			args: []string{"prog", "-r", "uk-tax-year"},
+			ok:   0,
+		},
+		{
+			args: []string{"prog", "--rule", "unknown"},
+			ok:   1,
+		},
+		{
+			args: []string{"prog", "-m"},
+			ok:   0,
+		},
+		{
+			args: []string{"prog", "--store", filepath.Join(dir, "sets")},
+			ok:   0,
+		},
+		{
+			args: []string{"prog", "--store", filepath.Join(file, "sets")},
+			ok:   1,
+		},
 	}
 
 	var exitCode int
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rorycl/timeaway/svg"
+)
+
+// svgOptions are the options for the svg subcommand, which also uses the
+// rule and merge options
+var svgOptions struct {
+	Format   string   `short:"f" long:"format" description:"input format" choice:"auto" choice:"json" choice:"query" choice:"lines" choice:"csv" choice:"ics" default:"auto"`
+	Out      string   `short:"o" long:"out" description:"svg file to write, or - for stdout" default:"-"`
+	Width    int      `short:"w" long:"width" description:"width of the svg in pixels" default:"860"`
+	Stripes  []string `short:"s" long:"stripes" description:"stripes to include, repeated or comma separated, from holidays, exempt, window and rules (default: holidays, exempt and window)"`
+	Forecast int      `short:"F" long:"forecast" description:"days after the last trip over which to forecast the allowance remaining (default: none)"`
+	Args     struct {
+		File string `positional-arg-name:"file" description:"file of trips to read, or - for stdin (the default)"`
+	} `positional-args:"yes"`
+}
+
+// svgCalendar reads trips from a file or stdin, calculates them with the
+// selected rule and writes the svg calendar of the trips to a file or
+// stdout, returning exitBreach if the trips breach the rule.
+func svgCalendar() int {
+	trs, err := calculateInput(svgOptions.Args.File, svgOptions.Format)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	svgOpts := []svg.Option{svg.WithWidth(svgOptions.Width)}
+	if len(svgOptions.Stripes) > 0 {
+		stripes := []string{}
+		for _, s := range svgOptions.Stripes {
+			for _, k := range strings.Split(s, ",") {
+				stripes = append(stripes, strings.TrimSpace(k))
+			}
+		}
+		svgOpts = append(svgOpts, svg.WithStripes(stripes...))
+	}
+	if svgOptions.Forecast != 0 {
+		svgOpts = append(svgOpts, svg.WithForecast(svgOptions.Forecast))
+	}
+
+	// render to a buffer so that a file is not written on error
+	var buf strings.Builder
+	err = svg.TripsAsSVG(trs, &buf, svgOpts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "svg error: %v\n", err)
+		return exitError
+	}
+	if svgOptions.Out == "-" || svgOptions.Out == "" {
+		_, err = io.WriteString(stdout, buf.String())
+	} else {
+		err = os.WriteFile(svgOptions.Out, []byte(buf.String()), 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "output error: %v\n", err)
+		return exitError
+	}
+	if trs.Breach {
+		return exitBreach
+	}
+	return exitOK
+}
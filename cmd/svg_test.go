@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSVGCalendar(t *testing.T) {
+
+	dir := t.TempDir()
+	trips := "2024-01-01 2024-03-31\n2024-04-05 2024-04-06\n"
+
+	tests := []struct {
+		name    string
+		args    []string
+		code    int
+		file    string // output file, or stdout if empty
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "stdout",
+			args: []string{"prog", "svg"},
+			code: exitBreach,
+			want: []string{`<svg width="860"`, "<title>holiday: 2024-01-01 to 2024-03-31</title>", "<title>breach (93 days) "},
+		},
+		{
+			name:    "file with width and stripes",
+			args:    []string{"prog", "svg", "-w", "430", "-s", "holidays,rules", "-o", filepath.Join(dir, "cal.svg")},
+			code:    exitBreach,
+			file:    filepath.Join(dir, "cal.svg"),
+			want:    []string{`<svg width="430"`, "<title>breach (schengen 93 days) ", "consecutive-90 breach"},
+			notWant: []string{"<title>breach (93 days) "},
+		},
+		{
+			name:    "repeated stripes",
+			args:    []string{"prog", "svg", "-s", "holidays", "-s", "exempt", "-r", "uk-tax-year"},
+			code:    exitOK,
+			want:    []string{"<title>holiday: 2024-04-05 to 2024-04-06</title>"},
+			notWant: []string{"longest window"},
+		},
+		{
+			name: "forecast",
+			args: []string{"prog", "svg", "-F", "60"},
+			code: exitBreach,
+			want: []string{"<title>forecast (exhausted, 0 days remaining) : 2024-04-07 to 2024-06-05</title>", ">full allowance</text>"},
+		},
+		{
+			name: "bad forecast",
+			args: []string{"prog", "svg", "--forecast", "-1"},
+			code: exitError,
+		},
+		{
+			name: "unknown stripe",
+			args: []string{"prog", "svg", "-s", "weekends"},
+			code: exitError,
+		},
+		{
+			name: "bad width",
+			args: []string{"prog", "svg", "-w", "10"},
+			code: exitError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options.Rule = "schengen"
+			options.Merge = false
+			svgOptions.Args.File = ""
+			svgOptions.Out = "-"
+			svgOptions.Stripes = nil
+			svgOptions.Forecast = 0
+
+			var out, errOut bytes.Buffer
+			stdin = strings.NewReader(trips)
+			stdout = &out
+			stderr = &errOut
+			serve = func(address, port, baseUrl string) {
+				t.Fatal("server run for a subcommand")
+			}
+			exitCode := -1
+			exit = func(i int) {
+				if exitCode < 0 {
+					exitCode = i
+				}
+			}
+			os.Args = tt.args
+			main()
+
+			if got, want := exitCode, tt.code; got != want {
+				t.Fatalf("exit code got %d want %d (%s)", got, want, errOut.String())
+			}
+			output := out.String()
+			if tt.file != "" {
+				b, err := os.ReadFile(tt.file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				output = string(b)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("output does not contain %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(output, notWant) {
+					t.Errorf("output unexpectedly contains %q", notWant)
+				}
+			}
+		})
+	}
+}
@@ -42,12 +42,40 @@ func GCPServer(w http.ResponseWriter, r *http.Request) {
 	m.HandleFunc("/partials/report", web.PartialReport)
 	m.HandleFunc("/partials/nocontent", web.PartialNoContent)
 	m.HandleFunc("/partials/addtrip", web.PartialAddTrip)
+	m.HandleFunc("/partials/plan", web.PartialPlan)
+	m.HandleFunc("/partials/upload", web.PartialUpload)
+	m.HandleFunc("/partials/sets", web.PartialSets)
+	m.HandleFunc("/partials/sets/save", web.PartialSetSave)
+	m.HandleFunc("/partials/sets/rename", web.PartialSetRename)
+	m.HandleFunc("/partials/sets/delete", web.PartialSetDelete)
+	m.HandleFunc("/partials/team", web.PartialTeam)
+	m.HandleFunc("/partials/addtraveller", web.PartialAddTraveller)
+	m.HandleFunc("/partials/compare", web.PartialCompare)
+	m.HandleFunc("/partials/addscenario", web.PartialAddScenario)
 
 	// main routes
 	m.HandleFunc("/", web.Home)
 	m.HandleFunc("/home", web.Home)
+	m.HandleFunc("/team", web.Team)
+	m.HandleFunc("/compare", web.Compare)
 	m.HandleFunc("/trips", web.Trips)
+	m.HandleFunc("/plan", web.Plan)
+	m.HandleFunc("/timeline", web.Timeline)
+	m.HandleFunc("/timeline.csv", web.TimelineCSV)
+	m.HandleFunc("/trips.ics", web.TripsICS)
+	m.HandleFunc("/calendar.ics", web.CalendarICS)
+	m.HandleFunc("/sets", web.SavedSets)
+	m.HandleFunc("/sets/{name}", web.SavedSet)
 	m.HandleFunc("/health", web.Health)
 
+	// api routes, reporting unknown api paths with an api error
+	m.HandleFunc("/api/v1/calculate", web.APICalculate)
+	m.HandleFunc("/api/v1/batch", web.APIBatch)
+	m.HandleFunc("/api/v1/stream", web.APIStream)
+	m.HandleFunc("/api/v1/compare", web.APICompare)
+	m.HandleFunc("/api/v1/rules", web.APIRules)
+	m.HandleFunc("/api/v1/openapi.json", web.APIOpenAPI)
+	m.PathPrefix("/api/").HandlerFunc(web.APINotFound)
+
 	m.ServeHTTP(w, r)
 }
@@ -3,10 +3,12 @@
 package svg
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"maps"
 	"math"
+	"slices"
 	"time"
 
 	svg "github.com/ajstarks/svgo"
@@ -45,8 +47,112 @@ const (
 
 	// target width, which requires the design to be scale
 	targetWidth int = 860 // px
+
+	// the range of widths which may be set with WithWidth
+	minWidth int = 200   // px
+	maxWidth int = 10000 // px
+
+	// the longest forecast which may be set with WithForecast
+	maxForecast int = 3660 // days
+)
+
+// ruleColours are the colours used for the breach stripes of each rule
+// when rendering rule stripes, reused in order if there are more rules
+// than colours.
+var ruleColours = []string{"red", "darkorange", "purple", "saddlebrown", "deeppink"}
+
+// exemptColour is the colour of exempt period stripes, drawn over the
+// holidays stripes.
+const exemptColour string = "gold"
+
+// forecastColours are the colours of the forecast stripes of days on
+// which the full allowance, part of the allowance or none of it remains.
+var forecastColours = map[string]string{
+	"full":      "mediumseagreen",
+	"partial":   "khaki",
+	"exhausted": "lightcoral",
+}
+
+// The kinds of stripes which may be selected with WithStripes.
+const (
+	HolidayStripes string = "holidays" // the holidays
+	ExemptStripes  string = "exempt"   // the exempt periods, over the holidays
+	WindowStripes  string = "window"   // the breaches, or else the longest window
+	RuleStripes    string = "rules"    // the breaches of each rule result
 )
 
+// stripeKinds are the kinds of stripes, in rendering order.
+var stripeKinds = []string{HolidayStripes, ExemptStripes, WindowStripes, RuleStripes}
+
+// config holds the rendering settings for TripsAsSVG.
+type config struct {
+	width    int             // the width of the svg in pixels
+	stripes  map[string]bool // the kinds of stripes to render
+	forecast int             // days past the end of the trips to forecast
+}
+
+// Option is a functional option for configuring TripsAsSVG.
+type Option func(*config) error
+
+// WithRuleStripes renders the breaches of each rule reported in
+// Trips.Results on a level of its own, in place of the breach or
+// longest window stripe of the Trips rule.
+func WithRuleStripes() Option {
+	return func(c *config) error {
+		c.stripes[WindowStripes] = false
+		c.stripes[RuleStripes] = true
+		return nil
+	}
+}
+
+// WithStripes sets the kinds of stripes to render, from HolidayStripes,
+// ExemptStripes, WindowStripes and RuleStripes. By default the holiday,
+// exempt and window stripes are rendered. Window and rule stripes are
+// each rendered on levels of their own below the holidays.
+func WithStripes(kinds ...string) Option {
+	return func(c *config) error {
+		if len(kinds) == 0 {
+			return errors.New("no stripes were selected")
+		}
+		stripes := map[string]bool{}
+		for _, k := range kinds {
+			if !slices.Contains(stripeKinds, k) {
+				return fmt.Errorf("stripe %q not known", k)
+			}
+			stripes[k] = true
+		}
+		c.stripes = stripes
+		return nil
+	}
+}
+
+// WithWidth sets the width of the svg in pixels, which is 860 by
+// default. The height is scaled to match.
+func WithWidth(px int) Option {
+	return func(c *config) error {
+		if px < minWidth || px > maxWidth {
+			return fmt.Errorf("width must be between %d and %d pixels", minWidth, maxWidth)
+		}
+		c.width = px
+		return nil
+	}
+}
+
+// WithForecast extends the calendar rendered by TripsAsSVG by horizon
+// days past the end of the trips, shading each of those days by the
+// allowance remaining on it: the full allowance, part of it, or none.
+// The forecast is rendered on the holidays level, which has no holidays
+// after the end of the trips.
+func WithForecast(horizon int) Option {
+	return func(c *config) error {
+		if horizon < 1 || horizon > maxForecast {
+			return fmt.Errorf("forecast must be between 1 and %d days", maxForecast)
+		}
+		c.forecast = horizon
+		return nil
+	}
+}
+
 // container is the rectangle describing the content
 type container struct {
 	borderColour     string
@@ -140,6 +246,7 @@ type weekGrid struct {
 	rows          int // number of rows at 8 weeks/row
 	columns       int // number of columns
 	legendHeight  int // position of legend
+	blockHeight   int // height of each row of weeks
 	width, height int // overall width and height
 
 	// report the column & row pos and coordinates
@@ -147,33 +254,91 @@ type weekGrid struct {
 	dateMatrix map[time.Time]xyColRow
 }
 
+// calendarDates returns the first and last dates of the calendar of
+// trips. Use the start and end date of the trips by default for the
+// reporting period. However if the window extends past these dates and
+// the Trips are in Breach, use the window dates instead in order to
+// render the breach strip correctly (otherwise the breach strip cannot
+// resolve to a system coordinate).
+func calendarDates(trips *trips.Trips) (start, end time.Time) {
+	start, end = trips.Start, trips.End
+	if start.After(trips.Window.Start) && trips.Breach {
+		start = trips.Window.Start
+	}
+	if end.Before(trips.Window.End) && trips.Breach {
+		end = trips.Window.End
+	}
+	return start, end
+}
+
+// forecastStripes returns the stripes of the days from the day after
+// the end of the trips to the forecast horizon, each stripe covering the
+// consecutive days on which the full allowance, part of it or none of it
+// remains, as reported by the trips timeline.
+func forecastStripes(tr *trips.Trips, horizon int) ([]*stripe, error) {
+	timeline, err := tr.Timeline(horizon)
+	if err != nil {
+		return nil, fmt.Errorf("forecast error: %w", err)
+	}
+
+	// allowance describes the allowance remaining on a day
+	allowance := func(d trips.TimelineDay) string {
+		switch {
+		case d.DaysRemaining == 0:
+			return "exhausted"
+		case d.DaysRemaining < tr.MaxStay:
+			return "partial"
+		}
+		return "full"
+	}
+
+	stripes := []*stripe{}
+	var run []trips.TimelineDay
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		least, most := run[0].DaysRemaining, run[0].DaysRemaining
+		for _, d := range run {
+			least, most = min(least, d.DaysRemaining), max(most, d.DaysRemaining)
+		}
+		kind := allowance(run[0])
+		info := fmt.Sprintf("%s, %d days remaining", kind, least)
+		if least != most {
+			info = fmt.Sprintf("%s, %d to %d days remaining", kind, least, most)
+		}
+		stripes = append(stripes, newStripe("forecast", info, forecastColours[kind], run[0].Date, run[len(run)-1].Date, 5, 0))
+		run = nil
+	}
+	for _, d := range timeline {
+		if !d.Date.After(tr.End) {
+			continue
+		}
+		if len(run) > 0 && allowance(run[0]) != allowance(d) {
+			flush()
+		}
+		run = append(run, d)
+	}
+	flush()
+	return stripes, nil
+}
+
 // newGrid makes a new weekGrid with the appropriate dimensions and
-// coordinates.
-func newGrid(trips *trips.Trips) (*weekGrid, error) {
+// coordinates covering the weeks from start to end, with the height of
+// each row of weeks increased to fit the number of stripe levels if
+// more than two.
+func newGrid(start, end time.Time, levels int) (*weekGrid, error) {
 	grid := weekGrid{
-		columns: weeksPerRow,
+		columns:     weeksPerRow,
+		blockHeight: weekBlockHeight + stripePadding*max(levels-2, 0),
 	}
 
-	// Use the start and end date of the trips by default for the
-	// reporting period. However if the window extends past these dates
-	// and the Trips are in Breach, use the window dates instead in
-	// order to render the breach strip correctly (otherwise the breach
-	// strip cannot resolve to a system coordinate).
 	var err error
-	minStartDate := trips.Start
-	if minStartDate.After(trips.Window.Start) && trips.Breach {
-		minStartDate = trips.Window.Start
-	}
-	grid.startDate, err = changeDate(minStartDate, 1, time.Hour*24*-1)
+	grid.startDate, err = changeDate(start, 1, time.Hour*24*-1)
 	if err != nil {
 		return nil, fmt.Errorf("grid startDate error %w", err)
 	}
-
-	maxEndDate := trips.End
-	if maxEndDate.Before(trips.Window.End) && trips.Breach {
-		maxEndDate = trips.Window.End
-	}
-	grid.endDate, err = changeDate(maxEndDate, 0, time.Hour*24*+1)
+	grid.endDate, err = changeDate(end, 0, time.Hour*24*+1)
 	if err != nil {
 		return nil, fmt.Errorf("grid endDate error %w", err)
 	}
@@ -186,14 +351,14 @@ func newGrid(trips *trips.Trips) (*weekGrid, error) {
 	grid.rightGutter = (weekBlockWidth * grid.columns) + weekNotchSpacing
 
 	grid.legendHeight = topPadding + legendOwnHeight // r1
-	grid.height = grid.legendHeight + (weekBlockHeight * grid.rows) + bottomPadding
+	grid.height = grid.legendHeight + (grid.blockHeight * grid.rows) + bottomPadding
 
 	// Set out the dates/coordinates for dateMatrix.
 	grid.dateMatrix = map[time.Time]xyColRow{}
 	col, row := 0, 0
 	for d := grid.startDate; d.Before(grid.endDate); d = d.Add(time.Hour * 24 * 7) {
 		cx := leftPadding + (weekBlockWidth * col)
-		cy := grid.legendHeight + weekBlockHeight + (weekBlockHeight * row) // make space for first row
+		cy := grid.legendHeight + grid.blockHeight + (grid.blockHeight * row) // make space for first row
 		grid.dateMatrix[d] = xyColRow{
 			x: cx, y: cy, col: col, row: row,
 		}
@@ -412,38 +577,48 @@ func (s *stripe) render(g *weekGrid, svg *svg.SVG) error {
 	return nil
 }
 
-// TripsAsSVG renders a set of trips as an SVG graphic calendar marking
-// the holidays, longest window or breach window according to the
-// results of the Trip calculations.
-func TripsAsSVG(trips *trips.Trips, w io.Writer) error {
-
-	grid, err := newGrid(trips)
-	if err != nil {
-		return err
+// newConfig returns the rendering settings modified by options.
+func newConfig(options ...Option) (*config, error) {
+	cfg := &config{
+		width: targetWidth,
+		stripes: map[string]bool{
+			HolidayStripes: true,
+			ExemptStripes:  true,
+			WindowStripes:  true,
+		},
+	}
+	for _, o := range options {
+		if err := o(cfg); err != nil {
+			return nil, err
+		}
 	}
+	return cfg, nil
+}
+
+// newCanvas starts an svg canvas for the grid scaled to width, and
+// renders the background, the legend of labels and the weeks of the
+// grid. The scaling group must be closed with Gend before the canvas is
+// ended.
+func newCanvas(w io.Writer, grid *weekGrid, width int, labels []label) (*svg.SVG, error) {
 
 	canvas := svg.New(w)
 
 	// calculate the canvas and viewbox sizes.
 	// https://developer.mozilla.org/en-US/docs/Web/SVG/Attribute/viewBox
 	// https://www.digitalocean.com/community/tutorials/svg-svg-viewbox
-	viewboxX, viewboxY := grid.viewBox(targetWidth)
+	viewboxX, viewboxY := grid.viewBox(width)
 	viewBox := fmt.Sprintf(`viewBox="0 0 %d %d"`, viewboxX, viewboxY)
 	// canvas.Start(grid.width, grid.height, viewBox)
 	// It isn't clear why Start doesn't take the image width/height
 	// (grid.width, grid.height) since the viewBox is smaller than the
 	// image.
 	canvas.Start(viewboxX, viewboxY, viewBox)
-	canvas.Scale(float64(targetWidth) / float64(grid.width)) // needs GEnd() -- see bottom
+	canvas.Scale(float64(width) / float64(grid.width)) // needs GEnd()
 
 	background := newContainer("#c4c8b7ff", "#ecececff", 2)
 	background.render(grid.width, grid.height, canvas)
 
-	legend := newLegend(leftPadding, grid.legendHeight, []label{
-		label{"holidays", "green", 5},
-		label{"breach", "red", 5},
-		label{"longest window without breach", "blue", 5},
-	})
+	legend := newLegend(leftPadding, grid.legendHeight, labels)
 	legend.render(canvas)
 
 	// render the weeks by progressing a week at a time from the start
@@ -452,14 +627,93 @@ func TripsAsSVG(trips *trips.Trips, w io.Writer) error {
 		date := grid.startDate.Add(time.Hour * 24 * 7 * time.Duration(i))
 		coordinates, ok := grid.coordinates(date)
 		if !ok {
-			return fmt.Errorf("date %s no coordinates\n", date)
+			return nil, fmt.Errorf("date %s no coordinates\n", date)
 		}
 		week := newWeek(coordinates.x, coordinates.y, date)
 		week.render(canvas)
 	}
+	return canvas, nil
+}
+
+// TripsAsSVG renders a set of trips as an SVG graphic calendar marking
+// the holidays, longest window or breach window according to the
+// results of the Trip calculations, as modified by the provided options,
+// optionally followed by a forecast of the allowance remaining.
+func TripsAsSVG(trips *trips.Trips, w io.Writer, options ...Option) error {
+
+	cfg, err := newConfig(options...)
+	if err != nil {
+		return err
+	}
+	start, end := calendarDates(trips)
+
+	// extend the calendar to the forecast horizon
+	var forecast []*stripe
+	if cfg.forecast > 0 {
+		forecast, err = forecastStripes(trips, cfg.forecast)
+		if err != nil {
+			return err
+		}
+		if horizon := trips.End.AddDate(0, 0, cfg.forecast); horizon.After(end) {
+			end = horizon
+		}
+	}
+	windowStripes := cfg.stripes[WindowStripes]
+	ruleStripes := cfg.stripes[RuleStripes] && len(trips.Results) > 0
+
+	// levels are the holiday level, a level for the trips breach or
+	// longest window and a level for each rule, if selected
+	levels, ruleLevel := 1, 1
+	if windowStripes {
+		levels++
+		ruleLevel++
+	}
+	if ruleStripes {
+		levels += len(trips.Results)
+	}
 
-	// stripe in the holidays
-	for _, tr := range trips.OriginalHolidays {
+	grid, err := newGrid(start, end, levels)
+	if err != nil {
+		return err
+	}
+
+	labels := []label{}
+	if cfg.stripes[HolidayStripes] {
+		labels = append(labels, label{"holidays", "green", 5})
+	}
+	if cfg.stripes[ExemptStripes] && len(trips.Exemptions) > 0 {
+		labels = append(labels, label{"exempt", exemptColour, 5})
+	}
+	if windowStripes {
+		labels = append(labels,
+			label{"breach", "red", 5},
+			label{"longest window without breach", "blue", 5},
+		)
+	}
+	if ruleStripes {
+		for i, r := range trips.Results {
+			labels = append(labels, label{r.Rule + " breach", ruleColours[i%len(ruleColours)], 5})
+		}
+	}
+	if len(forecast) > 0 {
+		for _, kind := range []string{"full", "partial", "exhausted"} {
+			labels = append(labels, label{kind + " allowance", forecastColours[kind], 5})
+		}
+	}
+	canvas, err := newCanvas(w, grid, cfg.width, labels)
+	if err != nil {
+		return err
+	}
+
+	// stripe in the holidays and exempt periods, if selected
+	holidays, exemptions := trips.OriginalHolidays, trips.Exemptions
+	if !cfg.stripes[HolidayStripes] {
+		holidays = nil
+	}
+	if !cfg.stripes[ExemptStripes] {
+		exemptions = nil
+	}
+	for _, tr := range holidays {
 		thisStripe := newStripe("holiday", "", "green", tr.Start, tr.End, 5, 0)
 		err := thisStripe.render(grid, canvas)
 		if err != nil {
@@ -467,21 +721,67 @@ func TripsAsSVG(trips *trips.Trips, w io.Writer) error {
 		}
 	}
 
-	// stripe in either the breach or no-breach longest window line
-	// segments showing the first holiday start date
-	// (trips.Window.OverlapStart) and last holiday end date
-	// (trips.Window.OverlapEnd) overlapping with the assessment window.
-	// Note that trips.Window.OverlapStart and trips.Window.OverlapEnd
-	// will the same as trips.Window.Start and trips.Window.End if there
-	// is a breach.
-	if trips.Breach {
-		info := fmt.Sprintf("%d days", trips.Window.DaysAway)
-		thisStripe := newStripe("breach", info, "red", trips.Window.Start, trips.Window.End, 5, 1)
+	// stripe in the forecast after the holidays
+	for _, thisStripe := range forecast {
 		err := thisStripe.render(grid, canvas)
 		if err != nil {
 			return fmt.Errorf("stripe render error: %w", err)
 		}
-	} else {
+	}
+
+	// stripe in the exempt periods over the holidays, clipped to the
+	// dates of the calendar
+	for _, ex := range exemptions {
+		start, end := ex.Start, ex.End
+		if start.Before(trips.Start) {
+			start = trips.Start
+		}
+		if end.After(trips.End) {
+			end = trips.End
+		}
+		if end.Before(start) {
+			continue
+		}
+		thisStripe := newStripe("exempt", "", exemptColour, start, end, 3, 0)
+		err := thisStripe.render(grid, canvas)
+		if err != nil {
+			return fmt.Errorf("stripe render error: %w", err)
+		}
+	}
+
+	// stripe in the breaches of each rule on its own level
+	if ruleStripes {
+		for i, r := range trips.Results {
+			for _, b := range r.Breaches {
+				info := fmt.Sprintf("%s %d days", r.Rule, b.DaysAway)
+				thisStripe := newStripe("breach", info, ruleColours[i%len(ruleColours)], b.Start, b.End, 5, ruleLevel+i)
+				err := thisStripe.render(grid, canvas)
+				if err != nil {
+					return fmt.Errorf("stripe render error: %w", err)
+				}
+			}
+		}
+	}
+
+	// stripe in each breach or the no-breach longest window line
+	// segments. Each breach runs from the first to the last day on
+	// which the window ending on that day breached. The no-breach line
+	// shows the first holiday start date (trips.Window.OverlapStart)
+	// and last holiday end date (trips.Window.OverlapEnd) overlapping
+	// with the assessment window, and is not drawn if no days away were
+	// counted.
+	switch {
+	case !windowStripes, !trips.Breach && trips.Window.DaysAway == 0:
+	case trips.Breach:
+		for _, b := range trips.Breaches {
+			info := fmt.Sprintf("%d days", b.DaysAway)
+			thisStripe := newStripe("breach", info, "red", b.Start, b.End, 5, 1)
+			err := thisStripe.render(grid, canvas)
+			if err != nil {
+				return fmt.Errorf("stripe render error: %w", err)
+			}
+		}
+	default:
 		info := fmt.Sprintf("%d days", trips.Window.DaysAway)
 		thisStripe := newStripe("longest window", info, "blue", trips.Window.OverlapStart, trips.Window.OverlapEnd, 5, 1)
 		err := thisStripe.render(grid, canvas)
@@ -494,3 +794,130 @@ func TripsAsSVG(trips *trips.Trips, w io.Writer) error {
 	canvas.End()
 	return nil
 }
+
+// teamColours are the colours used for the holiday stripes of each
+// traveller in a team calendar, reused in order if there are more
+// travellers than colours.
+var teamColours = []string{"green", "blue", "teal", "olive", "navy", "darkcyan", "darkslategray"}
+
+// changedColour is the colour of the changed holidays of a traveller
+// in a team calendar.
+const changedColour = "darkorange"
+
+// Traveller is a named traveller's calculated trips, as rendered on a
+// level of its own by TeamAsSVG. Changed holidays, such as the trips of
+// a scenario which differ from those of the other scenarios, are marked
+// in a colour of their own.
+type Traveller struct {
+	Name    string
+	Trips   *trips.Trips
+	Changed []trips.Holiday
+}
+
+// TeamAsSVG renders the trips of several travellers as a single SVG
+// graphic calendar spanning the trips of them all, with a level of
+// stripes for each traveller stacked above the weeks in the order
+// provided. The holidays of each traveller are marked in a colour of
+// their own, overlaid by their changed holidays, exempt periods and
+// breaches. The HolidayStripes, ExemptStripes and WindowStripes options
+// select what is rendered, although the WindowStripes option only shows
+// breaches and RuleStripes are not rendered.
+func TeamAsSVG(team []Traveller, w io.Writer, options ...Option) error {
+
+	if len(team) == 0 {
+		return errors.New("no travellers were provided")
+	}
+	cfg, err := newConfig(options...)
+	if err != nil {
+		return err
+	}
+
+	// the calendar spans the trips of every traveller
+	var start, end time.Time
+	exempt, breach, changed := false, false, false
+	for i, tr := range team {
+		if tr.Trips == nil {
+			return fmt.Errorf("traveller %q has no calculated trips", tr.Name)
+		}
+		s, e := calendarDates(tr.Trips)
+		if i == 0 || s.Before(start) {
+			start = s
+		}
+		if i == 0 || e.After(end) {
+			end = e
+		}
+		exempt = exempt || len(tr.Trips.Exemptions) > 0
+		breach = breach || tr.Trips.Breach
+		changed = changed || len(tr.Changed) > 0
+	}
+
+	grid, err := newGrid(start, end, len(team))
+	if err != nil {
+		return err
+	}
+
+	labels := []label{}
+	if cfg.stripes[HolidayStripes] {
+		for i, tr := range team {
+			labels = append(labels, label{tr.Name, teamColours[i%len(teamColours)], 5})
+		}
+		if changed {
+			labels = append(labels, label{"changed", changedColour, 5})
+		}
+	}
+	if cfg.stripes[ExemptStripes] && exempt {
+		labels = append(labels, label{"exempt", exemptColour, 3})
+	}
+	if cfg.stripes[WindowStripes] && breach {
+		labels = append(labels, label{"breach", "red", 3})
+	}
+	canvas, err := newCanvas(w, grid, cfg.width, labels)
+	if err != nil {
+		return err
+	}
+
+	// stripe in each traveller's holidays, exempt periods and breaches,
+	// the first traveller on the highest level
+	for i, tr := range team {
+		level := len(team) - 1 - i
+		stripes := []*stripe{}
+		if cfg.stripes[HolidayStripes] {
+			for _, h := range tr.Trips.OriginalHolidays {
+				stripes = append(stripes, newStripe("holiday", tr.Name, teamColours[i%len(teamColours)], h.Start, h.End, 5, level))
+			}
+			for _, h := range tr.Changed {
+				stripes = append(stripes, newStripe("changed", tr.Name, changedColour, h.Start, h.End, 5, level))
+			}
+		}
+		if cfg.stripes[ExemptStripes] {
+			for _, ex := range tr.Trips.Exemptions {
+				start, end := ex.Start, ex.End
+				if start.Before(tr.Trips.Start) {
+					start = tr.Trips.Start
+				}
+				if end.After(tr.Trips.End) {
+					end = tr.Trips.End
+				}
+				if end.Before(start) {
+					continue
+				}
+				stripes = append(stripes, newStripe("exempt", tr.Name, exemptColour, start, end, 3, level))
+			}
+		}
+		if cfg.stripes[WindowStripes] {
+			for _, b := range tr.Trips.Breaches {
+				info := fmt.Sprintf("%s %d days", tr.Name, b.DaysAway)
+				stripes = append(stripes, newStripe("breach", info, "red", b.Start, b.End, 3, level))
+			}
+		}
+		for _, s := range stripes {
+			if err := s.render(grid, canvas); err != nil {
+				return fmt.Errorf("stripe render error: %w", err)
+			}
+		}
+	}
+
+	canvas.Gend()
+	canvas.End()
+	return nil
+}
@@ -1,6 +1,8 @@
 package svg
 
 import (
+	"fmt"
+	"io"
 	"strings"
 	"testing"
 	"time"
@@ -16,6 +18,8 @@ func makeTrips() *trips.Trips {
 	//
 	// The maximum days away were for a 180 day window from Tuesday 01/07/2025 to Saturday 27/12/2025.
 	//
+	// The trips were in breach from Saturday 27/12/2025 to Tuesday 06/01/2026.
+	//
 	// The trips in this calculation are:
 	//
 	//  1. Tuesday 17/12/2024 to Saturday 04/01/2025 (19 days)
@@ -136,6 +140,30 @@ func makeTrips() *trips.Trips {
 		LongestDaysAway: 91,
 		Error:           nil,
 		Breach:          true,
+		Breaches: []trips.Breach{
+			trips.Breach{
+				Start:    time.Date(2025, 12, 27, 0, 0, 0, 0, time.UTC),
+				End:      time.Date(2026, 1, 6, 0, 0, 0, 0, time.UTC),
+				DaysAway: 91,
+				Holidays: []trips.Holiday{
+					trips.Holiday{
+						Start:    time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+						End:      time.Date(2025, 9, 3, 0, 0, 0, 0, time.UTC),
+						Duration: 65,
+					},
+					trips.Holiday{
+						Start:    time.Date(2025, 11, 9, 0, 0, 0, 0, time.UTC),
+						End:      time.Date(2025, 11, 16, 0, 0, 0, 0, time.UTC),
+						Duration: 8,
+					},
+					trips.Holiday{
+						Start:    time.Date(2025, 12, 10, 0, 0, 0, 0, time.UTC),
+						End:      time.Date(2026, 1, 6, 0, 0, 0, 0, time.UTC),
+						Duration: 28,
+					},
+				},
+			},
+		},
 	}
 }
 
@@ -149,7 +177,7 @@ func TestSVG(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	got, want := svgOutput.String(), "<title>breach (91 days) : 2025-07-01 to 2025-12-27</title>"
+	got, want := svgOutput.String(), "<title>breach (91 days) : 2025-12-27 to 2026-01-06</title>"
 	if !strings.Contains(
 		got,
 		want,
@@ -157,3 +185,364 @@ func TestSVG(t *testing.T) {
 		t.Errorf("expected breach group title")
 	}
 }
+
+// TestSVGBreaches checks that a stripe is rendered for each breach
+func TestSVGBreaches(t *testing.T) {
+
+	tp := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	hols := []trips.Holiday{
+		{Start: tp("2023-01-01"), End: tp("2023-03-31")},
+		{Start: tp("2023-04-10"), End: tp("2023-04-12")},
+		{Start: tp("2023-10-01"), End: tp("2023-12-29")},
+		{Start: tp("2024-01-05"), End: tp("2024-01-06")},
+	}
+	trs, err := trips.Calculate(hols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(trs.Breaches), 2; got != want {
+		t.Fatalf("breaches got %d want %d", got, want)
+	}
+
+	var svgOutput strings.Builder
+	err = TripsAsSVG(trs, &svgOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Count(svgOutput.String(), "<title>breach"), 2; got != want {
+		t.Errorf("breach stripes got %d want %d", got, want)
+	}
+}
+
+// TestSVGRuleStripes checks that the breaches of each rule are rendered
+// on their own level
+func TestSVGRuleStripes(t *testing.T) {
+
+	tp := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	consecutive, err := trips.RuleByName("consecutive-90")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calendar, err := trips.RuleByName("calendar-year")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hols := []trips.Holiday{
+		{Start: tp("2023-01-01"), End: tp("2023-04-10")}, // 100 days
+	}
+	trs, err := trips.Calculate(hols, trips.WithRules(consecutive, calendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var svgOutput strings.Builder
+	err = TripsAsSVG(trs, &svgOutput, WithRuleStripes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := svgOutput.String()
+	for _, want := range []string{
+		"<title>breach (schengen 100 days) : 2023-04-01 to 2023-04-10</title>",
+		"<title>breach (consecutive-90 91 days) : 2023-04-01 to 2023-04-10</title>",
+		"calendar-year breach",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q", want)
+		}
+	}
+	if strings.Contains(output, "<title>breach (calendar-year") {
+		t.Error("unexpected calendar-year breach stripe")
+	}
+
+	// four stripe levels make each row of weeks taller
+	start, end := calendarDates(trs)
+	plain, err := newGrid(start, end, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	striped, err := newGrid(start, end, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := striped.height-plain.height, 2*stripePadding*plain.rows; got != want {
+		t.Errorf("height difference got %d want %d", got, want)
+	}
+}
+
+// TestSVGExemptions checks that exempt periods are rendered, clipped to
+// the dates of the trips
+func TestSVGExemptions(t *testing.T) {
+
+	tp := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	hols := []trips.Holiday{
+		{Start: tp("2023-01-01"), End: tp("2023-01-31")},
+		{Start: tp("2023-03-01"), End: tp("2023-03-31")},
+		{Start: tp("2023-01-20"), End: tp("2023-12-31"), Exempt: true},
+	}
+	trs, err := trips.Calculate(hols)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var svgOutput strings.Builder
+	err = TripsAsSVG(trs, &svgOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<title>exempt: 2023-01-20 to 2023-03-31</title>",
+		">exempt</text>",
+	} {
+		if !strings.Contains(svgOutput.String(), want) {
+			t.Errorf("output does not contain %q", want)
+		}
+	}
+}
+
+// TestSVGNoDaysCounted checks that trips without any days counted by
+// the rule, such as those outside the Schengen area, are rendered
+// without a longest window.
+func TestSVGNoDaysCounted(t *testing.T) {
+
+	hols := []trips.Holiday{
+		{Start: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC), Country: "IE"},
+	}
+	trs, err := trips.Calculate(hols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := trs.Window.DaysAway, 0; got != want {
+		t.Fatalf("window days away got %d want %d", got, want)
+	}
+
+	var svgOutput strings.Builder
+	if err := TripsAsSVG(trs, &svgOutput); err != nil {
+		t.Fatal(err)
+	}
+	if got := svgOutput.String(); !strings.Contains(got, "<title>holiday: 2023-01-01 to 2023-01-10</title>") || strings.Contains(got, "<title>longest window") {
+		t.Errorf("unexpected output %s", got)
+	}
+}
+
+func TestSVGForecast(t *testing.T) {
+
+	tp := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	trs, err := trips.Calculate([]trips.Holiday{{Start: tp("2023-01-01"), End: tp("2023-03-31")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var plain, svgOutput strings.Builder
+	if err := TripsAsSVG(trs, &plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := TripsAsSVG(trs, &svgOutput, WithForecast(200)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<title>forecast (exhausted, 0 days remaining) : 2023-04-01 to 2023-06-29</title>",
+		"<title>forecast (partial, 1 to 89 days remaining) : 2023-06-30 to 2023-09-26</title>",
+		"<title>forecast (full, 90 days remaining) : 2023-09-27 to 2023-10-17</title>",
+		">full allowance</text>",
+		">exhausted allowance</text>",
+		">2 Oct 2023</text>", // the grid extends to the horizon
+	} {
+		if !strings.Contains(svgOutput.String(), want) {
+			t.Errorf("output does not contain %q", want)
+		}
+	}
+	if strings.Contains(plain.String(), "forecast") {
+		t.Error("forecast rendered without WithForecast")
+	}
+
+	for _, horizon := range []int{0, maxForecast + 1} {
+		if err := TripsAsSVG(trs, io.Discard, WithForecast(horizon)); err == nil {
+			t.Errorf("expected error for forecast of %d days", horizon)
+		}
+	}
+}
+
+func TestSVGStripesAndWidth(t *testing.T) {
+
+	tp := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	hols := []trips.Holiday{
+		{Start: tp("2023-01-01"), End: tp("2023-04-10")}, // 100 days
+	}
+	calendar, err := trips.RuleByName("calendar-year")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trs, err := trips.Calculate(hols, trips.WithRules(calendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		options []Option
+		want    []string
+		notWant []string
+		isErr   bool
+	}{
+		{
+			name:    "default",
+			want:    []string{`<svg width="860"`, "<title>holiday: 2023-01-01", "<title>breach (100 days)"},
+			notWant: []string{"calendar-year breach"},
+		},
+		{
+			name:    "wide holidays only",
+			options: []Option{WithWidth(1720), WithStripes(HolidayStripes)},
+			want:    []string{`<svg width="1720"`, "<title>holiday: 2023-01-01"},
+			notWant: []string{"<title>breach", ">breach<"},
+		},
+		{
+			name:    "window and rules",
+			options: []Option{WithStripes(WindowStripes, RuleStripes)},
+			want:    []string{"<title>breach (100 days)", "<title>breach (schengen 100 days)", "calendar-year breach"},
+			notWant: []string{"<title>holiday"},
+		},
+		{
+			name:    "unknown stripe",
+			options: []Option{WithStripes("weekends")},
+			isErr:   true,
+		},
+		{
+			name:    "no stripes",
+			options: []Option{WithStripes()},
+			isErr:   true,
+		},
+		{
+			name:    "narrow",
+			options: []Option{WithWidth(10)},
+			isErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var svgOutput strings.Builder
+			err := TripsAsSVG(trs, &svgOutput, tt.options...)
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Fatalf("error got %v want error %t", err, want)
+			}
+			output := svgOutput.String()
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("output does not contain %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(output, notWant) {
+					t.Errorf("output unexpectedly contains %q", notWant)
+				}
+			}
+		})
+	}
+}
+
+// TestTeamAsSVG checks that the trips of each traveller are rendered on
+// a level of their own in a calendar spanning all the trips
+func TestTeamAsSVG(t *testing.T) {
+
+	tp := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	alice, err := trips.Calculate([]trips.Holiday{
+		{Start: tp("2023-01-01"), End: tp("2023-04-10")}, // 100 days
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := trips.Calculate([]trips.Holiday{
+		{Start: tp("2023-06-01"), End: tp("2023-06-14")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	team := []Traveller{
+		{Name: "Alice", Trips: alice},
+		{Name: "Bob", Trips: bob, Changed: bob.OriginalHolidays},
+	}
+
+	var svgOutput strings.Builder
+	err = TeamAsSVG(team, &svgOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := svgOutput.String()
+	for _, want := range []string{
+		"<title>holiday (Alice) : 2023-01-01 to 2023-04-10</title>",
+		"<title>breach (Alice 100 days) : 2023-04-01 to 2023-04-10</title>",
+		"<title>holiday (Bob) : 2023-06-01 to 2023-06-14</title>",
+		">Alice</text>",
+		">Bob</text>",
+		">breach</text>",
+		"<title>changed (Bob) : 2023-06-01 to 2023-06-14</title>",
+		">changed</text>",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q", want)
+		}
+	}
+
+	// the first traveller's stripes are a level above the second's
+	grid, err := newGrid(tp("2023-01-01"), tp("2023-06-14"), len(team))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := grid.getSegments(tp("2023-01-02"), tp("2023-01-02"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, fmt.Sprintf(`y1="%d"`, first[0].y1)) {
+		t.Errorf("output has no stripe at the first level, y %d", first[0].y1)
+	}
+
+	if err := TeamAsSVG(nil, &svgOutput); err == nil {
+		t.Error("expected an error for no travellers")
+	}
+	if err := TeamAsSVG([]Traveller{{Name: "Carol"}}, &svgOutput); err == nil {
+		t.Error("expected an error for a traveller without trips")
+	}
+}
@@ -0,0 +1,129 @@
+package trips
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// dayOf returns the date of t as a UTC midnight time, matching the
+// dates of holidays.
+func dayOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// DaysUsed returns the number of days away in the rule's assessment
+// period up to and including the reference date ref, such as the window
+// of WindowSize days ending on ref.
+func (trips *Trips) DaysUsed(ref time.Time) int {
+	rule, err := trips.currentRule()
+	if err != nil {
+		return 0
+	}
+	ref = dayOf(ref)
+	l := trips.countedLedger(rule)
+	return l.count(rule.PeriodStart(ref), ref)
+}
+
+// DaysRemaining returns the number of days of the MaxStay allowance
+// which remain usable on the reference date ref, being MaxStay less
+// the days away in the period up to ref. DaysRemaining is never less
+// than zero.
+func (trips *Trips) DaysRemaining(ref time.Time) int {
+	return max(trips.MaxStay-trips.DaysUsed(ref), 0)
+}
+
+// EarliestEntry returns the earliest date on or after the reference
+// date ref from which a stay of stay consecutive days (inclusive of the
+// entry and exit days) would not breach MaxStay in any window, taking
+// into account the holidays already recorded in trips, including any
+// planned after ref. The stay may not overlap a recorded holiday.
+func (trips *Trips) EarliestEntry(ref time.Time, stay int) (time.Time, error) {
+	rule, err := trips.currentRule()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if stay < 1 {
+		return time.Time{}, errors.New("stay must be at least one day")
+	}
+	if stay > trips.MaxStay {
+		return time.Time{}, fmt.Errorf("a stay of %d days is longer than the maximum stay of %d days", stay, trips.MaxStay)
+	}
+
+	ref = dayOf(ref)
+	history := trips.countedLedger(rule)
+	all := newLedger(trips.OriginalHolidays)
+	stayDuration := durationDays(stay - 1)
+
+	// once the period in which a stay starts no longer includes any
+	// recorded holiday, any stay no longer than MaxStay is permissible
+	last := ref
+	if len(all.away) > 0 && all.last().After(last) {
+		last = all.last()
+	}
+
+	// stays may not overlap any recorded holiday, including those not
+	// counted by the rule
+	for d := ref; ; d = d.Add(durationDays(1)) {
+		if all.count(d, d.Add(stayDuration)) == 0 {
+			l := history.clone()
+			trips.markStay(l, rule, d, d.Add(stayDuration))
+			if l.complies(d, d.Add(stayDuration), rule) {
+				return d, nil
+			}
+		}
+		if d.After(last) && rule.PeriodStart(d).After(last) {
+			break
+		}
+	}
+	return time.Time{}, fmt.Errorf("no entry date found for a stay of %d days", stay)
+}
+
+// Milestone is a date of note in the allowance of a set of trips.
+type Milestone struct {
+	Exhausted time.Time `json:"exhausted"` // the day the allowance was exhausted
+	SafeFrom  time.Time `json:"safeFrom"`  // the earliest day from which a return is safe
+}
+
+// Milestones returns the days on which the allowance of the rule was
+// exhausted while away, each with the earliest following day from which
+// it is safe to return for a day without breaching the rule.
+func (trips *Trips) Milestones() ([]Milestone, error) {
+	timeline, err := trips.Timeline(0)
+	if err != nil {
+		return nil, err
+	}
+	milestones := []Milestone{}
+	remaining := trips.MaxStay
+	for _, td := range timeline {
+		if td.DaysRemaining == 0 && remaining > 0 {
+			safe, err := trips.EarliestEntry(td.Date, 1)
+			if err != nil {
+				return nil, err
+			}
+			milestones = append(milestones, Milestone{Exhausted: td.Date, SafeFrom: safe})
+		}
+		remaining = td.DaysRemaining
+	}
+	return milestones, nil
+}
+
+// ResetDate returns the first day after the last trip on which no days
+// away are counted in the rule's assessment period, from which the full
+// MaxStay allowance is again available.
+func (trips *Trips) ResetDate() (time.Time, error) {
+	rule, err := trips.currentRule()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(trips.OriginalHolidays) < 1 {
+		return time.Time{}, errors.New("no holidays provided")
+	}
+	l := trips.countedLedger(rule)
+	d := trips.End.Add(durationDays(1))
+	for l.count(rule.PeriodStart(d), d) > 0 {
+		d = d.Add(durationDays(1))
+	}
+	return d, nil
+}
@@ -0,0 +1,230 @@
+package trips
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowance(t *testing.T) {
+
+	tp := func(s, e string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *h
+	}
+	day := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	// a 90 day trip using up the whole allowance
+	trips, err := Calculate([]Holiday{tp("2023-01-01", "2023-03-31")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remainingCases := []struct {
+		ref       string
+		used      int
+		remaining int
+	}{
+		{"2022-12-31", 0, 90},
+		{"2023-01-10", 10, 80},
+		{"2023-05-01", 90, 0},
+		{"2023-07-15", 74, 16},
+		{"2023-12-01", 0, 90},
+	}
+	for _, tc := range remainingCases {
+		t.Run("remaining-"+tc.ref, func(t *testing.T) {
+			if got, want := trips.DaysUsed(day(tc.ref)), tc.used; got != want {
+				t.Errorf("used got %d want %d", got, want)
+			}
+			if got, want := trips.DaysRemaining(day(tc.ref)), tc.remaining; got != want {
+				t.Errorf("remaining got %d want %d", got, want)
+			}
+		})
+	}
+
+	entryCases := []struct {
+		ref   string
+		stay  int
+		entry string
+		isErr bool
+	}{
+		{"2023-04-01", 1, "2023-06-30", false},
+		{"2023-04-01", 10, "2023-06-30", false},
+		{"2023-04-01", 90, "2023-06-30", false},
+		{"2023-08-01", 30, "2023-08-01", false},
+		{"2023-04-01", 91, "", true},
+		{"2023-04-01", 0, "", true},
+	}
+	for _, tc := range entryCases {
+		t.Run("entry-"+tc.ref, func(t *testing.T) {
+			entry, err := trips.EarliestEntry(day(tc.ref), tc.stay)
+			if err != nil && !tc.isErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err == nil && tc.isErr {
+				t.Fatal("expected error")
+			}
+			if tc.isErr {
+				return
+			}
+			if got, want := entry, day(tc.entry); !got.Equal(want) {
+				t.Errorf("entry got %s want %s", dayShortFmt(got), dayShortFmt(want))
+			}
+		})
+	}
+}
+
+// TestEarliestEntryFuture checks that planned future holidays are taken
+// into account when finding an entry date.
+func TestEarliestEntryFuture(t *testing.T) {
+
+	tp := func(s, e string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *h
+	}
+
+	calc, err := NewCalculator(WithWindowSize(10), WithMaxStay(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a planned 4 day trip from 10 January
+	trips, err := calc.Calculate([]Holiday{tp("2023-01-10", "2023-01-13")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(s string) time.Time {
+		return tp(s, s).Start
+	}
+
+	entryCases := []struct {
+		ref   string
+		stay  int
+		entry string
+	}{
+		// the stay from 1 to 2 January puts only 3 days in any window
+		{"2023-01-01", 2, "2023-01-01"},
+		// a stay from 5 January, or any later date up to 18 January,
+		// puts 6 days in a window, and the stay may not overlap the
+		// planned trip
+		{"2023-01-05", 2, "2023-01-19"},
+	}
+	for _, tc := range entryCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			entry, err := trips.EarliestEntry(day(tc.ref), tc.stay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := entry, day(tc.entry); !got.Equal(want) {
+				t.Errorf("entry got %s want %s", dayShortFmt(got), dayShortFmt(want))
+			}
+		})
+	}
+}
+
+func TestMilestones(t *testing.T) {
+
+	tp := func(s, e string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *h
+	}
+
+	calc, err := NewCalculator(WithWindowSize(10), WithMaxStay(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the 5 day allowance is exhausted on the last day of the first
+	// trip, and the second trip is too short to exhaust it again
+	trips, err := calc.Calculate([]Holiday{
+		tp("2023-01-10", "2023-01-14"),
+		tp("2023-02-01", "2023-02-02"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	milestones, err := trips.Milestones()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(milestones), 1; got != want {
+		t.Fatalf("milestones got %d want %d", got, want)
+	}
+	if got, want := dayShortFmt(milestones[0].Exhausted), "14/01/2023"; got != want {
+		t.Errorf("exhausted got %s want %s", got, want)
+	}
+	// a day away on 20 January puts 5 days in the window from 11 January
+	if got, want := dayShortFmt(milestones[0].SafeFrom), "20/01/2023"; got != want {
+		t.Errorf("safe from got %s want %s", got, want)
+	}
+}
+
+func TestResetDate(t *testing.T) {
+
+	tp := func(s, e string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *h
+	}
+
+	tests := []struct {
+		name  string
+		rule  Rule
+		trips []Holiday
+		reset string
+	}{
+		{
+			name:  "rolling window",
+			rule:  RollingRule{"test", 10, 5},
+			trips: []Holiday{tp("2023-01-10", "2023-01-14")},
+			// the window of 10 days ending on 24 January starts on 15 January
+			reset: "24/01/2023",
+		},
+		{
+			name:  "tax year",
+			rule:  mustRule(t, "uk-tax-year"),
+			trips: []Holiday{tp("2023-01-10", "2023-01-14")},
+			reset: "06/04/2023",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trips, err := Calculate(tt.trips, WithRule(tt.rule))
+			if err != nil {
+				t.Fatal(err)
+			}
+			reset, err := trips.ResetDate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := dayShortFmt(reset), tt.reset; got != want {
+				t.Errorf("reset got %s want %s", got, want)
+			}
+		})
+	}
+}
+
+// mustRule returns the registered rule named name.
+func mustRule(t *testing.T, name string) Rule {
+	t.Helper()
+	rule, err := RuleByName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
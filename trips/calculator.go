@@ -0,0 +1,227 @@
+package trips
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// DefaultWindowSize is the default window of days to calculate over
+	DefaultWindowSize int = 180
+	// DefaultMaxStay is the default longest allowed compound trip length
+	DefaultMaxStay int = 90
+	// DefaultRuleName is the name of the default rule
+	DefaultRuleName string = "schengen"
+)
+
+// defaultCalculator is the Calculator used by the package level
+// Calculate function.
+var defaultCalculator = mustCalculator(NewCalculator())
+
+// Calculator calculates trips according to a Rule. A Calculator is not
+// altered after it is made by NewCalculator, so its methods are safe for
+// concurrent use by multiple goroutines.
+type Calculator struct {
+	rule  Rule   // the rule to calculate with
+	rules []Rule // further rules to report results for
+	merge bool   // if overlapping trips are merged
+}
+
+// calculatorConfig holds the settings from which a Calculator is made.
+type calculatorConfig struct {
+	windowSize int    // size of window of days to search over
+	maxStay    int    // the maximum length of trips in window
+	ruleName   string // the name of the rolling rule
+	rolling    bool   // if any rolling rule settings were provided
+	rule       Rule   // a rule provided by WithRule
+	rules      []Rule // further rules provided by WithRules
+	merge      bool   // if overlapping trips are merged
+}
+
+// Option is a functional option for configuring a Calculator.
+type Option func(*calculatorConfig) error
+
+// WithWindowSize sets the window of days to calculate over for a
+// rolling rule.
+func WithWindowSize(days int) Option {
+	return func(c *calculatorConfig) error {
+		if days < 3 {
+			return errors.New("window size cannot be less than 3 days")
+		}
+		c.windowSize = days
+		c.rolling = true
+		return nil
+	}
+}
+
+// WithMaxStay sets the longest allowed compound trip length in days for
+// a rolling rule.
+func WithMaxStay(days int) Option {
+	return func(c *calculatorConfig) error {
+		if days < 2 {
+			return errors.New("maximum stay cannot be less than 2 days")
+		}
+		c.maxStay = days
+		c.rolling = true
+		return nil
+	}
+}
+
+// WithRuleName sets the name of a rolling rule, which is reported in
+// calculation results.
+func WithRuleName(name string) Option {
+	return func(c *calculatorConfig) error {
+		if name == "" {
+			return errors.New("rule name cannot be empty")
+		}
+		c.ruleName = name
+		c.rolling = true
+		return nil
+	}
+}
+
+// WithRule sets the Rule to calculate with, such as one returned by
+// RuleByName. WithRule cannot be used with the rolling rule options
+// WithWindowSize, WithMaxStay and WithRuleName.
+func WithRule(rule Rule) Option {
+	return func(c *calculatorConfig) error {
+		if rule == nil || rule.Name() == "" {
+			return errors.New("rule must have a name")
+		}
+		if rule.Limit() < 1 {
+			return fmt.Errorf("rule %s limit cannot be less than 1 day", rule.Name())
+		}
+		c.rule = rule
+		return nil
+	}
+}
+
+// WithRules sets further rules to evaluate over the same holidays as
+// the Calculator's rule, each of which is reported in Trips.Results
+// after the result for the Calculator's rule. Rules with the same name
+// as the Calculator's rule are not reported twice.
+func WithRules(rules ...Rule) Option {
+	return func(c *calculatorConfig) error {
+		for _, rule := range rules {
+			if rule == nil || rule.Name() == "" {
+				return errors.New("rule must have a name")
+			}
+			if rule.Limit() < 1 {
+				return fmt.Errorf("rule %s limit cannot be less than 1 day", rule.Name())
+			}
+		}
+		c.rules = append(c.rules, rules...)
+		return nil
+	}
+}
+
+// WithMergeOverlaps merges overlapping trips, including those sharing a
+// day, with MergeHolidays before calculating, rather than reporting the
+// overlaps as errors. Each merge is reported in Trips.Merges.
+func WithMergeOverlaps() Option {
+	return func(c *calculatorConfig) error {
+		c.merge = true
+		return nil
+	}
+}
+
+// NewCalculator returns a new Calculator, by default using the Schengen
+// rule of 90 days in any 180 day window counting only days spent in
+// Schengen member states, as modified by the provided options. The
+// rolling rule options make a RollingRule which counts every day away.
+func NewCalculator(options ...Option) (*Calculator, error) {
+	c := &calculatorConfig{
+		windowSize: DefaultWindowSize,
+		maxStay:    DefaultMaxStay,
+		ruleName:   DefaultRuleName,
+	}
+	for _, o := range options {
+		if err := o(c); err != nil {
+			return nil, err
+		}
+	}
+	if c.rule != nil {
+		if c.rolling {
+			return nil, errors.New("a rule cannot be used with rolling rule options")
+		}
+		return &Calculator{rule: c.rule, rules: c.rules, merge: c.merge}, nil
+	}
+	if !c.rolling {
+		return &Calculator{rule: defaultRule, rules: c.rules, merge: c.merge}, nil
+	}
+	rule, err := NewRollingRule(c.ruleName, c.windowSize, c.maxStay)
+	if err != nil {
+		return nil, err
+	}
+	return &Calculator{rule: rule, rules: c.rules, merge: c.merge}, nil
+}
+
+// mustCalculator panics if a Calculator could not be made.
+func mustCalculator(c *Calculator, err error) *Calculator {
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Rule reports the rule used by the Calculator.
+func (c *Calculator) Rule() Rule {
+	return c.rule
+}
+
+// MaxStay reports the longest allowed compound trip length in days.
+func (c *Calculator) MaxStay() int {
+	return c.rule.Limit()
+}
+
+// RuleName reports the name of the rule used by the Calculator.
+func (c *Calculator) RuleName() string {
+	return c.rule.Name()
+}
+
+// Calculate initialises a new Trips struct with the Calculator's rule
+// (setting out the windows over which to do the calculation and the
+// maximum length of compound holidays in each) and then sequentially
+// adds holidays, then runs the calculation, returning the resulting
+// Trips object and embedded window (with the longest DaysAway), and
+// error if any. Holidays are first merged if the Calculator was made
+// WithMergeOverlaps, and then checked with Validate, any problems being
+// returned as ValidationErrors. A RuleResult is reported in
+// Trips.Results for the Calculator's rule and each rule provided by
+// WithRules.
+func (c *Calculator) Calculate(hols []Holiday) (*Trips, error) {
+
+	// initialise Trips
+	trips := newTrips(c)
+
+	// add holidays after checking them for every problem
+	if len(hols) == 0 {
+		trips.Error = errors.New("no trips were provided to calculate")
+		return trips, trips.Error
+	}
+	if c.merge {
+		hols, trips.Merges = MergeHolidays(hols)
+	}
+	if err := Validate(hols); err != nil {
+		trips.Error = err
+		return trips, trips.Error
+	}
+	for _, h := range hols {
+		trips.Error = trips.addHoliday(h)
+		if trips.Error != nil {
+			return trips, trips.Error
+		}
+	}
+
+	// perform the calculation, which needs at least one trip other
+	// than an exempt period
+	if len(trips.OriginalHolidays) == 0 {
+		trips.Error = errors.New("no trips were provided to calculate, only exempt periods")
+		return trips, trips.Error
+	}
+	if _, trips.Error = trips.calculate(); trips.Error != nil {
+		return trips, trips.Error
+	}
+	trips.Results, trips.Error = trips.ruleResults(c.rules)
+	return trips, trips.Error
+}
@@ -0,0 +1,128 @@
+package trips
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewCalculator(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		options []Option
+		isErr   bool
+	}{
+		{"defaults", nil, false},
+		{"custom", []Option{WithWindowSize(40), WithMaxStay(35), WithRuleName("custom")}, false},
+		{"window too small", []Option{WithWindowSize(2)}, true},
+		{"stay too small", []Option{WithMaxStay(1)}, true},
+		{"stay greater than window", []Option{WithWindowSize(10), WithMaxStay(11)}, true},
+		{"empty rule name", []Option{WithRuleName("")}, true},
+		{"rule", []Option{WithRule(YearRule{"year", 10, time.April, 6})}, false},
+		{"rule with rolling options", []Option{WithRule(YearRule{"year", 10, time.April, 6}), WithMaxStay(3)}, true},
+		{"nil rule", []Option{WithRule(nil)}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewCalculator(tc.options...)
+			if err != nil && !tc.isErr {
+				t.Errorf("unexpected error %v", err)
+			}
+			if err == nil && tc.isErr {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	c, err := NewCalculator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.Rule().Description(), "90 days in any 180 day period"; got != want {
+		t.Errorf("rule description got %s want %s", got, want)
+	}
+	if got, want := c.MaxStay(), DefaultMaxStay; got != want {
+		t.Errorf("max stay got %d want %d", got, want)
+	}
+	if got, want := c.RuleName(), DefaultRuleName; got != want {
+		t.Errorf("rule name got %s want %s", got, want)
+	}
+}
+
+// TestCalculatorConcurrent runs calculators with different rules side
+// by side to check the results don't interfere with each other. Run
+// with -race to check for data races.
+func TestCalculatorConcurrent(t *testing.T) {
+
+	tp := func(s, e string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *h
+	}
+
+	hols := []Holiday{
+		tp("2023-01-01", "2023-01-01"),
+		tp("2023-01-06", "2023-01-07"),
+		tp("2023-01-11", "2023-01-12"),
+		tp("2023-01-15", "2023-01-15"),
+		tp("2023-01-21", "2023-01-22"),
+		tp("2023-01-24", "2023-01-25"),
+	}
+
+	breaching, err := NewCalculator(WithWindowSize(5), WithMaxStay(3), WithRuleName("breaching"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compliant, err := NewCalculator(WithWindowSize(5), WithMaxStay(4), WithRuleName("compliant"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			calc, wantBreach, wantRule := compliant, false, "compliant"
+			if i%2 == 0 {
+				calc, wantBreach, wantRule = breaching, true, "breaching"
+			}
+			trips, err := calc.Calculate(hols)
+			if err != nil {
+				t.Errorf("calculation error %v", err)
+				return
+			}
+			if got, want := trips.Breach, wantBreach; got != want {
+				t.Errorf("%d: breach got %t want %t", i, got, want)
+			}
+			if got, want := trips.Rule, wantRule; got != want {
+				t.Errorf("%d: rule got %s want %s", i, got, want)
+			}
+			if got, want := trips.DaysAway, 4; got != want {
+				t.Errorf("%d: days away got %d want %d", i, got, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestCalculateExemptOnly checks that holidays with only exempt periods
+// report an error in Trips.Error.
+func TestCalculateExemptOnly(t *testing.T) {
+	h, err := newHolidayFromStr("2023-01-01", "2023-01-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Exempt = true
+	trips, err := Calculate([]Holiday{*h})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if trips.Error == nil || trips.Error.Error() != err.Error() {
+		t.Errorf("trips error got %v want %v", trips.Error, err)
+	}
+}
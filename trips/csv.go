@@ -0,0 +1,223 @@
+package trips
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// DefaultCSVDateFormats are the date layouts tried in order by
+// HolidaysCSVDecoder when no others are set with WithCSVDateFormats.
+// Dates with slashes are read day first.
+var DefaultCSVDateFormats = []string{
+	"2006-01-02",
+	"02/01/2006",
+	"2/1/2006",
+	"2006/01/02",
+	"2 Jan 2006",
+	"2 January 2006",
+	time.RFC3339,
+}
+
+// csvConfig holds the settings for HolidaysCSVDecoder.
+type csvConfig struct {
+	start, end  string   // names of the date columns
+	country     string   // name of the country column, if any
+	countrySet  bool     // if the country column must be present
+	tripType    string   // name of the trip type column, if any
+	tripTypeSet bool     // if the trip type column must be present
+	dateFormats []string // date layouts to try in order
+	comma       rune     // the field delimiter
+}
+
+// CSVOption is a functional option for configuring HolidaysCSVDecoder.
+type CSVOption func(*csvConfig) error
+
+// WithCSVColumns sets the names of the start and end date columns, which
+// are "start" and "end" by default.
+func WithCSVColumns(start, end string) CSVOption {
+	return func(c *csvConfig) error {
+		if strings.TrimSpace(start) == "" || strings.TrimSpace(end) == "" {
+			return errors.New("csv date column names cannot be empty")
+		}
+		c.start, c.end = start, end
+		return nil
+	}
+}
+
+// WithCSVCountryColumn sets the name of the column of country codes,
+// which must then be present. By default a "country" column is read if
+// present.
+func WithCSVCountryColumn(name string) CSVOption {
+	return func(c *csvConfig) error {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("csv country column name cannot be empty")
+		}
+		c.country, c.countrySet = name, true
+		return nil
+	}
+}
+
+// WithCSVTypeColumn sets the name of the column of trip types, "trip"
+// or "exempt", which must then be present. By default a "type" column is
+// read if present.
+func WithCSVTypeColumn(name string) CSVOption {
+	return func(c *csvConfig) error {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("csv type column name cannot be empty")
+		}
+		c.tripType, c.tripTypeSet = name, true
+		return nil
+	}
+}
+
+// WithCSVDateFormats sets the date layouts, in the form used by
+// time.Parse, to try in order in place of DefaultCSVDateFormats.
+func WithCSVDateFormats(layouts ...string) CSVOption {
+	return func(c *csvConfig) error {
+		if len(layouts) == 0 {
+			return errors.New("no csv date formats were provided")
+		}
+		c.dateFormats = layouts
+		return nil
+	}
+}
+
+// WithCSVComma sets the field delimiter, which is a comma by default.
+func WithCSVComma(r rune) CSVOption {
+	return func(c *csvConfig) error {
+		if r == 0 || r == '"' || r == '\r' || r == '\n' {
+			return fmt.Errorf("invalid csv delimiter %q", r)
+		}
+		c.comma = r
+		return nil
+	}
+}
+
+// parseDate parses s with each of the date layouts in turn, returning
+// the date at midnight UTC, or a zero time if s is empty.
+func (c *csvConfig) parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil // reported by Validate
+	}
+	for _, layout := range c.dateFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date %q not in a known format", s)
+}
+
+// HolidaysCSVDecoder decodes a set of holidays from csv with a header
+// row naming the start and end date columns, and optionally country and
+// trip type columns, such as
+//
+//	start,end,country
+//	2024-01-01,2024-01-10,FR
+//
+// Column names are matched ignoring case and surrounding space, other
+// columns are ignored, as are empty rows. The decoded holidays are
+// checked with Validate, and returned with any ValidationErrors.
+func HolidaysCSVDecoder(input []byte, options ...CSVOption) ([]Holiday, error) {
+	c := &csvConfig{
+		start:       "start",
+		end:         "end",
+		country:     "country",
+		tripType:    "type",
+		dateFormats: DefaultCSVDateFormats,
+		comma:       ',',
+	}
+	for _, o := range options {
+		if err := o(c); err != nil {
+			return nil, err
+		}
+	}
+
+	// drop any byte order mark added by spreadsheet exports
+	input = bytes.TrimPrefix(input, []byte("\ufeff"))
+	r := csv.NewReader(bytes.NewReader(input))
+	r.Comma = c.comma
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv has no header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv reading error: %w", err)
+	}
+
+	// find the index of each named column
+	column := func(name string) int {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), strings.TrimSpace(name)) {
+				return i
+			}
+		}
+		return -1
+	}
+	startCol, endCol := column(c.start), column(c.end)
+	countryCol, typeCol := column(c.country), column(c.tripType)
+	switch {
+	case startCol < 0:
+		return nil, fmt.Errorf("csv start column %q not found", c.start)
+	case endCol < 0:
+		return nil, fmt.Errorf("csv end column %q not found", c.end)
+	case c.countrySet && countryCol < 0:
+		return nil, fmt.Errorf("csv country column %q not found", c.country)
+	case c.tripTypeSet && typeCol < 0:
+		return nil, fmt.Errorf("csv type column %q not found", c.tripType)
+	}
+
+	// field returns the field of record at index i, if any
+	field := func(record []string, i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	hols := []Holiday{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return hols, fmt.Errorf("csv reading error: %w", err)
+		}
+		row, _ := r.FieldPos(0)
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		h := Holiday{}
+		h.Start, err = c.parseDate(field(record, startCol))
+		if err != nil {
+			return hols, fmt.Errorf("row %d: start %w", row, err)
+		}
+		h.End, err = c.parseDate(field(record, endCol))
+		if err != nil {
+			return hols, fmt.Errorf("row %d: end %w", row, err)
+		}
+		h.Country, err = countryCode(field(record, countryCol))
+		if err != nil {
+			return hols, fmt.Errorf("row %d: %w", row, err)
+		}
+		h.Exempt, err = exemptType(field(record, typeCol))
+		if err != nil {
+			return hols, fmt.Errorf("row %d: %w", row, err)
+		}
+		h.Duration = h.days()
+		hols = append(hols, h)
+	}
+	if len(hols) == 0 {
+		return hols, errors.New("csv has no trips")
+	}
+	return hols, Validate(hols)
+}
@@ -0,0 +1,100 @@
+package trips
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHolidaysCSVDecoder(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		input   string
+		options []CSVOption
+		trips   int
+		first   string // first holiday as a string
+		isErr   bool
+	}{
+		{
+			name:  "defaults",
+			input: "start,end\n2024-01-01,2024-01-10\n2024-02-01,2024-02-02\n",
+			trips: 2,
+			first: "01/01/2024 to 10/01/2024 (10 days)",
+		},
+		{
+			name:  "mixed formats, country, type and other columns",
+			input: "\ufeffEmployee, Start ,End,Country,Type\nA N Other,01/02/2024,2024-02-05T09:00:00Z,fr,trip\n\nA N Other,3 Mar 2024,5/3/2024,,exempt\n",
+			trips: 2,
+			first: "01/02/2024 to 05/02/2024 (5 days) FR",
+		},
+		{
+			name:    "configured columns, formats and delimiter",
+			input:   "Departed;Returned;Destination\n01/31/2024;02/02/2024;ES\n",
+			options: []CSVOption{WithCSVColumns("departed", "returned"), WithCSVCountryColumn("destination"), WithCSVDateFormats("01/02/2006"), WithCSVComma(';')},
+			trips:   1,
+			first:   "31/01/2024 to 02/02/2024 (3 days) ES",
+		},
+		{
+			name:    "missing country column",
+			input:   "start,end\n2024-01-01,2024-01-10\n",
+			options: []CSVOption{WithCSVCountryColumn("destination")},
+			isErr:   true,
+		},
+		{
+			name:  "missing start column",
+			input: "from,end\n2024-01-01,2024-01-10\n",
+			isErr: true,
+		},
+		{
+			name:  "unknown date format",
+			input: "start,end\n2024.01.01,2024-01-10\n",
+			isErr: true,
+		},
+		{
+			name:  "bad country",
+			input: "start,end,country\n2024-01-01,2024-01-10,France\n",
+			isErr: true,
+		},
+		{
+			name:  "no trips",
+			input: "start,end\n",
+			isErr: true,
+		},
+		{
+			name:  "empty",
+			input: "",
+			isErr: true,
+		},
+		{
+			name:    "bad delimiter",
+			input:   "start,end\n2024-01-01,2024-01-10\n",
+			options: []CSVOption{WithCSVComma('"')},
+			isErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hols, err := HolidaysCSVDecoder([]byte(tt.input), tt.options...)
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Fatalf("error got %v want error %t", err, want)
+			}
+			if err != nil {
+				return
+			}
+			if got, want := len(hols), tt.trips; got != want {
+				t.Fatalf("trips got %d want %d", got, want)
+			}
+			if got, want := hols[0].String(), tt.first; got != want {
+				t.Errorf("first trip got %q want %q", got, want)
+			}
+		})
+	}
+
+	// validation problems are reported with the row numbering of Validate
+	_, err := HolidaysCSVDecoder([]byte("start,end\n2024-01-01,2024-01-10\n2024-01-05,\n"))
+	var missing MissingDateError
+	if !errors.As(err, &missing) || missing.Index != 1 {
+		t.Errorf("expected missing end date for trip 2, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package trips
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HolidayError is implemented by errors describing a problem with one
+// or more holidays, reporting the indices of the offending holidays in
+// the order they were provided.
+type HolidayError interface {
+	error
+	Kind() string   // the kind of problem, such as "overlap"
+	Indices() []int // the indices of the offending holidays
+}
+
+// MissingDateError reports a holiday without a start or end date.
+type MissingDateError struct {
+	Index int    // index of the holiday
+	Field string // "start" or "end"
+}
+
+// Error describes the missing date, numbering holidays from 1.
+func (e MissingDateError) Error() string {
+	return fmt.Sprintf("trip %d: %s date not set", e.Index+1, e.Field)
+}
+
+// Kind returns "missing date".
+func (e MissingDateError) Kind() string { return "missing date" }
+
+// Indices returns the index of the holiday.
+func (e MissingDateError) Indices() []int { return []int{e.Index} }
+
+// ReversedDatesError reports a holiday with an end date before its start
+// date.
+type ReversedDatesError struct {
+	Index      int       // index of the holiday
+	Start, End time.Time // the dates of the holiday
+}
+
+// Error describes the reversed dates, numbering holidays from 1.
+func (e ReversedDatesError) Error() string {
+	return fmt.Sprintf("trip %d: start date %s after %s", e.Index+1, dayShortFmt(e.Start), dayShortFmt(e.End))
+}
+
+// Kind returns "reversed dates".
+func (e ReversedDatesError) Kind() string { return "reversed dates" }
+
+// Indices returns the index of the holiday.
+func (e ReversedDatesError) Indices() []int { return []int{e.Index} }
+
+// OverlapError reports a holiday which overlaps an earlier holiday.
+type OverlapError struct {
+	Index        int     // index of the holiday
+	Other        int     // index of the holiday it overlaps
+	Holiday      Holiday // the holiday
+	OtherHoliday Holiday // the holiday it overlaps
+}
+
+// Error describes the overlap, numbering holidays from 1.
+func (e OverlapError) Error() string {
+	return fmt.Sprintf(
+		"trip %d %s to %s overlaps with trip %d %s to %s",
+		e.Index+1, dayShortFmt(e.Holiday.Start), dayShortFmt(e.Holiday.End),
+		e.Other+1, dayShortFmt(e.OtherHoliday.Start), dayShortFmt(e.OtherHoliday.End),
+	)
+}
+
+// Kind returns "overlap".
+func (e OverlapError) Kind() string { return "overlap" }
+
+// Indices returns the indices of both holidays, in order.
+func (e OverlapError) Indices() []int {
+	return []int{min(e.Index, e.Other), max(e.Index, e.Other)}
+}
+
+// ValidationErrors collects every problem found with a set of holidays.
+type ValidationErrors []HolidayError
+
+// Error joins the descriptions of each problem.
+func (v ValidationErrors) Error() string {
+	s := make([]string, len(v))
+	for i, e := range v {
+		s[i] = e.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// Unwrap returns each problem for use with errors.Is and errors.As.
+func (v ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(v))
+	for i, e := range v {
+		errs[i] = e
+	}
+	return errs
+}
+
+// Indices returns the sorted indices of every offending holiday.
+func (v ValidationErrors) Indices() []int {
+	indices := []int{}
+	for _, e := range v {
+		indices = append(indices, e.Indices()...)
+	}
+	slices.Sort(indices)
+	return slices.Compact(indices)
+}
+
+// MarshalJSON encodes the problems as an array of objects with the
+// kind, holiday indices and description of each problem.
+func (v ValidationErrors) MarshalJSON() ([]byte, error) {
+	type jsonError struct {
+		Kind    string `json:"kind"`
+		Indices []int  `json:"indices"`
+		Message string `json:"message"`
+	}
+	errs := make([]jsonError, len(v))
+	for i, e := range v {
+		errs[i] = jsonError{e.Kind(), e.Indices(), e.Error()}
+	}
+	return json.Marshal(errs)
+}
+
+// Validate checks every holiday in hols for missing and reversed dates,
+// and every trip for overlaps with other trips, returning each problem
+// found as ValidationErrors, or nil if there are none. Exempt periods
+// may overlap trips and each other.
+func Validate(hols []Holiday) error {
+	errs := ValidationErrors{}
+
+	// dated holds the indices of trips with valid dates
+	dated := []int{}
+	for i, h := range hols {
+		switch {
+		case h.Start.IsZero():
+			errs = append(errs, MissingDateError{i, "start"})
+		case h.End.IsZero():
+			errs = append(errs, MissingDateError{i, "end"})
+		case h.End.Before(h.Start):
+			errs = append(errs, ReversedDatesError{i, h.Start, h.End})
+		case !h.Exempt:
+			dated = append(dated, i)
+		}
+	}
+
+	// sweep through the trips by start date, reporting each trip which
+	// starts on or before the latest end of the trips before it
+	sort.SliceStable(dated, func(i, j int) bool {
+		return hols[dated[i]].Start.Before(hols[dated[j]].Start)
+	})
+	latest := -1
+	for _, i := range dated {
+		if latest >= 0 && !hols[i].Start.After(hols[latest].End) {
+			errs = append(errs, OverlapError{i, latest, hols[i], hols[latest]})
+		}
+		if latest < 0 || hols[i].End.After(hols[latest].End) {
+			latest = i
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		return slices.Max(errs[i].Indices()) < slices.Max(errs[j].Indices())
+	})
+	return errs
+}
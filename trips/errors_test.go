@@ -0,0 +1,100 @@
+package trips
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+
+	vals, err := url.ParseQuery(
+		"Start=2024-01-01&End=2024-01-10&" + // 0
+			"Start=2024-01-05&End=2024-01-12&" + // 1 overlaps 0
+			"Start=2024-02-10&End=2024-02-01&" + // 2 reversed
+			"Start=&End=2024-03-01&" + // 3 no start
+			"Start=2024-01-12&End=2024-01-14", // 4 overlaps 1
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = HolidaysURLDecoder(vals)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	var ve ValidationErrors
+	if !errors.As(err, &ve) {
+		t.Fatalf("expected ValidationErrors, got %T", err)
+	}
+	if got, want := len(ve), 4; got != want {
+		t.Fatalf("errors got %d want %d: %v", got, want, ve)
+	}
+	if got, want := ve.Indices(), []int{0, 1, 2, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("indices got %v want %v", got, want)
+	}
+
+	var overlap OverlapError
+	if !errors.As(err, &overlap) {
+		t.Fatal("expected an OverlapError")
+	}
+	if got, want := overlap.Indices(), []int{0, 1}; !slices.Equal(got, want) {
+		t.Errorf("overlap indices got %v want %v", got, want)
+	}
+	if got, want := overlap.Error(), "trip 2 05/01/2024 to 12/01/2024 overlaps with trip 1 01/01/2024 to 10/01/2024"; got != want {
+		t.Errorf("overlap error got %q want %q", got, want)
+	}
+
+	var reversed ReversedDatesError
+	if !errors.As(err, &reversed) || reversed.Index != 2 {
+		t.Errorf("expected ReversedDatesError for trip 2, got %v", reversed)
+	}
+	var missing MissingDateError
+	if !errors.As(err, &missing) || missing.Index != 3 || missing.Field != "start" {
+		t.Errorf("expected MissingDateError for trip 3, got %v", missing)
+	}
+
+	j, err := json.Marshal(ve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []struct {
+		Kind    string `json:"kind"`
+		Indices []int  `json:"indices"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(j, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded[0].Kind, "overlap"; got != want {
+		t.Errorf("first kind got %s want %s", got, want)
+	}
+	if got, want := decoded[3].Indices, []int{1, 4}; !slices.Equal(got, want) {
+		t.Errorf("last indices got %v want %v", got, want)
+	}
+
+	// exempt periods may overlap trips
+	vals, err = url.ParseQuery("Start=2024-01-01&End=2024-01-10&Type=trip&Start=2024-01-05&End=2024-03-01&Type=exempt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = HolidaysURLDecoder(vals); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// the calculator reports every problem
+	hols := []Holiday{
+		{Start: overlap.Holiday.Start, End: overlap.Holiday.End},
+		{Start: overlap.OtherHoliday.Start, End: overlap.OtherHoliday.End},
+		{Start: reversed.Start, End: reversed.End},
+	}
+	trips, err := Calculate(hols)
+	if err == nil {
+		t.Fatal("expected calculation error")
+	}
+	if !errors.As(trips.Error, &ve) || len(ve) != 2 {
+		t.Errorf("expected two validation errors, got %v", trips.Error)
+	}
+}
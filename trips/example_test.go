@@ -8,10 +8,6 @@ import (
 
 func Example() {
 
-	// package variables
-	WindowMaxDays = 180      // maximum window of days to calculate over
-	CompoundStayMaxDays = 90 // longest allowed compound trip length in days
-
 	// fail immediately on error
 	fe := func(err error) {
 		if err != nil {
@@ -19,6 +15,13 @@ func Example() {
 		}
 	}
 
+	// make a calculator; these options are the defaults
+	calculator, err := NewCalculator(
+		WithWindowSize(180), // maximum window of days to calculate over
+		WithMaxStay(90),     // longest allowed compound trip length in days
+	)
+	fe(err)
+
 	// add trips by url
 	url, _ := url.ParseRequestURI(
 		"http://test.com/?" +
@@ -28,7 +31,7 @@ func Example() {
 			"Start=2023-07-01&End=2023-07-30&" +
 			"Start=2024-06-10&End=2024-06-14",
 	)
-	_, err := HolidaysURLDecoder(url.Query()) // replace _ with holidays
+	_, err = HolidaysURLDecoder(url.Query()) // replace _ with holidays
 	fe(err)
 
 	// or add trips by json
@@ -43,7 +46,7 @@ func Example() {
 	fe(err)
 
 	// calculate
-	trips, err := Calculate(holidays)
+	trips, err := calculator.Calculate(holidays)
 	fe(err)
 
 	// show whether or not trips breach, the maximum compound days away,
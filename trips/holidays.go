@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -17,14 +18,27 @@ import (
 // (when Start and End are the same date). A holiday End date may not be
 // before its Start.
 //
+// A Holiday may record the ISO 3166-1 alpha-2 code of the destination
+// Country, such as "FR", so that days spent in countries which were not
+// Schengen members are not counted. Days away in a Holiday without a
+// Country are always counted.
+//
+// An Exempt Holiday describes an exempt period rather than a trip, such
+// as a period covered by a residence permit or national long-stay (D)
+// visa, during which days away are not counted by the Schengen rule.
+// Exempt periods may overlap trips.
+//
 // The Holiday struct is also used to describe partial holidays which
 // overlap the holiday under consideration for any calculation window in
 // Trips.calculate.
 type Holiday struct {
 	Start          time.Time `json:"start"`                                // start date
 	End            time.Time `json:"end"`                                  // end date
+	Country        string    `json:"country,omitempty" form:",omitempty"`  // destination country code
+	Exempt         bool      `json:"exempt,omitempty" form:",omitempty"`   // if an exempt period
 	Duration       int       `json:"duration,omitempty" form:",omitempty"` // duration in days
 	PartialHoliday *Holiday  `json:"overlap,omitempty"`                    // pointer to a partial holiday
+	Legs           []Holiday `json:"legs,omitempty" form:"-"`              // countries of a merged trip
 }
 
 // newHoliday returns a new Holiday from two dates (time.Time values)
@@ -46,6 +60,32 @@ func newHoliday(s, e time.Time) (*Holiday, error) {
 	return h, nil
 }
 
+// countryCode checks and returns the upper case version of an ISO 3166-1
+// alpha-2 country code, which may be empty.
+func countryCode(c string) (string, error) {
+	c = strings.ToUpper(strings.TrimSpace(c))
+	if c == "" {
+		return c, nil
+	}
+	if len(c) != 2 || c[0] < 'A' || c[0] > 'Z' || c[1] < 'A' || c[1] > 'Z' {
+		return "", fmt.Errorf("invalid country code %q", c)
+	}
+	return c, nil
+}
+
+// holidayTypes are the permissible values of the Type of a holiday in
+// url and json input, and if each is exempt. An empty Type is a trip.
+var holidayTypes = map[string]bool{"": false, "trip": false, "exempt": true}
+
+// exemptType returns if the provided holiday Type is an exempt period.
+func exemptType(t string) (bool, error) {
+	exempt, ok := holidayTypes[strings.ToLower(strings.TrimSpace(t))]
+	if !ok {
+		return false, fmt.Errorf("invalid type %q, expected trip or exempt", t)
+	}
+	return exempt, nil
+}
+
 // newHoliday returns a new Holiday from two date strings
 func newHolidayFromStr(s, e string) (*Holiday, error) {
 	h := new(Holiday)
@@ -67,14 +107,20 @@ func newHolidayFromStr(s, e string) (*Holiday, error) {
 	return newHoliday(st, et)
 }
 
-// HolidaysURLDecoder decodes a set of holidays provided as a URL.Query
+// HolidaysURLDecoder decodes a set of holidays provided as a URL.Query.
+// A country code may be provided for each holiday with a Country
+// parameter, which may be empty, and the type of each holiday with a Type
+// parameter of "trip" or "exempt". The decoded holidays are checked with
+// Validate, and returned with any ValidationErrors.
 func HolidaysURLDecoder(input url.Values) ([]Holiday, error) {
 
 	// holidaysFromURL is a struct suitable for decoding parameters provided
 	// in a url eg `?Start=2022-12-18&End=2023-01-07&Start=2023-02-10&End=2023-02-15`
 	type holidaysFromURL struct {
-		Start []time.Time
-		End   []time.Time
+		Start   []time.Time
+		End     []time.Time
+		Country []string
+		Type    []string
 	}
 
 	holsByURL := holidaysFromURL{}
@@ -82,6 +128,9 @@ func HolidaysURLDecoder(input url.Values) ([]Holiday, error) {
 
 	decoder := form.NewDecoder()
 	decoder.RegisterCustomTypeFunc(func(vals []string) (interface{}, error) {
+		if vals[0] == "" {
+			return time.Time{}, nil // reported by Validate
+		}
 		return time.Parse("2006-01-02", vals[0])
 	}, time.Time{})
 
@@ -95,25 +144,45 @@ func HolidaysURLDecoder(input url.Values) ([]Holiday, error) {
 	if len(holsByURL.Start) != len(holsByURL.End) {
 		return hols, errors.New("incorrect number of url arguments")
 	}
+	if len(holsByURL.Country) > 0 && len(holsByURL.Country) != len(holsByURL.Start) {
+		return hols, errors.New("incorrect number of country url arguments")
+	}
+	if len(holsByURL.Type) > 0 && len(holsByURL.Type) != len(holsByURL.Start) {
+		return hols, errors.New("incorrect number of type url arguments")
+	}
 	for i := 0; i < len(holsByURL.Start); i++ {
-		h, err := newHoliday(holsByURL.Start[i], holsByURL.End[i])
-		if err != nil {
-			return hols, err
+		h := &Holiday{Start: holsByURL.Start[i], End: holsByURL.End[i]}
+		h.Duration = h.days()
+		if len(holsByURL.Country) > 0 {
+			h.Country, err = countryCode(holsByURL.Country[i])
+			if err != nil {
+				return hols, err
+			}
+		}
+		if len(holsByURL.Type) > 0 {
+			h.Exempt, err = exemptType(holsByURL.Type[i])
+			if err != nil {
+				return hols, err
+			}
 		}
 		hols = append(hols, *h)
 	}
-	return hols, err
+	return hols, Validate(hols)
 }
 
-// HolidaysJSONDecoder decodes a set of holidays provided as JSON
+// HolidaysJSONDecoder decodes a set of holidays provided as JSON. The
+// decoded holidays are checked with Validate, and returned with any
+// ValidationErrors.
 func HolidaysJSONDecoder(input []byte) ([]Holiday, error) {
 
 	var hols []Holiday
 	// internal struct to convert from 2006-01-02 values by first
 	// converting to string
 	type jsonHoliday struct {
-		Start string
-		End   string
+		Start   string
+		End     string
+		Country string
+		Type    string
 	}
 	var jsonHols []jsonHoliday
 	err := json.Unmarshal(input, &jsonHols)
@@ -123,16 +192,39 @@ func HolidaysJSONDecoder(input []byte) ([]Holiday, error) {
 	if len(jsonHols) < 1 {
 		return hols, nil
 	}
+	// dateOrZero parses a date, leaving a missing date to be reported
+	// by Validate
+	dateOrZero := func(s string) (time.Time, error) {
+		if s == "" {
+			return time.Time{}, nil
+		}
+		return time.Parse("2006-01-02", s)
+	}
+
 	// make Holiday objects from each jsonHoliday in the slice
 	for _, j := range jsonHols {
 
-		hol, err := newHolidayFromStr(j.Start, j.End)
+		hol := &Holiday{}
+		hol.Start, err = dateOrZero(j.Start)
+		if err != nil {
+			return hols, err
+		}
+		hol.End, err = dateOrZero(j.End)
+		if err != nil {
+			return hols, err
+		}
+		hol.Duration = hol.days()
+		hol.Country, err = countryCode(j.Country)
+		if err != nil {
+			return hols, err
+		}
+		hol.Exempt, err = exemptType(j.Type)
 		if err != nil {
 			return hols, err
 		}
 		hols = append(hols, *hol)
 	}
-	return hols, nil
+	return hols, Validate(hols)
 }
 
 // String returns a string representation of a holiday
@@ -140,6 +232,12 @@ func (h Holiday) String() string {
 	result := ""
 	tpl := "%s to %s (%d days)"
 	result = fmt.Sprintf(tpl, dayShortFmt(h.Start), dayShortFmt(h.End), h.Duration)
+	if h.Country != "" {
+		result += " " + h.Country
+	}
+	if h.Exempt {
+		result += " exempt"
+	}
 	if h.PartialHoliday != nil {
 		p := *h.PartialHoliday
 		tpl = " [overlap %d days]"
@@ -148,14 +246,21 @@ func (h Holiday) String() string {
 	return result
 }
 
+// Type returns "exempt" for an exempt period, otherwise "trip".
+func (h Holiday) Type() string {
+	if h.Exempt {
+		return "exempt"
+	}
+	return "trip"
+}
+
 // days returns the number of inclusive days between the start and end
 // dates of a holiday
 func (h Holiday) days() int {
-	days := 0
-	for d := h.Start; !d.After(h.End); d = d.Add(durationDays(1)) {
-		days++
+	if h.End.Before(h.Start) {
+		return 0
 	}
-	return days
+	return int(h.End.Sub(h.Start)/durationDays(1)) + 1
 }
 
 // overlaps returns a pointer to a partial or full holiday if there is
@@ -188,7 +293,9 @@ func (h Holiday) overlaps(start, end time.Time) *Holiday {
 }
 
 // HolidaysURLEncode url encodes a slice of Holiday ordered by holiday rather
-// than `net/url.Encode`'s sort by key
+// than `net/url.Encode`'s sort by key. If any holiday has a Country, a
+// Country parameter is encoded for every holiday, and similarly a Type
+// parameter if any holiday is Exempt.
 func HolidaysURLEncode(hols []Holiday) string {
 	if len(hols) < 1 {
 		return ""
@@ -196,6 +303,12 @@ func HolidaysURLEncode(hols []Holiday) string {
 	sort.SliceStable(hols, func(i, j int) bool {
 		return hols[i].Start.Before(hols[j].Start)
 	})
+	withCountry := slices.ContainsFunc(hols, func(h Holiday) bool {
+		return h.Country != ""
+	})
+	withType := slices.ContainsFunc(hols, func(h Holiday) bool {
+		return h.Exempt
+	})
 	u := ""
 	counter := 0
 	tpl := "Start=%s&End=%s"
@@ -205,6 +318,12 @@ func HolidaysURLEncode(hols []Holiday) string {
 			t = "&" + t
 		}
 		u += fmt.Sprintf(t, h.Start.Format("2006-01-02"), h.End.Format("2006-01-02"))
+		if withCountry {
+			u += "&Country=" + url.QueryEscape(h.Country)
+		}
+		if withType {
+			u += "&Type=" + h.Type()
+		}
 		counter++
 	}
 	return strings.TrimRight(u, "&")
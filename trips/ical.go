@@ -0,0 +1,394 @@
+package trips
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// icsKindProperty is the non-standard property recording the kind of
+// each event written by TripsAsICS and TripsAsICSFeed, so that
+// HolidaysICSDecoder can read back trips and exempt periods and skip
+// the other events.
+const icsKindProperty = "X-TIMEAWAY-KIND"
+
+// icsCountryProperty is the non-standard property recording the country
+// of a trip written by TripsAsICS and TripsAsICSFeed.
+const icsCountryProperty = "X-TIMEAWAY-COUNTRY"
+
+// icsStamp provides the DTSTAMP of exported events, which may be
+// swapped out for testing.
+var icsStamp func() time.Time = time.Now
+
+// icsConfig holds the settings for HolidaysICSDecoder.
+type icsConfig struct {
+	inclusiveEnd bool // if all-day DTEND dates are the last day away
+}
+
+// ICSOption is a functional option for configuring HolidaysICSDecoder.
+type ICSOption func(*icsConfig) error
+
+// WithICSInclusiveEnd treats the DTEND date of all-day events as the
+// last day of the trip, as written by some calendar applications, rather
+// than the day after the trip as set out in RFC 5545.
+func WithICSInclusiveEnd() ICSOption {
+	return func(c *icsConfig) error {
+		c.inclusiveEnd = true
+		return nil
+	}
+}
+
+// icsProperty is a content line of an iCalendar file, such as
+// "DTSTART;VALUE=DATE:20240101".
+type icsProperty struct {
+	name   string            // upper case property name
+	params map[string]string // upper case parameter names and values
+	value  string
+}
+
+// parseICSLine parses an unfolded content line.
+func parseICSLine(line string) (icsProperty, error) {
+	p := icsProperty{params: map[string]string{}}
+	nameParams, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return p, fmt.Errorf("invalid ics line %q", line)
+	}
+	parts := strings.Split(nameParams, ";")
+	p.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		k, v, _ := strings.Cut(param, "=")
+		p.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	p.value = value
+	return p, nil
+}
+
+// icsLines returns the unfolded content lines of an iCalendar file.
+func icsLines(input []byte) ([]string, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// date returns the date of a DTSTART or DTEND property, and if it is an
+// all-day date rather than a date-time. Date-times are taken as dates in
+// their TZID time zone, if provided.
+func (p icsProperty) date() (time.Time, bool, error) {
+	if p.params["VALUE"] == "DATE" || len(p.value) == 8 {
+		t, err := time.Parse("20060102", p.value)
+		return t, true, err
+	}
+	loc := time.UTC
+	if tz, ok := p.params["TZID"]; ok {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("time zone %q not known", tz)
+		}
+		loc = l
+	}
+	var t time.Time
+	var err error
+	if strings.HasSuffix(p.value, "Z") {
+		t, err = time.Parse("20060102T150405Z", p.value)
+	} else {
+		t, err = time.ParseInLocation("20060102T150405", p.value, loc)
+	}
+	if err != nil {
+		return t, false, err
+	}
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), false, nil
+}
+
+// HolidaysICSDecoder decodes a set of holidays from the VEVENT entries
+// of an iCalendar (.ics) file, using the DTSTART and DTEND of each
+// event. The DTEND of an all-day event is the day after the last day
+// away, as set out in RFC 5545, unless the decoder is made
+// WithICSInclusiveEnd. An event with a DTEND date-time ends on the date
+// of DTEND, and an event without a DTEND lasts a day. Events written by
+// TripsAsICS or TripsAsICSFeed other than trips and exempt periods are
+// skipped. The decoded holidays are checked with Validate, and returned
+// with any ValidationErrors.
+func HolidaysICSDecoder(input []byte, options ...ICSOption) ([]Holiday, error) {
+	c := &icsConfig{}
+	for _, o := range options {
+		if err := o(c); err != nil {
+			return nil, err
+		}
+	}
+
+	lines, err := icsLines(input)
+	if err != nil {
+		return nil, fmt.Errorf("ics reading error: %w", err)
+	}
+
+	hols := []Holiday{}
+	var event map[string]icsProperty // the properties of the current event
+	for _, line := range lines {
+		p, err := parseICSLine(line)
+		if err != nil {
+			return hols, err
+		}
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT"):
+			event = map[string]icsProperty{}
+		case p.name == "END" && strings.EqualFold(p.value, "VEVENT"):
+			if event == nil {
+				return hols, errors.New("ics event ended without beginning")
+			}
+			h, skip, err := c.eventHoliday(event, len(hols))
+			if err != nil {
+				return hols, err
+			}
+			if !skip {
+				hols = append(hols, h)
+			}
+			event = nil
+		case event != nil:
+			if _, ok := event[p.name]; !ok {
+				event[p.name] = p
+			}
+		}
+	}
+	if len(hols) == 0 {
+		return hols, errors.New("ics has no events")
+	}
+	return hols, Validate(hols)
+}
+
+// eventHoliday makes the holiday described by the properties of an
+// event, numbered from zero by index, reporting if the event is to be
+// skipped.
+func (c *icsConfig) eventHoliday(event map[string]icsProperty, index int) (Holiday, bool, error) {
+	h := Holiday{}
+	switch kind := strings.ToLower(event[icsKindProperty].value); kind {
+	case "", "trip":
+	case "exempt":
+		h.Exempt = true
+	default:
+		return h, true, nil
+	}
+
+	start, ok := event["DTSTART"]
+	if !ok {
+		return h, false, fmt.Errorf("event %d: no DTSTART", index+1)
+	}
+	var allDay bool
+	var err error
+	h.Start, allDay, err = start.date()
+	if err != nil {
+		return h, false, fmt.Errorf("event %d: DTSTART %w", index+1, err)
+	}
+	h.End = h.Start
+	if end, ok := event["DTEND"]; ok {
+		var endAllDay bool
+		h.End, endAllDay, err = end.date()
+		if err != nil {
+			return h, false, fmt.Errorf("event %d: DTEND %w", index+1, err)
+		}
+		if allDay && endAllDay && !c.inclusiveEnd && h.End.After(h.Start) {
+			h.End = h.End.Add(durationDays(-1))
+		}
+	}
+	if country, ok := event[icsCountryProperty]; ok {
+		h.Country, err = countryCode(country.value)
+		if err != nil {
+			return h, false, fmt.Errorf("event %d: %w", index+1, err)
+		}
+	}
+	h.Duration = h.days()
+	return h, false, nil
+}
+
+// icsEvent is an all-day event written by TripsAsICS.
+type icsEvent struct {
+	kind        string    // the value of icsKindProperty
+	summary     string    // the event title
+	description string    // the event description
+	start, end  time.Time // the first and last days of the event
+	country     string    // the country of a trip
+}
+
+// tripEvents returns an event for each trip and exempt period.
+func (trips *Trips) tripEvents() []icsEvent {
+	events := []icsEvent{}
+	for _, h := range trips.OriginalHolidays {
+		summary := "Trip"
+		if h.Country != "" {
+			summary += " (" + h.Country + ")"
+		}
+		events = append(events, icsEvent{"trip", summary, h.String(), h.Start, h.End, h.Country})
+	}
+	for _, h := range trips.Exemptions {
+		events = append(events, icsEvent{"exempt", "Exempt period", h.String() + " " + exemptReason, h.Start, h.End, ""})
+	}
+	return events
+}
+
+// TripsAsICS writes the trips as an iCalendar (.ics) file of all-day
+// events for each trip and exempt period, and for each day on which the
+// allowance was exhausted and the following day from which it is safe to
+// return, as reported by Milestones.
+func TripsAsICS(trips *Trips, w io.Writer) error {
+	milestones, err := trips.Milestones()
+	if err != nil {
+		return err
+	}
+
+	events := trips.tripEvents()
+	for _, m := range milestones {
+		events = append(events,
+			icsEvent{
+				"exhausted", "Allowance exhausted",
+				fmt.Sprintf("The allowance of %d days under the %s rule was exhausted on %s.", trips.MaxStay, trips.Rule, dayFmt(m.Exhausted)),
+				m.Exhausted, m.Exhausted, "",
+			},
+			icsEvent{
+				"safe-return", "Safe to return from",
+				fmt.Sprintf("A return from %s does not breach the %s rule.", dayFmt(m.SafeFrom), trips.Rule),
+				m.SafeFrom, m.SafeFrom, "",
+			},
+		)
+	}
+	return writeICS(w, nil, events)
+}
+
+// TripsAsICSFeed writes the trips as an iCalendar (.ics) feed for
+// calendar apps to subscribe to, named name, with all-day events for
+// each trip and exempt period, for the window with the most days away,
+// if any days away are counted by the rule, and for the day on which the full allowance is again available, as
+// reported by ResetDate. Calendar apps are asked to refresh the feed
+// every refresh.
+func TripsAsICSFeed(trips *Trips, w io.Writer, name string, refresh time.Duration) error {
+	reset, err := trips.ResetDate()
+	if err != nil {
+		return err
+	}
+
+	events := trips.tripEvents()
+	if trips.Window.DaysAway > 0 {
+		events = append(events, icsEvent{
+			"window", fmt.Sprintf("Most days away: %d of %d", trips.Window.DaysAway, trips.MaxStay),
+			fmt.Sprintf("The window with the most days away under the %s rule, %d of the %d allowed.", trips.Rule, trips.Window.DaysAway, trips.MaxStay),
+			trips.Window.Start, trips.Window.End, "",
+		})
+	}
+	events = append(events,
+		icsEvent{
+			"reset", "Allowance reset",
+			fmt.Sprintf("The full allowance of %d days under the %s rule is available from %s.", trips.MaxStay, trips.Rule, dayFmt(reset)),
+			reset, reset, "",
+		},
+	)
+	interval := icsDuration(refresh)
+	properties := []string{
+		"X-WR-CALNAME:" + icsEscape(name),
+		"NAME:" + icsEscape(name),
+		"REFRESH-INTERVAL;VALUE=DURATION:" + interval,
+		"X-PUBLISHED-TTL:" + interval,
+	}
+	return writeICS(w, properties, events)
+}
+
+// uid returns the UID of the event, made from its kind, dates and
+// country so that it does not change as other events are added or
+// removed. Repeated events are numbered by the count of each UID in
+// seen, which is updated.
+func (e icsEvent) uid(seen map[string]int) string {
+	uid := e.kind + "-" + e.start.Format("20060102") + "-" + e.end.Format("20060102")
+	if e.country != "" {
+		uid += "-" + e.country
+	}
+	seen[uid]++
+	if n := seen[uid]; n > 1 {
+		uid += fmt.Sprintf("-%d", n)
+	}
+	return uid + "@timeaway"
+}
+
+// writeICS writes an iCalendar file with the calendar properties and
+// events to w.
+func writeICS(w io.Writer, properties []string, events []icsEvent) error {
+	stamp := icsStamp().UTC().Format("20060102T150405Z")
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//rorycl//timeaway//EN",
+		"CALSCALE:GREGORIAN",
+	}
+	lines = append(lines, properties...)
+	uids := map[string]int{}
+	for _, e := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+e.uid(uids),
+			"DTSTAMP:"+stamp,
+			"DTSTART;VALUE=DATE:"+e.start.Format("20060102"),
+			"DTEND;VALUE=DATE:"+e.end.Add(durationDays(1)).Format("20060102"),
+			"SUMMARY:"+icsEscape(e.summary),
+			"DESCRIPTION:"+icsEscape(e.description),
+			"TRANSP:TRANSPARENT",
+			icsKindProperty+":"+e.kind,
+		)
+		if e.country != "" {
+			lines = append(lines, icsCountryProperty+":"+e.country)
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	bw := bufio.NewWriter(w)
+	for _, l := range lines {
+		if _, err := bw.WriteString(icsFold(l)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// icsDuration formats d as an RFC 5545 duration in whole hours, or else
+// minutes, such as "PT12H", of at least a minute.
+func icsDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("PT%dH", int(d.Hours()))
+	}
+	return fmt.Sprintf("PT%dM", max(int(d.Minutes()), 1))
+}
+
+// icsEscape escapes text property values.
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// icsFold folds a content line into lines of no more than 75 octets,
+// without splitting utf-8 characters, ending each with CRLF.
+func icsFold(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
@@ -0,0 +1,348 @@
+package trips
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHolidaysICSDecoder(t *testing.T) {
+
+	// ics returns a calendar of the events, each a set of content lines
+	ics := func(events ...string) string {
+		s := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"
+		for _, e := range events {
+			s += "BEGIN:VEVENT\r\n" + e + "END:VEVENT\r\n"
+		}
+		return s + "END:VCALENDAR\r\n"
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		options []ICSOption
+		trips   []string // start and end dates as "2006-01-02 2006-01-02"
+		exempt  int
+		isErr   bool
+		isValid bool // if the error is ValidationErrors
+	}{
+		{
+			name:  "all-day exclusive end",
+			input: ics("DTSTART;VALUE=DATE:20240101\r\nDTEND;VALUE=DATE:20240111\r\n"),
+			trips: []string{"2024-01-01 2024-01-10"},
+		},
+		{
+			name:    "all-day inclusive end",
+			input:   ics("DTSTART;VALUE=DATE:20240101\r\nDTEND;VALUE=DATE:20240110\r\n"),
+			options: []ICSOption{WithICSInclusiveEnd()},
+			trips:   []string{"2024-01-01 2024-01-10"},
+		},
+		{
+			name:  "single all-day",
+			input: ics("DTSTART;VALUE=DATE:20240101\r\n"),
+			trips: []string{"2024-01-01 2024-01-01"},
+		},
+		{
+			name:  "date-times",
+			input: ics("DTSTART:20240101T090000Z\r\nDTEND:20240110T170000Z\r\n"),
+			trips: []string{"2024-01-01 2024-01-10"},
+		},
+		{
+			name:  "date-time time zone",
+			input: ics("DTSTART;TZID=Asia/Tokyo:20240101T080000\r\nDTEND;TZID=\"Asia/Tokyo\":20240110T080000\r\n"),
+			trips: []string{"2024-01-01 2024-01-10"},
+		},
+		{
+			name:  "utc date-time in a later time zone",
+			input: ics("DTSTART:20231231T230000Z\r\nDTEND:20240110T120000Z\r\n"),
+			trips: []string{"2023-12-31 2024-01-10"},
+		},
+		{
+			name: "folded lines, country and exempt",
+			input: ics(
+				"SUMMARY:a long summary which is folded across\r\n  two lines\r\nDTSTART;VALUE=DATE:20240101\r\nDTEND;VALUE=DATE:20240111\r\nX-TIMEAWAY-COUNTRY:FR\r\n",
+				"DTSTART;VALUE=DATE:20240201\r\nDTEND;VALUE=DATE:20240301\r\nX-TIMEAWAY-KIND:exempt\r\n",
+				"DTSTART;VALUE=DATE:20240401\r\nDTEND;VALUE=DATE:20240402\r\nX-TIMEAWAY-KIND:exhausted\r\n",
+			),
+			trips:  []string{"2024-01-01 2024-01-10", "2024-02-01 2024-02-29"},
+			exempt: 1,
+		},
+		{
+			name:  "bare newlines",
+			input: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20240101\nDTEND;VALUE=DATE:20240103\nEND:VEVENT\nEND:VCALENDAR\n",
+			trips: []string{"2024-01-01 2024-01-02"},
+		},
+		{
+			name:  "no events",
+			input: ics(),
+			isErr: true,
+		},
+		{
+			name:  "no start",
+			input: ics("DTEND;VALUE=DATE:20240110\r\n"),
+			isErr: true,
+		},
+		{
+			name:  "bad date",
+			input: ics("DTSTART;VALUE=DATE:2024-01-01\r\n"),
+			isErr: true,
+		},
+		{
+			name:  "unknown time zone",
+			input: ics("DTSTART;TZID=Nowhere/Special:20240101T080000\r\n"),
+			isErr: true,
+		},
+		{
+			name:  "bad country",
+			input: ics("DTSTART;VALUE=DATE:20240101\r\nX-TIMEAWAY-COUNTRY:France\r\n"),
+			isErr: true,
+		},
+		{
+			name:  "bad line",
+			input: ics("DTSTART;VALUE=DATE:20240101\r\nnonsense\r\n"),
+			isErr: true,
+		},
+		{
+			name: "overlap",
+			input: ics(
+				"DTSTART;VALUE=DATE:20240101\r\nDTEND;VALUE=DATE:20240111\r\n",
+				"DTSTART;VALUE=DATE:20240110\r\nDTEND;VALUE=DATE:20240112\r\n",
+			),
+			trips:   []string{"2024-01-01 2024-01-10", "2024-01-10 2024-01-11"},
+			isErr:   true,
+			isValid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hols, err := HolidaysICSDecoder([]byte(tt.input), tt.options...)
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Fatalf("error got %v want error %t", err, want)
+			}
+			var invalid ValidationErrors
+			if got, want := errors.As(err, &invalid), tt.isValid; got != want {
+				t.Fatalf("validation error got %t want %t (%v)", got, want, err)
+			}
+			if err != nil && !tt.isValid {
+				return
+			}
+			if got, want := len(hols), len(tt.trips); got != want {
+				t.Fatalf("trips got %d want %d", got, want)
+			}
+			exempt := 0
+			for i, h := range hols {
+				if got, want := h.Start.Format("2006-01-02")+" "+h.End.Format("2006-01-02"), tt.trips[i]; got != want {
+					t.Errorf("trip %d got %s want %s", i+1, got, want)
+				}
+				if h.Exempt {
+					exempt++
+				}
+			}
+			if got, want := exempt, tt.exempt; got != want {
+				t.Errorf("exempt got %d want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestTripsAsICS(t *testing.T) {
+
+	icsStamp = func() time.Time {
+		return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	}
+	defer func() { icsStamp = time.Now }()
+
+	calc, err := NewCalculator(WithWindowSize(10), WithMaxStay(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hols := []Holiday{}
+	for _, d := range [][]string{{"2023-01-10", "2023-01-14"}, {"2023-02-01", "2023-02-02"}} {
+		h, err := newHolidayFromStr(d[0], d[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		hols = append(hols, *h)
+	}
+	hols[0].Country = "FR"
+	trips, err := calc.Calculate(hols)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := TripsAsICS(trips, &buf); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"SUMMARY:Trip (FR)\r\n",
+		"DTSTART;VALUE=DATE:20230110\r\nDTEND;VALUE=DATE:20230115\r\n",
+		"DTSTAMP:20240601T120000Z\r\n",
+		"SUMMARY:Allowance exhausted\r\n",
+		"UID:exhausted-20230114-20230114@timeaway\r\n",
+		"UID:trip-20230110-20230114-FR@timeaway\r\n",
+		"SUMMARY:Safe to return from\r\n",
+		"DTSTART;VALUE=DATE:20230120\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q", want)
+		}
+	}
+	if got, want := strings.Count(output, "BEGIN:VEVENT"), 4; got != want {
+		t.Errorf("events got %d want %d", got, want)
+	}
+	for _, line := range strings.Split(output, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+	}
+
+	// the trips, but not the other events, are read back
+	readHols, err := HolidaysICSDecoder(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(readHols), len(hols); got != want {
+		t.Fatalf("read back trips got %d want %d", got, want)
+	}
+	for i, h := range readHols {
+		if !h.Start.Equal(hols[i].Start) || !h.End.Equal(hols[i].End) || h.Country != hols[i].Country {
+			t.Errorf("read back trip %d got %s want %s", i+1, h, hols[i])
+		}
+	}
+}
+
+func TestICSFold(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("é", 50)
+	folded := icsFold(line)
+	if !strings.HasSuffix(folded, "\r\n") {
+		t.Error("folded line does not end with CRLF")
+	}
+	lines, err := icsLines([]byte(folded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lines, []string{line}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("unfolded got %q want %q", got, want)
+	}
+	for _, l := range strings.Split(folded, "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("line longer than 75 octets: %q", l)
+		}
+	}
+}
+
+// TestICSEventUID checks that event UIDs do not depend on the position
+// of the events, and that repeated events have distinct UIDs.
+func TestICSEventUID(t *testing.T) {
+	d := func(s string) time.Time {
+		t.Helper()
+		day, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return day
+	}
+	trip := icsEvent{kind: "trip", start: d("2023-01-10"), end: d("2023-01-14"), country: "FR"}
+	exempt := icsEvent{kind: "exempt", start: d("2023-02-01"), end: d("2023-02-28")}
+
+	if got, want := trip.uid(map[string]int{}), "trip-20230110-20230114-FR@timeaway"; got != want {
+		t.Errorf("uid got %s want %s", got, want)
+	}
+	seen := map[string]int{}
+	other := icsEvent{kind: "trip", start: d("2023-01-01"), end: d("2023-01-02")}
+	for _, e := range []icsEvent{other, trip} {
+		e.uid(seen)
+	}
+	if got, want := exempt.uid(seen), "exempt-20230201-20230228@timeaway"; got != want {
+		t.Errorf("uid after another trip got %s want %s", got, want)
+	}
+	if got, want := exempt.uid(seen), "exempt-20230201-20230228-2@timeaway"; got != want {
+		t.Errorf("repeated uid got %s want %s", got, want)
+	}
+}
+
+func TestTripsAsICSFeed(t *testing.T) {
+
+	icsStamp = func() time.Time {
+		return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	}
+	defer func() { icsStamp = time.Now }()
+
+	h, err := newHolidayFromStr("2023-01-10", "2023-01-14")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trips, err := Calculate([]Holiday{*h}, WithRule(RollingRule{"test", 10, 5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := TripsAsICSFeed(trips, &buf, "My trips; 2023", 12*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+
+	for _, want := range []string{
+		`X-WR-CALNAME:My trips\; 2023` + "\r\n",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT12H\r\n",
+		"SUMMARY:Trip\r\n",
+		"SUMMARY:Most days away: 5 of 5\r\n",
+		"SUMMARY:Allowance reset\r\nDESCRIPTION:The full allowance of 5 days under the test rule is",
+		"DTSTART;VALUE=DATE:20230124\r\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q:\n%s", want, output)
+		}
+	}
+
+	// only the trip is read back
+	hols, err := HolidaysICSDecoder(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(hols), 1; got != want {
+		t.Errorf("read back trips got %d want %d", got, want)
+	}
+}
+
+// TestTripsAsICSFeedNoDaysCounted checks that the feed has no window
+// event if no days away are counted by the rule.
+func TestTripsAsICSFeedNoDaysCounted(t *testing.T) {
+	h, err := newHolidayFromStr("2023-01-10", "2023-01-14")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Country = "IE"
+	trips, err := Calculate([]Holiday{*h})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := TripsAsICSFeed(trips, &buf, "trips", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if output := buf.String(); strings.Contains(output, "X-TIMEAWAY-KIND:window") || strings.Contains(output, "00010101") {
+		t.Errorf("unexpected window event:\n%s", output)
+	}
+}
+
+func TestICSDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		12 * time.Hour:   "PT12H",
+		90 * time.Minute: "PT90M",
+		time.Second:      "PT1M",
+	} {
+		if got := icsDuration(d); got != want {
+			t.Errorf("duration %s got %s want %s", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package trips
+
+import (
+	"time"
+)
+
+// ledger records whether each day from origin was spent away, allowing
+// the number of days away in any period to be counted without
+// reference to the holidays themselves. A running total of days away is
+// kept as a prefix sum so that the days away in any period can be
+// counted in constant time.
+type ledger struct {
+	origin time.Time // the first day recorded
+	away   []bool    // days away from origin
+	sums   []int     // sums[i] is the number of days away before away[i]
+}
+
+// newLedger makes a ledger covering the provided holidays.
+func newLedger(hols []Holiday) *ledger {
+	l := &ledger{}
+	if len(hols) == 0 {
+		return l
+	}
+	l.origin = hols[0].Start
+	last := hols[0].End
+	for _, h := range hols[1:] {
+		if h.Start.Before(l.origin) {
+			l.origin = h.Start
+		}
+		if h.End.After(last) {
+			last = h.End
+		}
+	}
+	l.away = make([]bool, l.index(last)+1)
+	for _, h := range hols {
+		for i := l.index(h.Start); i <= l.index(h.End); i++ {
+			l.away[i] = true
+		}
+	}
+	l.sum()
+	return l
+}
+
+// sum calculates the prefix sums of days away.
+func (l *ledger) sum() {
+	l.sums = make([]int, len(l.away)+1)
+	for i, a := range l.away {
+		l.sums[i+1] = l.sums[i]
+		if a {
+			l.sums[i+1]++
+		}
+	}
+}
+
+// index returns the position of day d in the ledger, which may be out
+// of range.
+func (l *ledger) index(d time.Time) int {
+	return int(d.Sub(l.origin) / durationDays(1))
+}
+
+// mark records the days from start to end inclusive as days away,
+// extending the ledger if necessary.
+func (l *ledger) mark(start, end time.Time) {
+	if len(l.away) == 0 {
+		l.origin = start
+	}
+	if start.Before(l.origin) {
+		extra := make([]bool, -l.index(start))
+		l.away = append(extra, l.away...)
+		l.origin = start
+	}
+	if i := l.index(end); i >= len(l.away) {
+		l.away = append(l.away, make([]bool, i-len(l.away)+1)...)
+	}
+	for i := l.index(start); i <= l.index(end); i++ {
+		l.away[i] = true
+	}
+	l.sum()
+}
+
+// clone returns a copy of the ledger.
+func (l *ledger) clone() *ledger {
+	c := &ledger{
+		origin: l.origin,
+		away:   make([]bool, len(l.away)),
+		sums:   make([]int, len(l.sums)),
+	}
+	copy(c.away, l.away)
+	copy(c.sums, l.sums)
+	return c
+}
+
+// isAway reports if day d was spent away.
+func (l *ledger) isAway(d time.Time) bool {
+	i := l.index(d)
+	if i < 0 || i >= len(l.away) {
+		return false
+	}
+	return l.away[i]
+}
+
+// last returns the last day recorded by the ledger.
+func (l *ledger) last() time.Time {
+	return l.origin.Add(durationDays(len(l.away) - 1))
+}
+
+// count returns the number of days away from start to end inclusive.
+func (l *ledger) count(start, end time.Time) int {
+	s, e := max(l.index(start), 0), min(l.index(end), len(l.away)-1)
+	if s > e {
+		return 0
+	}
+	return l.sums[e+1] - l.sums[s]
+}
+
+// complies reports if, for each day away from start until the day
+// before the first period under rule to start after stayEnd, the days
+// away in the period up to and including that day are within the rule's
+// limit. Periods starting after stayEnd are not affected by days away
+// up to stayEnd.
+func (l *ledger) complies(start, stayEnd time.Time, rule Rule) bool {
+	for d := start; !d.After(l.last()); d = d.Add(durationDays(1)) {
+		periodStart := rule.PeriodStart(d)
+		if periodStart.After(stayEnd) {
+			break
+		}
+		if l.isAway(d) && l.count(periodStart, d) > rule.Limit() {
+			return false
+		}
+	}
+	return true
+}
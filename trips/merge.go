@@ -0,0 +1,119 @@
+package trips
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Merge records a set of overlapping trips, such as the legs of a
+// journey through several countries sharing border days, which were
+// combined into a single trip.
+type Merge struct {
+	Holiday Holiday   `json:"holiday"` // the combined trip
+	Merged  []Holiday `json:"merged"`  // the trips which were combined
+	Indices []int     `json:"indices"` // the indices of the combined trips
+}
+
+// String returns a printable version of a merge
+func (m Merge) String() string {
+	return fmt.Sprintf("%s to %s (%d days) merged from %d trips", dayShortFmt(m.Holiday.Start), dayShortFmt(m.Holiday.End), m.Holiday.Duration, len(m.Merged))
+}
+
+// MergeHolidays combines trips which overlap, including those which
+// share a day such as the day of a border crossing, into single trips,
+// returning the holidays with each set of overlapping trips replaced by
+// one trip in the place of the first of the set, and a Merge describing
+// each combination. A combined trip keeps the country of its trips if
+// they share the same country; otherwise the trips are kept as its Legs
+// so that a CountryRule counts each day by the countries visited.
+// Exempt periods and holidays with missing or reversed dates are
+// returned unchanged, to be reported by Validate.
+func MergeHolidays(hols []Holiday) ([]Holiday, []Merge) {
+
+	// dated holds the indices of trips with valid dates, by start date
+	dated := []int{}
+	for i, h := range hols {
+		if h.Exempt || h.Start.IsZero() || h.End.IsZero() || h.End.Before(h.Start) {
+			continue
+		}
+		dated = append(dated, i)
+	}
+	sort.SliceStable(dated, func(i, j int) bool {
+		return hols[dated[i]].Start.Before(hols[dated[j]].Start)
+	})
+
+	// group the overlapping trips
+	groups := [][]int{}
+	var end time.Time // the latest end of the current group
+	for _, i := range dated {
+		if len(groups) > 0 && !hols[i].Start.After(end) {
+			groups[len(groups)-1] = append(groups[len(groups)-1], i)
+			if hols[i].End.After(end) {
+				end = hols[i].End
+			}
+			continue
+		}
+		groups = append(groups, []int{i})
+		end = hols[i].End
+	}
+
+	// combine each group of more than one trip, recording the combined
+	// trip against the lowest index of the group
+	merges := []Merge{}
+	combined := map[int]Holiday{}
+	skip := map[int]bool{}
+	for _, g := range groups {
+		if len(g) < 2 {
+			continue
+		}
+		sort.Ints(g)
+		h := Holiday{Start: hols[g[0]].Start, End: hols[g[0]].End, Country: hols[g[0]].Country}
+		m := Merge{Indices: g}
+		for _, i := range g {
+			o := hols[i]
+			if o.Start.Before(h.Start) {
+				h.Start = o.Start
+			}
+			if o.End.After(h.End) {
+				h.End = o.End
+			}
+			if o.Country != h.Country {
+				h.Country = ""
+			}
+			h.Legs = append(h.Legs, Holiday{Start: o.Start, End: o.End, Country: o.Country, Duration: o.days()})
+			m.Merged = append(m.Merged, o)
+			skip[i] = true
+		}
+		h.Duration = h.days()
+		if h.Country != "" {
+			h.Legs = nil
+		}
+		m.Holiday = h
+		combined[g[0]] = h
+		merges = append(merges, m)
+	}
+
+	merged := []Holiday{}
+	for i, h := range hols {
+		if c, ok := combined[i]; ok {
+			merged = append(merged, c)
+			continue
+		}
+		if !skip[i] {
+			merged = append(merged, h)
+		}
+	}
+	return merged, merges
+}
+
+// Mergeable reports if every problem is an overlap between trips, which
+// MergeHolidays would resolve.
+func (v ValidationErrors) Mergeable() bool {
+	for _, e := range v {
+		if _, ok := e.(OverlapError); !ok {
+			return false
+		}
+	}
+	return len(v) > 0
+}
@@ -0,0 +1,127 @@
+package trips
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestMergeHolidays(t *testing.T) {
+
+	tp := func(s, e, c string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Country = c
+		return *h
+	}
+
+	hols := []Holiday{
+		tp("2024-03-01", "2024-03-05", "DE"),
+		tp("2024-01-01", "2024-01-10", "FR"), // journey through France,
+		tp("2024-01-10", "2024-01-15", "FR"), // more France
+		tp("2024-02-01", "2024-02-10", "FR"), // then Spain,
+		tp("2024-02-10", "2024-02-20", "ES"), // sharing a border day
+		tp("2024-02-05", "2024-02-06", "ES"), // and within
+	}
+	exempt := tp("2024-01-01", "2024-01-31", "")
+	exempt.Exempt = true
+	hols = append(hols, exempt)
+
+	merged, merges := MergeHolidays(hols)
+	if got, want := len(merged), 4; got != want {
+		t.Fatalf("merged holidays got %d want %d: %v", got, want, merged)
+	}
+	if got, want := len(merges), 2; got != want {
+		t.Fatalf("merges got %d want %d", got, want)
+	}
+	if got, want := merged[1].String(), tp("2024-01-01", "2024-01-15", "FR").String(); got != want {
+		t.Errorf("first merge got %s want %s", got, want)
+	}
+	if got, want := merged[2].Country, ""; got != want {
+		t.Errorf("mixed country merge got %q want %q", got, want)
+	}
+	if got, want := merges[1].Indices, []int{3, 4, 5}; !slices.Equal(got, want) {
+		t.Errorf("merge indices got %v want %v", got, want)
+	}
+	if got, want := merges[1].String(), "01/02/2024 to 20/02/2024 (20 days) merged from 3 trips"; got != want {
+		t.Errorf("merge string got %q want %q", got, want)
+	}
+	if !merged[3].Exempt {
+		t.Error("exempt period not kept")
+	}
+
+	// overlaps are only errors without merging
+	var ve ValidationErrors
+	if err := Validate(hols); !errors.As(err, &ve) || !ve.Mergeable() {
+		t.Errorf("expected mergeable validation errors, got %v", err)
+	}
+	if _, err := Calculate(hols); err == nil {
+		t.Error("expected overlap error")
+	}
+	trips, err := Calculate(hols, WithMergeOverlaps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(trips.Merges), 2; got != want {
+		t.Errorf("trips merges got %d want %d", got, want)
+	}
+	if got, want := len(trips.OriginalHolidays), 3; got != want {
+		t.Errorf("trips holidays got %d want %d", got, want)
+	}
+
+	reversed := append(hols, Holiday{Start: hols[1].End, End: hols[1].Start})
+	if err := Validate(reversed); !errors.As(err, &ve) || ve.Mergeable() {
+		t.Errorf("expected unmergeable validation errors, got %v", err)
+	}
+
+	plan, err := PlanStay(hols, tp("2024-04-01", "2024-04-01", "").Start, WithMergeOverlaps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Days < 1 {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+}
+
+// TestMergeHolidaysMixedCountries checks that merging legs in Schengen
+// and non-Schengen countries only counts the Schengen days.
+func TestMergeHolidaysMixedCountries(t *testing.T) {
+
+	tp := func(s, e, c string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Country = c
+		return *h
+	}
+
+	hols := []Holiday{
+		tp("2024-01-01", "2024-01-10", "FR"),
+		tp("2024-01-10", "2024-01-20", "IE"), // crossing on the 10th
+	}
+	trips, err := Calculate(hols, WithMergeOverlaps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(trips.OriginalHolidays), 1; got != want {
+		t.Fatalf("merged holidays got %d want %d", got, want)
+	}
+	if got, want := len(trips.OriginalHolidays[0].Legs), 2; got != want {
+		t.Errorf("merged legs got %d want %d", got, want)
+	}
+	if got, want := trips.DaysAway, 10; got != want {
+		t.Errorf("days away got %d want %d", got, want)
+	}
+	if got, want := len(trips.Exclusions), 1; got != want {
+		t.Fatalf("exclusions got %d want %d: %v", got, want, trips.Exclusions)
+	}
+	if got, want := trips.Exclusions[0].String(), "11/01/2024 to 20/01/2024 (10 days) excluded: Ireland is not a Schengen member state"; got != want {
+		t.Errorf("exclusion got %q want %q", got, want)
+	}
+	if got, want := trips.Exclusions[0].Country, "IE"; got != want {
+		t.Errorf("exclusion country got %q want %q", got, want)
+	}
+}
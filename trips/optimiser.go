@@ -0,0 +1,267 @@
+package trips
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// suggestMaxShift is the most days by which a Suggestion moves a trip
+// later.
+const suggestMaxShift = 366
+
+// Adjustment is the kind of change to a plan of trips proposed by a
+// Suggestion.
+type Adjustment string
+
+// The adjustments proposed by Suggest.
+const (
+	ShortenTrip Adjustment = "shorten" // end the last trip earlier
+	ShiftTrip   Adjustment = "shift"   // move a trip later
+	DropTrip    Adjustment = "drop"    // remove a trip
+)
+
+// adjustmentOrder ranks suggestions which disturb a plan equally.
+var adjustmentOrder = map[Adjustment]int{ShortenTrip: 0, ShiftTrip: 1, DropTrip: 2}
+
+// Suggestion is a change to a plan of trips which brings it within the
+// rule it breached. Days is the number of days by which the Trip is
+// shortened or shifted, or its length if it is dropped, and measures
+// how much the change disturbs the plan. Holidays are the holidays of
+// the plan after the change, including any exempt periods.
+type Suggestion struct {
+	Adjustment Adjustment `json:"adjustment"`
+	Trip       Holiday    `json:"trip"`               // the trip to change
+	Adjusted   *Holiday   `json:"adjusted,omitempty"` // the trip after the change, if not dropped
+	Days       int        `json:"days"`               // days shortened, shifted or dropped
+	Holidays   []Holiday  `json:"holidays"`           // the holidays after the change
+}
+
+// String returns a simple string representation of a suggestion
+func (s Suggestion) String() string {
+	switch s.Adjustment {
+	case ShortenTrip:
+		return fmt.Sprintf("shorten the trip %s to %s by %d %s to end on %s",
+			dayShortFmt(s.Trip.Start), dayShortFmt(s.Trip.End), s.Days, plural(s.Days, "day"), dayShortFmt(s.Adjusted.End))
+	case ShiftTrip:
+		return fmt.Sprintf("move the trip %s to %s %d %s later to %s to %s",
+			dayShortFmt(s.Trip.Start), dayShortFmt(s.Trip.End), s.Days, plural(s.Days, "day"),
+			dayShortFmt(s.Adjusted.Start), dayShortFmt(s.Adjusted.End))
+	}
+	return fmt.Sprintf("drop the trip %s to %s (%d %s)", dayShortFmt(s.Trip.Start), dayShortFmt(s.Trip.End), s.Days, plural(s.Days, "day"))
+}
+
+// plural returns noun, pluralised if n is not 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+	return noun + "s"
+}
+
+// Suggest proposes the smallest changes to the trips which would bring
+// them within the rule they breached: shortening the last trip, moving
+// a trip later, without reaching the next trip, or dropping a trip. At
+// most one suggestion of each kind is made for each trip, with the
+// fewest days changed, and the suggestions are ranked by the days they
+// change, then by shortening before moving before dropping trips. No
+// suggestions are made if the trips do not breach the rule.
+//
+// Each change is checked against a ledger of the days away of the
+// other trips, as for PlanStay, rather than by recalculating the trips.
+// If the trips were merged, the suggestions change the merged trips
+// reported in OriginalHolidays, and Holidays lists the trips making up
+// each merged trip with Legs in different countries, so that the
+// changed holidays give the same result when calculated with merging.
+func (trips *Trips) Suggest() ([]Suggestion, error) {
+	rule, err := trips.currentRule()
+	if err != nil {
+		return nil, err
+	}
+	if trips.Error != nil {
+		return nil, trips.Error
+	}
+	if !trips.Breach || len(trips.OriginalHolidays) == 0 {
+		return nil, nil
+	}
+
+	// adjusted returns the holidays with trip i replaced by h, or
+	// dropped if h is nil, the legs of merged trips being listed in
+	// their place
+	adjusted := func(i int, h *Holiday) []Holiday {
+		hols := []Holiday{}
+		for j, o := range trips.OriginalHolidays {
+			switch {
+			case j != i:
+				hols = append(hols, unmerged(o)...)
+			case h != nil:
+				hols = append(hols, unmerged(*h)...)
+			}
+		}
+		for _, ex := range trips.Exemptions {
+			hols = append(hols, plainHoliday(ex))
+		}
+		return hols
+	}
+
+	suggestions := []Suggestion{}
+	suggest := func(kind Adjustment, i int, h *Holiday, days int) {
+		suggestions = append(suggestions, Suggestion{kind, plainHoliday(trips.OriginalHolidays[i]), h, days, adjusted(i, h)})
+	}
+
+	last := 0
+	for i, h := range trips.OriginalHolidays {
+		if h.Start.After(trips.OriginalHolidays[last].Start) {
+			last = i
+		}
+	}
+
+	for i, trip := range trips.OriginalHolidays {
+
+		// others is a ledger of the days away counted without the trip;
+		// if they breach the rule no change to the trip can help
+		others := []Holiday{}
+		others = append(others, trips.OriginalHolidays[:i]...)
+		others = append(others, trips.OriginalHolidays[i+1:]...)
+		counted, _ := countedHolidays(rule, others, trips.Exemptions)
+		history := newLedger(counted)
+		if !history.complies(history.origin, history.last(), rule) {
+			continue
+		}
+
+		// fits reports if the trip may be replaced by h; only the days
+		// away from the start of h are affected by adding it
+		fits := func(h Holiday) bool {
+			l := history.clone()
+			counted, _ := countedHolidays(rule, []Holiday{h}, trips.Exemptions)
+			for _, c := range counted {
+				l.mark(c.Start, c.End)
+			}
+			return l.complies(h.Start, h.End, rule)
+		}
+
+		// shorten the last trip a day at a time, short of dropping it
+		if i == last {
+			for n := 1; n < trip.days(); n++ {
+				h := shortened(trip, trip.End.Add(durationDays(-n)))
+				if fits(h) {
+					suggest(ShortenTrip, i, &h, n)
+					break
+				}
+			}
+		}
+
+		// move the trip later a day at a time until it would reach the
+		// next trip
+		var next time.Time
+		for _, o := range trips.OriginalHolidays {
+			if o.Start.After(trip.Start) && (next.IsZero() || o.Start.Before(next)) {
+				next = o.Start
+			}
+		}
+		for n := 1; n <= suggestMaxShift; n++ {
+			h := shifted(trip, n)
+			if !next.IsZero() && !h.End.Before(next) {
+				break
+			}
+			if fits(h) {
+				suggest(ShiftTrip, i, &h, n)
+				break
+			}
+		}
+
+		// drop the trip, if others remain
+		if len(trips.OriginalHolidays) > 1 {
+			suggest(DropTrip, i, nil, trip.days())
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Days != b.Days {
+			return a.Days < b.Days
+		}
+		if a.Adjustment != b.Adjustment {
+			return adjustmentOrder[a.Adjustment] < adjustmentOrder[b.Adjustment]
+		}
+		return a.Trip.Start.Before(b.Trip.Start)
+	})
+	return suggestions, nil
+}
+
+// plainHoliday returns a copy of a holiday without calculation
+// details, keeping the legs of a merged trip.
+func plainHoliday(h Holiday) Holiday {
+	p := Holiday{Start: h.Start, End: h.End, Country: h.Country, Exempt: h.Exempt}
+	p.Duration = p.days()
+	for _, l := range h.Legs {
+		p.Legs = append(p.Legs, plainHoliday(l))
+	}
+	return p
+}
+
+// unmerged returns the legs of a merged trip, or else the trip.
+func unmerged(h Holiday) []Holiday {
+	p := plainHoliday(h)
+	if len(p.Legs) == 0 {
+		return []Holiday{p}
+	}
+	return p.Legs
+}
+
+// shortened returns the trip ending on end, with any legs after end
+// dropped or shortened.
+func shortened(h Holiday, end time.Time) Holiday {
+	p := plainHoliday(h)
+	p.End = end
+	p.Duration = p.days()
+	p.Legs = nil
+	for _, l := range plainHoliday(h).Legs {
+		if l.Start.After(end) {
+			continue
+		}
+		if l.End.After(end) {
+			l.End = end
+			l.Duration = l.days()
+		}
+		p.Legs = append(p.Legs, l)
+	}
+	return p
+}
+
+// shifted returns the trip, with any legs, moved n days later.
+func shifted(h Holiday, n int) Holiday {
+	p := plainHoliday(h)
+	p.Start, p.End = p.Start.Add(durationDays(n)), p.End.Add(durationDays(n))
+	for i := range p.Legs {
+		p.Legs[i].Start = p.Legs[i].Start.Add(durationDays(n))
+		p.Legs[i].End = p.Legs[i].End.Add(durationDays(n))
+	}
+	return p
+}
+
+// Suggest calculates the holidays in hols, merging overlapping trips
+// first if the Calculator was made WithMergeOverlaps, and proposes
+// changes which would bring them within the Calculator's rule. See
+// Trips.Suggest for details.
+func (c *Calculator) Suggest(hols []Holiday) ([]Suggestion, error) {
+	trips, err := c.Calculate(hols)
+	if err != nil {
+		return nil, err
+	}
+	return trips.Suggest()
+}
+
+// Suggest proposes changes to the holidays in hols which would bring
+// them within the rule of the default Calculator, or a Calculator made
+// with the provided options. See Trips.Suggest for details.
+func Suggest(hols []Holiday, options ...Option) ([]Suggestion, error) {
+	if len(options) == 0 {
+		return defaultCalculator.Suggest(hols)
+	}
+	c, err := NewCalculator(options...)
+	if err != nil {
+		return nil, err
+	}
+	return c.Suggest(hols)
+}
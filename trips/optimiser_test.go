@@ -0,0 +1,196 @@
+package trips
+
+import (
+	"testing"
+	"time"
+)
+
+// complies reports if the holidays can be calculated by c without
+// breaching its rule.
+func complies(c *Calculator, hols []Holiday) bool {
+	trips, err := c.Calculate(hols)
+	return err == nil && !trips.Breach
+}
+
+func TestSuggest(t *testing.T) {
+
+	tp := func(s, e string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *h
+	}
+	iso := func(d time.Time) string {
+		return d.Format(time.DateOnly)
+	}
+	c, err := NewCalculator(WithRuleName("schengen"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type want struct {
+		adjustment Adjustment
+		start, end string // the trip to change
+		days       int
+		adjusted   string // start and end of the adjusted trip, if any
+	}
+
+	testCases := []struct {
+		name  string
+		hols  []Holiday
+		want  []want
+		isErr bool
+	}{
+		{
+			name: "no breach",
+			hols: []Holiday{tp("2023-01-01", "2023-03-21"), tp("2023-05-01", "2023-05-10")},
+			want: []want{},
+		},
+		{
+			name: "breach",
+			hols: []Holiday{tp("2023-01-01", "2023-03-21"), tp("2023-05-01", "2023-05-15")},
+			want: []want{
+				{ShortenTrip, "2023-05-01", "2023-05-15", 5, "2023-05-01 2023-05-10"},
+				{DropTrip, "2023-05-01", "2023-05-15", 15, ""},
+				{ShiftTrip, "2023-05-01", "2023-05-15", 50, "2023-06-20 2023-07-04"},
+				{DropTrip, "2023-01-01", "2023-03-21", 80, ""},
+			},
+		},
+		{
+			name: "single trip",
+			hols: []Holiday{tp("2023-01-01", "2023-04-10")},
+			want: []want{
+				{ShortenTrip, "2023-01-01", "2023-04-10", 10, "2023-01-01 2023-03-31"},
+			},
+		},
+		{
+			name: "exemption kept",
+			hols: []Holiday{
+				tp("2023-01-01", "2023-03-21"),
+				tp("2023-05-01", "2023-05-15"),
+				{Start: tp("2023-05-01", "2023-05-01").Start, End: tp("2023-05-02", "2023-05-02").Start, Exempt: true},
+			},
+			want: []want{
+				{ShortenTrip, "2023-05-01", "2023-05-15", 5, "2023-05-01 2023-05-10"},
+				{DropTrip, "2023-05-01", "2023-05-15", 15, ""},
+				{ShiftTrip, "2023-05-01", "2023-05-15", 50, "2023-06-20 2023-07-04"},
+				{DropTrip, "2023-01-01", "2023-03-21", 80, ""},
+			},
+		},
+		{
+			name:  "no trips",
+			hols:  []Holiday{},
+			isErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			suggestions, err := c.Suggest(tc.hols)
+			if err != nil && !tc.isErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err == nil && tc.isErr {
+				t.Fatalf("expected error, got %v", suggestions)
+			}
+			if tc.isErr {
+				return
+			}
+			if got, want := len(suggestions), len(tc.want); got != want {
+				t.Fatalf("got %d suggestions want %d: %v", got, want, suggestions)
+			}
+			for i, w := range tc.want {
+				s := suggestions[i]
+				if got, want := s.Adjustment, w.adjustment; got != want {
+					t.Errorf("%d adjustment got %s want %s", i, got, want)
+				}
+				if got, want := iso(s.Trip.Start)+" "+iso(s.Trip.End), w.start+" "+w.end; got != want {
+					t.Errorf("%d trip got %s want %s", i, got, want)
+				}
+				if got, want := s.Days, w.days; got != want {
+					t.Errorf("%d days got %d want %d", i, got, want)
+				}
+				adjusted := ""
+				if s.Adjusted != nil {
+					adjusted = iso(s.Adjusted.Start) + " " + iso(s.Adjusted.End)
+				}
+				if got, want := adjusted, w.adjusted; got != want {
+					t.Errorf("%d adjusted got %q want %q", i, got, want)
+				}
+				if !complies(c, s.Holidays) {
+					t.Errorf("%d holidays %v do not comply", i, s.Holidays)
+				}
+				exempt := func(hols []Holiday) (n int) {
+					for _, h := range hols {
+						if h.Exempt {
+							n++
+						}
+					}
+					return n
+				}
+				if got, want := exempt(s.Holidays), exempt(tc.hols); got != want {
+					t.Errorf("%d holidays have %d exemptions want %d", i, got, want)
+				}
+				if s.String() == "" {
+					t.Errorf("%d empty string", i)
+				}
+			}
+		})
+	}
+}
+
+// TestSuggestMerged checks that suggestions for merged trips change the
+// merged trips, listing the legs of merged trips in their holidays.
+func TestSuggestMerged(t *testing.T) {
+
+	tp := func(s, e, c string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Country = c
+		return *h
+	}
+	c, err := NewCalculator(WithMergeOverlaps())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 80 days in France, then a journey through Ireland and France of
+	// which only the 15 French days are counted
+	hols := []Holiday{
+		tp("2023-01-01", "2023-03-21", "FR"),
+		tp("2023-05-01", "2023-05-10", "IE"),
+		tp("2023-05-10", "2023-05-24", "FR"),
+	}
+	trips, err := c.Calculate(hols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := trips.LongestDaysAway, 95; got != want {
+		t.Fatalf("days away got %d want %d", got, want)
+	}
+	suggestions, err := trips.Suggest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) == 0 {
+		t.Fatal("no suggestions")
+	}
+	s := suggestions[0]
+	if got, want := s.String(), "shorten the trip 01/05/2023 to 24/05/2023 by 5 days to end on 19/05/2023"; got != want {
+		t.Errorf("suggestion got %q want %q", got, want)
+	}
+	if got, want := len(s.Adjusted.Legs), 2; got != want {
+		t.Errorf("adjusted legs got %d want %d", got, want)
+	}
+	if got, want := len(s.Holidays), 3; got != want {
+		t.Errorf("suggested holidays got %d want %d: %v", got, want, s.Holidays)
+	}
+	for i, s := range suggestions {
+		if !complies(c, s.Holidays) {
+			t.Errorf("%d holidays %v do not comply", i, s.Holidays)
+		}
+	}
+}
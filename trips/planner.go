@@ -0,0 +1,97 @@
+package trips
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Plan describes the longest permissible stay for a proposed entry
+// date, from Entry to Exit inclusive.
+type Plan struct {
+	Entry time.Time `json:"entry"` // proposed entry date
+	Exit  time.Time `json:"exit"`  // latest permissible exit date
+	Days  int       `json:"days"`  // length of the stay in days
+}
+
+// String returns a simple string representation of a plan
+func (p Plan) String() string {
+	return fmt.Sprintf("entry %s exit %s (%d days)", dayFmt(p.Entry), dayFmt(p.Exit), p.Days)
+}
+
+// PlanStay returns the Plan with the latest exit date for a stay
+// starting on the entry date which keeps every window at or under
+// MaxStay, taking into account the holidays recorded in trips. The stay
+// may not overlap a recorded holiday, so it ends at the latest the day
+// before the next recorded holiday.
+func (trips *Trips) PlanStay(entry time.Time) (*Plan, error) {
+	rule, err := trips.currentRule()
+	if err != nil {
+		return nil, err
+	}
+	if entry.IsZero() {
+		return nil, errors.New("entry date not set")
+	}
+
+	entry = dayOf(entry)
+	history := trips.countedLedger(rule)
+	all := newLedger(trips.OriginalHolidays)
+	if all.isAway(entry) {
+		return nil, fmt.Errorf("entry date %s is during a recorded trip", dayShortFmt(entry))
+	}
+
+	// extend the stay a day at a time until it would breach; as a
+	// longer stay can only add days away, the first breach ends the
+	// search.
+	plan := &Plan{Entry: entry}
+	for exit := entry; plan.Days < trips.MaxStay; exit = exit.Add(durationDays(1)) {
+		if all.isAway(exit) {
+			break
+		}
+		l := history.clone()
+		trips.markStay(l, rule, entry, exit)
+		if !l.complies(entry, exit, rule) {
+			break
+		}
+		plan.Exit = exit
+		plan.Days++
+	}
+	if plan.Days == 0 {
+		return nil, fmt.Errorf("no stay is permissible from %s", dayShortFmt(entry))
+	}
+	return plan, nil
+}
+
+// PlanStay returns the Plan with the latest exit date for a stay
+// starting on the entry date given the holidays in hols, which may be
+// empty, merging overlapping trips first if the Calculator was made
+// WithMergeOverlaps. See Trips.PlanStay for details.
+func (c *Calculator) PlanStay(hols []Holiday, entry time.Time) (*Plan, error) {
+	if c.merge {
+		hols, _ = MergeHolidays(hols)
+	}
+	if err := Validate(hols); err != nil {
+		return nil, err
+	}
+	trips := newTrips(c)
+	for _, h := range hols {
+		if err := trips.addHoliday(h); err != nil {
+			return nil, err
+		}
+	}
+	return trips.PlanStay(entry)
+}
+
+// PlanStay returns the Plan with the latest exit date for a stay
+// starting on the entry date given the holidays in hols using the
+// default Calculator, or a Calculator made with the provided options.
+func PlanStay(hols []Holiday, entry time.Time, options ...Option) (*Plan, error) {
+	if len(options) == 0 {
+		return defaultCalculator.PlanStay(hols, entry)
+	}
+	c, err := NewCalculator(options...)
+	if err != nil {
+		return nil, err
+	}
+	return c.PlanStay(hols, entry)
+}
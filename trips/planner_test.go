@@ -0,0 +1,61 @@
+package trips
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlanStay(t *testing.T) {
+
+	tp := func(s, e string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *h
+	}
+	day := func(s string) time.Time {
+		return tp(s, s).Start
+	}
+
+	history := []Holiday{tp("2023-01-01", "2023-03-31")}
+	planned := append(history, tp("2023-10-01", "2023-10-10"))
+
+	testCases := []struct {
+		name  string
+		hols  []Holiday
+		entry string
+		exit  string
+		days  int
+		isErr bool
+	}{
+		{"no history", nil, "2023-01-01", "2023-03-31", 90, false},
+		{"allowance exhausted", history, "2023-04-15", "", 0, true},
+		{"allowance exhausted until 30 June", history, "2023-06-29", "", 0, true},
+		{"full allowance", history, "2023-06-30", "2023-09-27", 90, false},
+		{"during a trip", history, "2023-02-01", "", 0, true},
+		{"limited by planned trip", planned, "2023-06-30", "2023-09-17", 80, false},
+		{"up to planned trip", planned, "2023-09-25", "2023-09-30", 6, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := PlanStay(tc.hols, day(tc.entry))
+			if err != nil && !tc.isErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err == nil && tc.isErr {
+				t.Fatalf("expected error, got %s", plan)
+			}
+			if tc.isErr {
+				return
+			}
+			if got, want := plan.Exit, day(tc.exit); !got.Equal(want) {
+				t.Errorf("exit got %s want %s", dayShortFmt(got), dayShortFmt(want))
+			}
+			if got, want := plan.Days, tc.days; got != want {
+				t.Errorf("days got %d want %d", got, want)
+			}
+		})
+	}
+}
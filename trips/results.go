@@ -0,0 +1,68 @@
+package trips
+
+import (
+	"fmt"
+	"time"
+)
+
+// RuleResult summarises the calculation of a set of holidays under a
+// rule, so that several rules may be reported together.
+type RuleResult struct {
+	Rule          string    `json:"rule"`          // name of the rule
+	Description   string    `json:"description"`   // description of the rule
+	Limit         int       `json:"limit"`         // maximum days away under the rule
+	Breach        bool      `json:"breach"`        // if the rule is breached
+	DaysAway      int       `json:"daysAway"`      // days away in the worst window
+	WindowStart   time.Time `json:"windowStart"`   // start of the worst window
+	WindowEnd     time.Time `json:"windowEnd"`     // end of the worst window
+	DaysRemaining int       `json:"daysRemaining"` // days remaining on the last day of the trips
+	Breaches      []Breach  `json:"breaches"`      // each period in breach
+}
+
+// String returns a simple string representation of a rule result
+func (r RuleResult) String() string {
+	return fmt.Sprintf(
+		"%s: %d of %d days (breach %t, %d remaining)",
+		r.Rule, r.DaysAway, r.Limit, r.Breach, r.DaysRemaining,
+	)
+}
+
+// result returns the RuleResult for calculated trips.
+func (trips *Trips) result() RuleResult {
+	return RuleResult{
+		Rule:          trips.Rule,
+		Description:   trips.Description,
+		Limit:         trips.MaxStay,
+		Breach:        trips.Breach,
+		DaysAway:      trips.DaysAway,
+		WindowStart:   trips.Window.Start,
+		WindowEnd:     trips.Window.End,
+		DaysRemaining: trips.DaysRemaining(trips.End),
+		Breaches:      trips.Breaches,
+	}
+}
+
+// ruleResults returns the RuleResult for the calculated trips followed
+// by the result of calculating the same holidays under each of rules,
+// skipping rules with the name of a rule already reported.
+func (trips *Trips) ruleResults(rules []Rule) ([]RuleResult, error) {
+	results := []RuleResult{trips.result()}
+	seen := map[string]bool{trips.Rule: true}
+	for _, rule := range rules {
+		if seen[rule.Name()] {
+			continue
+		}
+		seen[rule.Name()] = true
+		other := newRuleTrips(rule)
+		other.OriginalHolidays = trips.OriginalHolidays
+		other.Exemptions = trips.Exemptions
+		other.Start, other.End = trips.Start, trips.End
+		other.startFrame, other.endFrame = trips.startFrame, trips.endFrame
+		_, err := other.calculate()
+		if err != nil {
+			return results, fmt.Errorf("rule %s calculation error: %w", rule.Name(), err)
+		}
+		results = append(results, other.result())
+	}
+	return results, nil
+}
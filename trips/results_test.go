@@ -0,0 +1,81 @@
+package trips
+
+import (
+	"testing"
+)
+
+func TestRuleResults(t *testing.T) {
+
+	tp := func(s, e string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *h
+	}
+
+	hols := []Holiday{
+		tp("2024-01-01", "2024-03-31"), // 91 days
+		tp("2024-06-01", "2024-06-30"), // 30 days
+	}
+
+	schengen, err := RuleByName(DefaultRuleName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calendar, err := RuleByName("calendar-year")
+	if err != nil {
+		t.Fatal(err)
+	}
+	consecutive := ConsecutiveRule{"consecutive-30", 30}
+
+	trips, err := Calculate(hols, WithRules(schengen, calendar, consecutive))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the default rule is not repeated
+	if got, want := len(trips.Results), 3; got != want {
+		t.Fatalf("results got %d want %d", got, want)
+	}
+
+	tests := []struct {
+		rule      string
+		breach    bool
+		daysAway  int
+		remaining int
+		breaches  int
+	}{
+		{DefaultRuleName, true, 119, 0, 1},
+		{"calendar-year", false, 121, 62, 0},
+		{"consecutive-30", true, 31, 0, 1},
+	}
+	for i, tt := range tests {
+		r := trips.Results[i]
+		if got, want := r.Rule, tt.rule; got != want {
+			t.Errorf("result %d rule got %s want %s", i, got, want)
+		}
+		if got, want := r.Breach, tt.breach; got != want {
+			t.Errorf("%s breach got %t want %t", r.Rule, got, want)
+		}
+		if got, want := r.DaysAway, tt.daysAway; got != want {
+			t.Errorf("%s days away got %d want %d", r.Rule, got, want)
+		}
+		if got, want := r.DaysRemaining, tt.remaining; got != want {
+			t.Errorf("%s remaining got %d want %d", r.Rule, got, want)
+		}
+		if got, want := len(r.Breaches), tt.breaches; got != want {
+			t.Errorf("%s breaches got %d want %d", r.Rule, got, want)
+		}
+	}
+
+	// the first result matches the trips calculation
+	if got, want := trips.Results[0].DaysAway, trips.DaysAway; got != want {
+		t.Errorf("first result days away got %d want %d", got, want)
+	}
+
+	_, err = Calculate(hols, WithRules(nil))
+	if err == nil {
+		t.Error("expected nil rule error")
+	}
+}
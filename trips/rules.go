@@ -0,0 +1,265 @@
+package trips
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule describes a limit on the number of days away permitted in each
+// of a series of assessment windows, such as the Schengen limit of 90
+// days in any 180 day window, or a cap on the days spent in a country
+// in a tax year.
+//
+// Days away are counted against the limit from the start of the
+// assessment period in which they fall, as reported by PeriodStart. A
+// day away is in breach if the days away from PeriodStart to that day
+// inclusive exceed Limit.
+type Rule interface {
+	// Name returns the name of the rule, such as "schengen".
+	Name() string
+	// Description returns a short description of the rule.
+	Description() string
+	// Limit returns the maximum number of days away permitted in an
+	// assessment window.
+	Limit() int
+	// Windows returns the start and end dates of each assessment
+	// window to search for holidays running from start to end.
+	Windows(start, end time.Time) iter.Seq2[time.Time, time.Time]
+	// PeriodStart returns the first day of the assessment period in
+	// which the days away up to and including day d are counted.
+	PeriodStart(d time.Time) time.Time
+}
+
+// RollingRule limits the days away in any window of WindowSize days to
+// MaxStay, such as the Schengen rule of 90 days in any 180 day window.
+type RollingRule struct {
+	Label      string // name of the rule
+	WindowSize int    // size of the rolling window in days
+	MaxStay    int    // the maximum days away in any window
+}
+
+// NewRollingRule returns a new RollingRule after checking its settings.
+func NewRollingRule(name string, windowSize, maxStay int) (RollingRule, error) {
+	r := RollingRule{name, windowSize, maxStay}
+	switch {
+	case name == "":
+		return r, errors.New("rule name cannot be empty")
+	case windowSize < 3:
+		return r, errors.New("window size cannot be less than 3 days")
+	case maxStay < 2:
+		return r, errors.New("maximum stay cannot be less than 2 days")
+	case maxStay > windowSize:
+		return r, errors.New("maximum stay cannot be greater than the window size")
+	}
+	return r, nil
+}
+
+// Name returns the rule name.
+func (r RollingRule) Name() string { return r.Label }
+
+// Description describes the rule.
+func (r RollingRule) Description() string {
+	return fmt.Sprintf("%d days in any %d day period", r.MaxStay, r.WindowSize)
+}
+
+// Limit returns the maximum stay in any window.
+func (r RollingRule) Limit() int { return r.MaxStay }
+
+// Windows returns a window of WindowSize days starting on each day from
+// start, the last window being the one ending on end, or starting on
+// start if the holidays span less than a window.
+func (r RollingRule) Windows(start, end time.Time) iter.Seq2[time.Time, time.Time] {
+	return rollingWindows(start, end, r.WindowSize)
+}
+
+// PeriodStart returns the start of the window of WindowSize days ending
+// on d.
+func (r RollingRule) PeriodStart(d time.Time) time.Time {
+	return d.Add(-durationDays(r.WindowSize - 1))
+}
+
+// rollingWindows returns windows of windowSize days starting on each
+// day from start to the start of the window ending on end.
+func rollingWindows(start, end time.Time, windowSize int) iter.Seq2[time.Time, time.Time] {
+	windowDuration := durationDays(windowSize - 1) // remove last day
+	endFrame := end.Add(-windowDuration)
+	if endFrame.Before(start) {
+		endFrame = start
+	}
+	return func(yield func(time.Time, time.Time) bool) {
+		for d := start; !d.After(endFrame); d = d.Add(durationDays(1)) {
+			if !yield(d, d.Add(windowDuration)) {
+				return
+			}
+		}
+	}
+}
+
+// YearRule limits the days away in each year to MaxDays, where the year
+// starts on StartDay of StartMonth, such as the UK tax year starting on
+// 6 April.
+type YearRule struct {
+	Label      string     // name of the rule
+	MaxDays    int        // the maximum days away in a year
+	StartMonth time.Month // the month in which the year starts
+	StartDay   int        // the day of the month on which the year starts
+}
+
+// NewYearRule returns a new YearRule after checking its settings.
+func NewYearRule(name string, maxDays int, startMonth time.Month, startDay int) (YearRule, error) {
+	r := YearRule{name, maxDays, startMonth, startDay}
+	switch {
+	case name == "":
+		return r, errors.New("rule name cannot be empty")
+	case maxDays < 1 || maxDays > 365:
+		return r, errors.New("maximum days must be between 1 and 365")
+	case startMonth < time.January || startMonth > time.December:
+		return r, fmt.Errorf("invalid start month %d", startMonth)
+	case startDay < 1 || startDay > 28:
+		return r, errors.New("start day must be between 1 and 28")
+	}
+	return r, nil
+}
+
+// Name returns the rule name.
+func (r YearRule) Name() string { return r.Label }
+
+// Description describes the rule.
+func (r YearRule) Description() string {
+	return fmt.Sprintf("%d days in a year starting %d %s", r.MaxDays, r.StartDay, r.StartMonth)
+}
+
+// Limit returns the maximum days away in a year.
+func (r YearRule) Limit() int { return r.MaxDays }
+
+// yearStart returns the start of the year in which d falls.
+func (r YearRule) yearStart(d time.Time) time.Time {
+	s := time.Date(d.Year(), r.StartMonth, r.StartDay, 0, 0, 0, 0, time.UTC)
+	if d.Before(s) {
+		s = s.AddDate(-1, 0, 0)
+	}
+	return s
+}
+
+// Windows returns each year overlapping start to end.
+func (r YearRule) Windows(start, end time.Time) iter.Seq2[time.Time, time.Time] {
+	return func(yield func(time.Time, time.Time) bool) {
+		for s := r.yearStart(start); !s.After(end); s = s.AddDate(1, 0, 0) {
+			if !yield(s, s.AddDate(1, 0, -1)) {
+				return
+			}
+		}
+	}
+}
+
+// PeriodStart returns the start of the year in which d falls.
+func (r YearRule) PeriodStart(d time.Time) time.Time {
+	return r.yearStart(d)
+}
+
+// ConsecutiveRule limits each visit to MaxDays consecutive days away.
+// Trips on adjoining days are considered a single visit.
+//
+// The rule is assessed as a rolling window of MaxDays+1 days, any of
+// which breaches the rule if every day in it is spent away.
+type ConsecutiveRule struct {
+	Label   string // name of the rule
+	MaxDays int    // the maximum consecutive days away
+}
+
+// NewConsecutiveRule returns a new ConsecutiveRule after checking its
+// settings.
+func NewConsecutiveRule(name string, maxDays int) (ConsecutiveRule, error) {
+	r := ConsecutiveRule{name, maxDays}
+	switch {
+	case name == "":
+		return r, errors.New("rule name cannot be empty")
+	case maxDays < 1:
+		return r, errors.New("maximum days cannot be less than 1 day")
+	}
+	return r, nil
+}
+
+// Name returns the rule name.
+func (r ConsecutiveRule) Name() string { return r.Label }
+
+// Description describes the rule.
+func (r ConsecutiveRule) Description() string {
+	return fmt.Sprintf("%d consecutive days per visit", r.MaxDays)
+}
+
+// Limit returns the maximum consecutive days away.
+func (r ConsecutiveRule) Limit() int { return r.MaxDays }
+
+// Windows returns a window of MaxDays+1 days starting on each day from
+// start.
+func (r ConsecutiveRule) Windows(start, end time.Time) iter.Seq2[time.Time, time.Time] {
+	return rollingWindows(start, end, r.MaxDays+1)
+}
+
+// PeriodStart returns the start of the window of MaxDays+1 days ending
+// on d.
+func (r ConsecutiveRule) PeriodStart(d time.Time) time.Time {
+	return d.Add(-durationDays(r.MaxDays))
+}
+
+// defaultRule is the Schengen rule of 90 days in any 180 day window.
+var defaultRule = SchengenRule{RollingRule{DefaultRuleName, DefaultWindowSize, DefaultMaxStay}}
+
+// registry holds the rules available by name.
+var registry = struct {
+	sync.RWMutex
+	rules map[string]Rule
+}{
+	rules: map[string]Rule{
+		DefaultRuleName:  defaultRule,
+		"uk-tax-year":    YearRule{"uk-tax-year", 183, time.April, 6},
+		"calendar-year":  YearRule{"calendar-year", 183, time.January, 1},
+		"consecutive-90": ConsecutiveRule{"consecutive-90", 90},
+	},
+}
+
+// RegisterRule makes a rule available by its name to RuleByName,
+// replacing any rule already registered with that name.
+func RegisterRule(r Rule) error {
+	if r == nil || r.Name() == "" {
+		return errors.New("rule must have a name")
+	}
+	if r.Limit() < 1 {
+		return fmt.Errorf("rule %s limit cannot be less than 1 day", r.Name())
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	registry.rules[r.Name()] = r
+	return nil
+}
+
+// RuleByName returns the registered rule with the provided name.
+func RuleByName(name string) (Rule, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	r, ok := registry.rules[name]
+	if !ok {
+		return nil, fmt.Errorf("rule %q not known", name)
+	}
+	return r, nil
+}
+
+// Rules returns the registered rules, ordered by name.
+func Rules() []Rule {
+	registry.RLock()
+	defer registry.RUnlock()
+	rules := []Rule{}
+	for _, r := range registry.rules {
+		rules = append(rules, r)
+	}
+	slices.SortFunc(rules, func(a, b Rule) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return rules
+}
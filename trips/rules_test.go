@@ -0,0 +1,187 @@
+package trips
+
+import (
+	"testing"
+	"time"
+)
+
+func TestYearRule(t *testing.T) {
+
+	tp := func(s, e string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *h
+	}
+
+	rule, err := NewYearRule("tax", 10, time.April, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the year starting 6 April 2023 ends on 5 April 2024
+	if got, want := rule.PeriodStart(tp("2024-04-05", "2024-04-05").Start), tp("2023-04-06", "2023-04-06").Start; !got.Equal(want) {
+		t.Errorf("period start got %s want %s", dayFmt(got), dayFmt(want))
+	}
+	if got, want := rule.PeriodStart(tp("2024-04-06", "2024-04-06").Start), tp("2024-04-06", "2024-04-06").Start; !got.Equal(want) {
+		t.Errorf("period start got %s want %s", dayFmt(got), dayFmt(want))
+	}
+
+	testCases := []struct {
+		name        string
+		hols        []Holiday
+		breach      bool
+		longestDays int
+		windows     int
+	}{
+		{
+			name: "split over tax years",
+			hols: []Holiday{
+				tp("2024-03-30", "2024-04-05"), // 7 days
+				tp("2024-04-06", "2024-04-12"), // 7 days
+			},
+			breach:      false,
+			longestDays: 7,
+			windows:     2,
+		},
+		{
+			name: "in one tax year",
+			hols: []Holiday{
+				tp("2024-04-06", "2024-04-12"), // 7 days
+				tp("2025-03-01", "2025-03-04"), // 4 days
+			},
+			breach:      true,
+			longestDays: 11,
+			windows:     1,
+		},
+	}
+
+	calc, err := NewCalculator(WithRule(rule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trips, err := calc.Calculate(tc.hols)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := trips.Breach, tc.breach; got != want {
+				t.Errorf("breach got %t want %t", got, want)
+			}
+			if got, want := trips.DaysAway, tc.longestDays; got != want {
+				t.Errorf("days away got %d want %d", got, want)
+			}
+			windows := 0
+			for range rule.Windows(trips.Start, trips.End) {
+				windows++
+			}
+			if got, want := windows, tc.windows; got != want {
+				t.Errorf("windows got %d want %d", got, want)
+			}
+			if got, want := trips.Rule, "tax"; got != want {
+				t.Errorf("rule got %s want %s", got, want)
+			}
+		})
+	}
+
+	// only the last day of the second trip is in breach
+	trips, err := calc.Calculate(testCases[1].hols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(trips.Breaches), 1; got != want {
+		t.Fatalf("breaches got %d want %d", got, want)
+	}
+	if got, want := trips.Breaches[0].Start, tp("2025-03-04", "2025-03-04").Start; !got.Equal(want) {
+		t.Errorf("breach start got %s want %s", dayFmt(got), dayFmt(want))
+	}
+
+	// a 5 day stay does not fit in the 2024 tax year, which is in breach
+	ref := tp("2024-04-13", "2024-04-13").Start
+	entry, err := trips.EarliestEntry(ref, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entry, tp("2025-04-06", "2025-04-06").Start; !got.Equal(want) {
+		t.Errorf("earliest entry got %s want %s", dayFmt(got), dayFmt(want))
+	}
+}
+
+func TestConsecutiveRule(t *testing.T) {
+
+	tp := func(s, e string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *h
+	}
+
+	calc, err := NewCalculator(WithRule(ConsecutiveRule{"consecutive", 5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trips, err := calc.Calculate([]Holiday{
+		tp("2024-01-01", "2024-01-05"),
+		tp("2024-01-07", "2024-01-11"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trips.Breach {
+		t.Error("unexpected breach")
+	}
+
+	trips, err = calc.Calculate([]Holiday{
+		tp("2024-01-01", "2024-01-05"),
+		tp("2024-01-06", "2024-01-07"), // adjoining
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !trips.Breach {
+		t.Error("expected breach")
+	}
+	if got, want := len(trips.Breaches), 1; got != want {
+		t.Fatalf("breaches got %d want %d", got, want)
+	}
+	if got, want := trips.Breaches[0].String(), "breach Saturday 6 January 2024:Sunday 7 January 2024 (6 days)"; got != want {
+		t.Errorf("breach got %s want %s", got, want)
+	}
+}
+
+func TestRuleRegistry(t *testing.T) {
+
+	for _, name := range []string{DefaultRuleName, "uk-tax-year", "calendar-year", "consecutive-90"} {
+		if _, err := RuleByName(name); err != nil {
+			t.Errorf("rule %s: %v", name, err)
+		}
+	}
+	if _, err := RuleByName("unknown"); err == nil {
+		t.Error("expected unknown rule error")
+	}
+
+	if err := RegisterRule(YearRule{}); err == nil {
+		t.Error("expected unnamed rule error")
+	}
+	if err := RegisterRule(ConsecutiveRule{"consecutive-test", 30}); err != nil {
+		t.Fatal(err)
+	}
+	rule, err := RuleByName("consecutive-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rule.Description(), "30 consecutive days per visit"; got != want {
+		t.Errorf("description got %s want %s", got, want)
+	}
+
+	rules := Rules()
+	for i := 1; i < len(rules); i++ {
+		if rules[i-1].Name() > rules[i].Name() {
+			t.Errorf("rules not sorted: %s > %s", rules[i-1].Name(), rules[i].Name())
+		}
+	}
+}
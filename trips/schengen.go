@@ -0,0 +1,229 @@
+package trips
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Membership records the date from which a country's short stays count
+// toward the Schengen limit. A country which is not a member has a zero
+// Joined date.
+type Membership struct {
+	Country string    // ISO 3166-1 alpha-2 country code
+	Name    string    // country name
+	Joined  time.Time // date from which stays in the country count
+}
+
+// member reports if the country was a Schengen member on d.
+func (m Membership) member(d time.Time) bool {
+	return !m.Joined.IsZero() && !d.Before(m.Joined)
+}
+
+// schengenDate makes a UTC date for the membership table.
+func schengenDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// schengenMembership is the dated table of Schengen member states, and
+// of EU states which are not members, by country code. The dates are
+// those on which internal border controls were lifted; Bulgaria and
+// Romania are counted from 31 March 2024 when air and sea border
+// controls were lifted.
+var schengenMembership = map[string]Membership{
+	"AT": {"AT", "Austria", schengenDate(1997, time.December, 1)},
+	"BE": {"BE", "Belgium", schengenDate(1995, time.March, 26)},
+	"BG": {"BG", "Bulgaria", schengenDate(2024, time.March, 31)},
+	"CH": {"CH", "Switzerland", schengenDate(2008, time.December, 12)},
+	"CY": {"CY", "Cyprus", time.Time{}},
+	"CZ": {"CZ", "Czechia", schengenDate(2007, time.December, 21)},
+	"DE": {"DE", "Germany", schengenDate(1995, time.March, 26)},
+	"DK": {"DK", "Denmark", schengenDate(2001, time.March, 25)},
+	"EE": {"EE", "Estonia", schengenDate(2007, time.December, 21)},
+	"ES": {"ES", "Spain", schengenDate(1995, time.March, 26)},
+	"FI": {"FI", "Finland", schengenDate(2001, time.March, 25)},
+	"FR": {"FR", "France", schengenDate(1995, time.March, 26)},
+	"GR": {"GR", "Greece", schengenDate(2000, time.January, 1)},
+	"HR": {"HR", "Croatia", schengenDate(2023, time.January, 1)},
+	"HU": {"HU", "Hungary", schengenDate(2007, time.December, 21)},
+	"IE": {"IE", "Ireland", time.Time{}},
+	"IS": {"IS", "Iceland", schengenDate(2001, time.March, 25)},
+	"IT": {"IT", "Italy", schengenDate(1997, time.October, 26)},
+	"LI": {"LI", "Liechtenstein", schengenDate(2011, time.December, 19)},
+	"LT": {"LT", "Lithuania", schengenDate(2007, time.December, 21)},
+	"LU": {"LU", "Luxembourg", schengenDate(1995, time.March, 26)},
+	"LV": {"LV", "Latvia", schengenDate(2007, time.December, 21)},
+	"MT": {"MT", "Malta", schengenDate(2007, time.December, 21)},
+	"NL": {"NL", "Netherlands", schengenDate(1995, time.March, 26)},
+	"NO": {"NO", "Norway", schengenDate(2001, time.March, 25)},
+	"PL": {"PL", "Poland", schengenDate(2007, time.December, 21)},
+	"PT": {"PT", "Portugal", schengenDate(1995, time.March, 26)},
+	"RO": {"RO", "Romania", schengenDate(2024, time.March, 31)},
+	"SE": {"SE", "Sweden", schengenDate(2001, time.March, 25)},
+	"SI": {"SI", "Slovenia", schengenDate(2007, time.December, 21)},
+	"SK": {"SK", "Slovakia", schengenDate(2007, time.December, 21)},
+}
+
+// SchengenMembership returns the Membership of the country with the
+// provided code, if it is in the membership table.
+func SchengenMembership(country string) (Membership, bool) {
+	m, ok := schengenMembership[strings.ToUpper(country)]
+	return m, ok
+}
+
+// SchengenCountries returns the memberships in the membership table,
+// including the EU states which are not members, in country code order.
+func SchengenCountries() []Membership {
+	members := []Membership{}
+	for _, m := range schengenMembership {
+		members = append(members, m)
+	}
+	slices.SortFunc(members, func(a, b Membership) int {
+		return strings.Compare(a.Country, b.Country)
+	})
+	return members
+}
+
+// CountryRule is implemented by rules which only count days away spent
+// in certain countries. Days away in a Holiday without a Country are
+// always counted.
+type CountryRule interface {
+	Rule
+	// Excludes reports if a day away in country is not counted by the
+	// rule, with the reason.
+	Excludes(country string, d time.Time) (bool, string)
+}
+
+// SchengenRule is a RollingRule counting only the days away in states
+// which were Schengen members on each day, such as the Schengen rule of
+// 90 days in any 180 day window.
+type SchengenRule struct {
+	RollingRule
+}
+
+// Excludes reports if a day spent in country is not counted because the
+// country was not a Schengen member state on d.
+func (r SchengenRule) Excludes(country string, d time.Time) (bool, string) {
+	m, ok := SchengenMembership(country)
+	switch {
+	case !ok:
+		return true, fmt.Sprintf("%s is not a Schengen member state", strings.ToUpper(country))
+	case m.Joined.IsZero():
+		return true, fmt.Sprintf("%s is not a Schengen member state", m.Name)
+	case !m.member(d):
+		return true, fmt.Sprintf("%s joined the Schengen area on %s", m.Name, m.Joined.Format("2 January 2006"))
+	}
+	return false, ""
+}
+
+// Exclusion describes a period of a holiday not counted by a rule.
+type Exclusion struct {
+	Start   time.Time `json:"start"`   // first excluded day
+	End     time.Time `json:"end"`     // last excluded day
+	Days    int       `json:"days"`    // number of days excluded
+	Country string    `json:"country"` // the country code of the holiday
+	Reason  string    `json:"reason"`  // why the days were excluded
+}
+
+// String returns a printable version of an exclusion
+func (e Exclusion) String() string {
+	return fmt.Sprintf("%s to %s (%d days) excluded: %s", dayShortFmt(e.Start), dayShortFmt(e.End), e.Days, e.Reason)
+}
+
+// exemptReason is the reason given for days away excluded as they are
+// covered by an exempt period.
+const exemptReason = "covered by a residence permit or long-stay visa"
+
+// countedHolidays returns the parts of hols counted by rule, together
+// with the excluded parts, if the rule is a CountryRule. Days away
+// covered by one of the exempt periods are also excluded by a
+// CountryRule.
+func countedHolidays(rule Rule, hols, exempt []Holiday) ([]Holiday, []Exclusion) {
+	cr, ok := rule.(CountryRule)
+	if !ok {
+		return hols, nil
+	}
+
+	// excludes reports if day d of holiday h is excluded, why, and the
+	// country of the excluded day. A day of a merged holiday is only
+	// excluded if each of its legs covering that day is excluded.
+	excludes := func(h Holiday, d time.Time) (bool, string, string) {
+		for _, e := range exempt {
+			if !d.Before(e.Start) && !d.After(e.End) {
+				return true, exemptReason, h.Country
+			}
+		}
+		if len(h.Legs) == 0 {
+			if h.Country == "" {
+				return false, "", ""
+			}
+			excluded, reason := cr.Excludes(h.Country, d)
+			return excluded, reason, h.Country
+		}
+		excluded, reason, country := false, "", ""
+		for _, l := range h.Legs {
+			if d.Before(l.Start) || d.After(l.End) {
+				continue
+			}
+			if l.Country == "" {
+				return false, "", ""
+			}
+			if excluded, reason = cr.Excludes(l.Country, d); !excluded {
+				return false, "", ""
+			}
+			country = l.Country
+		}
+		return excluded, reason, country
+	}
+
+	counted := []Holiday{}
+	exclusions := []Exclusion{}
+	for _, h := range hols {
+		exempted := slices.ContainsFunc(exempt, func(e Holiday) bool {
+			return h.overlaps(e.Start, e.End) != nil
+		})
+		if h.Country == "" && len(h.Legs) == 0 && !exempted {
+			counted = append(counted, h)
+			continue
+		}
+		// split the holiday into runs of counted or excluded days
+		var run *Holiday
+		var exclusion *Exclusion
+		for d := h.Start; !d.After(h.End); d = d.Add(durationDays(1)) {
+			excluded, reason, country := excludes(h, d)
+			if !excluded {
+				if exclusion != nil {
+					exclusions = append(exclusions, *exclusion)
+					exclusion = nil
+				}
+				if run == nil {
+					run = &Holiday{Start: d, Country: h.Country}
+				}
+				run.End = d
+				continue
+			}
+			if run != nil {
+				run.Duration = run.days()
+				counted = append(counted, *run)
+				run = nil
+			}
+			if exclusion == nil || exclusion.Reason != reason || exclusion.Country != country {
+				if exclusion != nil {
+					exclusions = append(exclusions, *exclusion)
+				}
+				exclusion = &Exclusion{Start: d, Country: country, Reason: reason}
+			}
+			exclusion.End = d
+			exclusion.Days++
+		}
+		if run != nil {
+			run.Duration = run.days()
+			counted = append(counted, *run)
+		}
+		if exclusion != nil {
+			exclusions = append(exclusions, *exclusion)
+		}
+	}
+	return counted, exclusions
+}
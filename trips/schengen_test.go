@@ -0,0 +1,227 @@
+package trips
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSchengenMembership(t *testing.T) {
+
+	tp := func(s string) Holiday {
+		h, err := newHolidayFromStr(s, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *h
+	}
+
+	tests := []struct {
+		country  string
+		date     string
+		excluded bool
+		reason   string
+	}{
+		{"FR", "2024-01-01", false, ""},
+		{"fr", "2024-01-01", false, ""},
+		{"IE", "2024-01-01", true, "Ireland is not a Schengen member state"},
+		{"CY", "2024-01-01", true, "Cyprus is not a Schengen member state"},
+		{"BG", "2024-03-30", true, "Bulgaria joined the Schengen area on 31 March 2024"},
+		{"BG", "2024-03-31", false, ""},
+		{"HR", "2022-12-31", true, "Croatia joined the Schengen area on 1 January 2023"},
+		{"US", "2024-01-01", true, "US is not a Schengen member state"},
+	}
+	for _, tt := range tests {
+		excluded, reason := defaultRule.Excludes(tt.country, tp(tt.date).Start)
+		if got, want := excluded, tt.excluded; got != want {
+			t.Errorf("%s on %s excluded got %t want %t", tt.country, tt.date, got, want)
+		}
+		if got, want := reason, tt.reason; got != want {
+			t.Errorf("%s on %s reason got %q want %q", tt.country, tt.date, got, want)
+		}
+	}
+
+	countries := SchengenCountries()
+	if got, want := len(countries), 31; got != want {
+		t.Errorf("countries got %d want %d", got, want)
+	}
+	if got, want := countries[0].Country, "AT"; got != want {
+		t.Errorf("first country got %s want %s", got, want)
+	}
+}
+
+func TestCountryExclusions(t *testing.T) {
+
+	tp := func(s, e, c string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Country = c
+		return *h
+	}
+
+	hols := []Holiday{
+		tp("2024-01-01", "2024-02-29", "FR"), // 60 days
+		tp("2024-03-01", "2024-03-20", "IE"), // 20 days, excluded
+		tp("2024-03-25", "2024-04-09", "BG"), // 6 days excluded, 10 counted
+		tp("2024-04-10", "2024-04-20", ""),   // 11 days
+	}
+
+	trips, err := Calculate(hols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trips.Breach {
+		t.Error("unexpected breach")
+	}
+	if got, want := trips.DaysAway, 81; got != want {
+		t.Errorf("days away got %d want %d", got, want)
+	}
+	if got, want := len(trips.Exclusions), 2; got != want {
+		t.Fatalf("exclusions got %d want %d", got, want)
+	}
+	if got, want := trips.Exclusions[1].String(), "25/03/2024 to 30/03/2024 (6 days) excluded: Bulgaria joined the Schengen area on 31 March 2024"; got != want {
+		t.Errorf("exclusion got %q want %q", got, want)
+	}
+	if got, want := trips.DaysRemaining(trips.End), 9; got != want {
+		t.Errorf("days remaining got %d want %d", got, want)
+	}
+
+	// a rule without country exclusions counts every day
+	calc, err := NewCalculator(WithWindowSize(180), WithMaxStay(90))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trips, err = calc.Calculate(hols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !trips.Breach {
+		t.Error("expected breach")
+	}
+	if len(trips.Exclusions) != 0 {
+		t.Error("unexpected exclusions")
+	}
+
+	// a stay may not overlap an excluded trip
+	_, err = PlanStay(hols, tp("2024-03-10", "2024-03-10", "").Start)
+	if err == nil {
+		t.Error("expected overlap error")
+	}
+}
+
+func TestHolidayCountryDecoding(t *testing.T) {
+
+	hols, err := HolidaysJSONDecoder([]byte(`[{"Start":"2024-01-01","End":"2024-01-02","Country":"ie"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hols[0].Country, "IE"; got != want {
+		t.Errorf("json country got %s want %s", got, want)
+	}
+
+	_, err = HolidaysJSONDecoder([]byte(`[{"Start":"2024-01-01","End":"2024-01-02","Country":"IRL"}]`))
+	if err == nil {
+		t.Error("expected invalid country error")
+	}
+
+	vals, err := url.ParseQuery("Start=2024-01-01&End=2024-01-02&Country=FR&Start=2024-02-01&End=2024-02-02&Country=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hols, err = HolidaysURLDecoder(vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(hols), 2; got != want {
+		t.Fatalf("url holidays got %d want %d", got, want)
+	}
+	if hols[0].Country != "FR" || hols[1].Country != "" {
+		t.Errorf("unexpected url countries %q %q", hols[0].Country, hols[1].Country)
+	}
+	if got, want := HolidaysURLEncode(hols), "Start=2024-01-01&End=2024-01-02&Country=FR&Start=2024-02-01&End=2024-02-02&Country="; got != want {
+		t.Errorf("url encode got %s want %s", got, want)
+	}
+
+	vals, err = url.ParseQuery("Start=2024-01-01&End=2024-01-02&Start=2024-02-01&End=2024-02-02&Country=FR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = HolidaysURLDecoder(vals)
+	if err == nil {
+		t.Error("expected country argument count error")
+	}
+}
+
+func TestExemptPeriods(t *testing.T) {
+
+	vals, err := url.ParseQuery(
+		"Start=2024-01-01&End=2024-03-31&Type=trip&" + // 91 days
+			"Start=2024-03-01&End=2024-06-30&Type=exempt&" + // residence permit
+			"Start=2024-07-01&End=2024-07-10&Type=trip", // 10 days
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hols, err := HolidaysURLDecoder(vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hols[1].Exempt || hols[0].Exempt {
+		t.Fatal("unexpected exempt status")
+	}
+
+	trips, err := Calculate(hols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trips.Breach {
+		t.Error("unexpected breach")
+	}
+	if got, want := len(trips.OriginalHolidays), 2; got != want {
+		t.Errorf("holidays got %d want %d", got, want)
+	}
+	if got, want := len(trips.Exemptions), 1; got != want {
+		t.Errorf("exemptions got %d want %d", got, want)
+	}
+	// the most days away in a window are the 60 days in January and
+	// February
+	if got, want := trips.DaysAway, 60; got != want {
+		t.Errorf("days away got %d want %d", got, want)
+	}
+	if got, want := trips.Exclusions[0].String(), "01/03/2024 to 31/03/2024 (31 days) excluded: "+exemptReason; got != want {
+		t.Errorf("exclusion got %q want %q", got, want)
+	}
+
+	// the exempt period is not counted by other rules
+	calendar, err := RuleByName("calendar-year")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trips, err = Calculate(hols, WithRule(calendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := trips.DaysAway, 101; got != want {
+		t.Errorf("calendar year days away got %d want %d", got, want)
+	}
+
+	// a stay may be planned during the exempt period, up to the day
+	// before the next trip
+	plan, err := PlanStay(hols, hols[1].Start.AddDate(0, 2, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := plan.Days, 61; got != want {
+		t.Errorf("plan days got %d want %d", got, want)
+	}
+
+	if got, want := HolidaysURLEncode(hols), "Start=2024-01-01&End=2024-03-31&Type=trip&Start=2024-03-01&End=2024-06-30&Type=exempt&Start=2024-07-01&End=2024-07-10&Type=trip"; got != want {
+		t.Errorf("url encode got %s want %s", got, want)
+	}
+
+	_, err = HolidaysJSONDecoder([]byte(`[{"Start":"2024-01-01","End":"2024-01-02","Type":"visa"}]`))
+	if err == nil {
+		t.Error("expected invalid type error")
+	}
+}
@@ -0,0 +1,72 @@
+package trips
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// TimelineDay reports, for a calendar day, the number of days away in
+// the rule's assessment period up to that day, such as the window ending
+// on that day, and the allowance remaining.
+type TimelineDay struct {
+	Date          time.Time `json:"date"`          // the calendar day
+	Away          bool      `json:"away"`          // if the day was spent away
+	DaysUsed      int       `json:"daysUsed"`      // days away in the period up to Date
+	DaysRemaining int       `json:"daysRemaining"` // MaxStay less DaysUsed, not less than zero
+}
+
+// Timeline returns a TimelineDay for each calendar day from Trips.Start
+// to Trips.End, followed by a further horizon days after Trips.End.
+func (trips *Trips) Timeline(horizon int) ([]TimelineDay, error) {
+	rule, err := trips.currentRule()
+	if err != nil {
+		return nil, err
+	}
+	if len(trips.OriginalHolidays) < 1 {
+		return nil, errors.New("no holidays provided")
+	}
+	if horizon < 0 {
+		return nil, errors.New("horizon cannot be negative")
+	}
+
+	l := trips.countedLedger(rule)
+	all := newLedger(trips.OriginalHolidays)
+	end := trips.End.Add(durationDays(horizon))
+
+	timeline := []TimelineDay{}
+	for d := trips.Start; !d.After(end); d = d.Add(durationDays(1)) {
+		used := l.count(rule.PeriodStart(d), d)
+		timeline = append(timeline, TimelineDay{
+			Date:          d,
+			Away:          all.isAway(d),
+			DaysUsed:      used,
+			DaysRemaining: max(trips.MaxStay-used, 0),
+		})
+	}
+	return timeline, nil
+}
+
+// TimelineAsCSV writes a timeline as CSV with a header row.
+func TimelineAsCSV(timeline []TimelineDay, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"date", "away", "days_used", "days_remaining"})
+	if err != nil {
+		return err
+	}
+	for _, td := range timeline {
+		err := cw.Write([]string{
+			td.Date.Format("2006-01-02"),
+			strconv.FormatBool(td.Away),
+			strconv.Itoa(td.DaysUsed),
+			strconv.Itoa(td.DaysRemaining),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,70 @@
+package trips
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTimeline(t *testing.T) {
+
+	tp := func(s, e string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *h
+	}
+
+	calc, err := NewCalculator(WithWindowSize(5), WithMaxStay(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trips, err := calc.Calculate([]Holiday{
+		tp("2023-01-01", "2023-01-02"),
+		tp("2023-01-04", "2023-01-05"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeline, err := trips.Timeline(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 2 . 4 5 | . . . .
+	wantUsed := []int{1, 2, 2, 3, 4, 3, 2, 2, 1}
+	if got, want := len(timeline), len(wantUsed); got != want {
+		t.Fatalf("timeline length got %d want %d", got, want)
+	}
+	for i, td := range timeline {
+		if got, want := td.DaysUsed, wantUsed[i]; got != want {
+			t.Errorf("day %d used got %d want %d", i, got, want)
+		}
+		if got, want := td.DaysRemaining, max(3-wantUsed[i], 0); got != want {
+			t.Errorf("day %d remaining got %d want %d", i, got, want)
+		}
+		// the timeline should agree with DaysUsed
+		if got, want := td.DaysUsed, trips.DaysUsed(td.Date); got != want {
+			t.Errorf("day %d used got %d DaysUsed %d", i, got, want)
+		}
+	}
+	if !timeline[0].Away || timeline[2].Away {
+		t.Error("unexpected away status")
+	}
+
+	_, err = trips.Timeline(-1)
+	if err == nil {
+		t.Error("expected negative horizon error")
+	}
+
+	var csv strings.Builder
+	err = TimelineAsCSV(timeline[:2], &csv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "date,away,days_used,days_remaining\n2023-01-01,true,1,2\n2023-01-02,true,2,1\n"
+	if got := csv.String(); got != want {
+		t.Errorf("csv got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -7,30 +7,32 @@ import (
 	"time"
 )
 
-var (
-	// WindowMaxDays maximum window of days to calculate over
-	WindowMaxDays int = 180
-	// CompoundStayMaxDays is the longest allowed compound trip length
-	CompoundStayMaxDays int = 90
-)
-
 // Trips describe a set of holidays and their calculation results.
 //
 // Details following calculation are largely held in the `window`
 // embedded struct which reports the window calculated to have longest
 // number of days away. Where more than one window has the same number
-// of days away, the window with the earliest date is used.
+// of days away, the window with the earliest date is used. Every period
+// in breach of MaxStay is reported in Breaches.
 type Trips struct {
-	WindowSize       int       // size of window of days to search over
-	MaxStay          int       // the maximum length of trips in window
-	Start, End       time.Time // the start and end of the overall holidays
-	startFrame       time.Time // date at which to start calculating windows
-	endFrame         time.Time // date at which to stop calculating windows
-	OriginalHolidays []Holiday // list of holidays under consideration
-	Window                     // the window with the longest compound trip length
-	LongestDaysAway  int       // used during window calculations
-	Error            error     `json:"error"`  // calculation errors
-	Breach           bool      `json:"breach"` // if CompoundStayMaxDays is breached
+	Rule             string       `json:"rule"`        // name of the rule calculated
+	Description      string       `json:"description"` // description of the rule
+	rule             Rule         // the rule calculated
+	WindowSize       int          // size of window of days to search over, if fixed
+	MaxStay          int          // the maximum length of trips in window
+	Start, End       time.Time    // the start and end of the overall holidays
+	startFrame       time.Time    // date at which to start calculating windows
+	endFrame         time.Time    // date at which to stop calculating windows
+	OriginalHolidays []Holiday    // list of holidays under consideration
+	Window                        // the window with the longest compound trip length
+	LongestDaysAway  int          // used during window calculations
+	Error            error        `json:"error"`      // calculation errors
+	Breach           bool         `json:"breach"`     // if MaxStay is breached
+	Breaches         []Breach     `json:"breaches"`   // each period in breach
+	Results          []RuleResult `json:"results"`    // results for each rule calculated
+	Exemptions       []Holiday    `json:"exemptions"` // exempt periods, such as under a residence permit
+	Exclusions       []Exclusion  `json:"exclusions"` // days away not counted by the rule
+	Merges           []Merge      `json:"merges"`     // overlapping trips merged before calculating
 }
 
 // String returns a simple string representation of trips
@@ -49,43 +51,86 @@ func (trips Trips) String() string {
 	)
 }
 
-// newTrips makes a new Trips struct after checking the overrideable
-// package variables are ok
-func newTrips() (*Trips, error) {
-	trips := Trips{}
-	trips.Breach = false
-	if WindowMaxDays < 3 {
-		return &trips, errors.New("window size cannot be less than 3 days")
+// newTrips makes a new Trips struct using the settings of the provided
+// Calculator
+func newTrips(c *Calculator) *Trips {
+	return newRuleTrips(c.rule)
+}
+
+// newRuleTrips makes a new Trips struct to be calculated with rule.
+func newRuleTrips(rule Rule) *Trips {
+	trips := &Trips{
+		Rule:        rule.Name(),
+		Description: rule.Description(),
+		rule:        rule,
+		MaxStay:     rule.Limit(),
 	}
-	if CompoundStayMaxDays < 2 {
-		return &trips, errors.New("maximum stay cannot be less than 2 days")
+	switch r := rule.(type) {
+	case RollingRule:
+		trips.WindowSize = r.WindowSize
+	case SchengenRule:
+		trips.WindowSize = r.WindowSize
+	case ConsecutiveRule:
+		trips.WindowSize = r.MaxDays + 1
 	}
-	if CompoundStayMaxDays > WindowMaxDays {
-		return &trips, errors.New("maximum stay cannot be greater than the window size")
+	return trips
+}
+
+// currentRule returns the rule with which the trips are calculated. If
+// the trips were not made by a Calculator, a RollingRule is made from
+// WindowSize and MaxStay.
+func (trips *Trips) currentRule() (Rule, error) {
+	if trips.rule != nil {
+		return trips.rule, nil
+	}
+	if trips.MaxStay == 0 || trips.WindowSize == 0 {
+		return nil, errors.New("trip not properly initialised")
 	}
-	trips.WindowSize = WindowMaxDays
-	trips.MaxStay = CompoundStayMaxDays
-	return &trips, nil
+	return RollingRule{trips.Rule, trips.WindowSize, trips.MaxStay}, nil
 }
 
-// addHoliday adds a holiday to Trips, checking for validity and overlaps
+// countedLedger returns a ledger of the days away counted by rule,
+// which for a CountryRule excludes days spent in countries not counted
+// by the rule and days covered by exempt periods.
+func (trips *Trips) countedLedger(rule Rule) *ledger {
+	counted, _ := countedHolidays(rule, trips.OriginalHolidays, trips.Exemptions)
+	return newLedger(counted)
+}
+
+// markStay marks the days of a proposed stay from start to end counted
+// by rule in the ledger l, so that days covered by exempt periods are
+// not counted by a CountryRule.
+func (trips *Trips) markStay(l *ledger, rule Rule, start, end time.Time) {
+	counted, _ := countedHolidays(rule, []Holiday{{Start: start, End: end}}, trips.Exemptions)
+	for _, h := range counted {
+		l.mark(h.Start, h.End)
+	}
+}
+
+// addHoliday adds a holiday to Trips, checking for validity and overlaps.
+// Exempt periods are recorded in Trips.Exemptions, and may overlap
+// holidays. The indices reported by errors are those of the holidays
+// in Trips.OriginalHolidays; use Validate to check holidays by their
+// input order.
 func (trips *Trips) addHoliday(h Holiday) error {
 
 	// check validity of this holiday
+	index := len(trips.OriginalHolidays)
 	if h.End.Before(h.Start) {
-		return fmt.Errorf("start date %s after %s", dayShortFmt(h.Start), dayShortFmt(h.End))
+		return ReversedDatesError{index, h.Start, h.End}
+	}
+	if h.Exempt {
+		h.Duration = h.days()
+		trips.Exemptions = append(trips.Exemptions, h)
+		return nil
 	}
 	// check no overlaps
-	for _, o := range trips.OriginalHolidays {
+	for i, o := range trips.OriginalHolidays {
 		if ok := o.overlaps(h.Start, h.End); ok != nil {
-			return fmt.Errorf(
-				"trip %s to %s overlaps with %s to %s",
-				dayShortFmt(h.Start), dayShortFmt(h.End), dayShortFmt(o.Start), dayShortFmt(o.End),
-			)
+			return OverlapError{index, i, h, o}
 		}
 	}
-	// set window dates; endFrame gets reset during calculation, so use
-	// Start and End for overall start/end
+	// set window dates
 	x := Holiday{}
 	if trips.startFrame == x.Start || trips.startFrame.After(h.Start) {
 		trips.startFrame = h.Start
@@ -131,75 +176,146 @@ func (w Window) String() string {
 	return s
 }
 
+// Breach describes a period of days away on each of which the window
+// ending on that day had more than MaxStay days away. A breach runs
+// from its first to last offending day, and continues over days not
+// spent away so long as the next day away is also offending.
+type Breach struct {
+	Start    time.Time `json:"start"`    // first offending day
+	End      time.Time `json:"end"`      // last offending day
+	DaysAway int       `json:"daysAway"` // peak days away in windows ending in the breach
+	Holidays []Holiday `json:"holidays"` // holidays contributing to the breach
+}
+
+// String returns a printable version of a breach
+func (b Breach) String() string {
+	tpl := "breach %s:%s (%d days)"
+	return fmt.Sprintf(tpl, dayFmt(b.Start), dayFmt(b.End), b.DaysAway)
+}
+
+// breaches finds each Breach of MaxStay under rule, populating
+// Trips.Breaches.
+func (trips *Trips) breaches(rule Rule, l *ledger) {
+	oneDay := durationDays(1)
+
+	var breach *Breach
+	closeBreach := func() {
+		if breach == nil {
+			return
+		}
+		// record the holidays contributing to the periods ending in
+		// the breach
+		from := rule.PeriodStart(breach.Start)
+		for _, h := range trips.OriginalHolidays {
+			if h.overlaps(from, breach.End) != nil {
+				breach.Holidays = append(breach.Holidays, h)
+			}
+		}
+		trips.Breaches = append(trips.Breaches, *breach)
+		breach = nil
+	}
+
+	for d := trips.Start; !d.After(trips.End); d = d.Add(oneDay) {
+		if !l.isAway(d) {
+			continue
+		}
+		// used is the days away in the period ending on d
+		used := l.count(rule.PeriodStart(d), d)
+		if used <= trips.MaxStay {
+			closeBreach()
+			continue
+		}
+		if breach == nil {
+			breach = &Breach{Start: d}
+		}
+		breach.End = d
+		breach.DaysAway = max(breach.DaysAway, used)
+	}
+	closeBreach()
+}
+
+// window returns the Window from start to end, decorating a copy of
+// trips.OriginalHolidays with any partial holidays overlapping the
+// window.
+func (trips *Trips) window(start, end time.Time) Window {
+	w := Window{}
+	w.Start = start
+	w.End = end
+
+	w.Holidays = make([]Holiday, len(trips.OriginalHolidays))
+	copy(w.Holidays, trips.OriginalHolidays)
+
+	for i, t := range w.Holidays {
+		partialHoliday := t.overlaps(w.Start, w.End)
+		if partialHoliday == nil {
+			continue
+		}
+		partialHoliday.Duration = partialHoliday.days()
+		w.Overlaps++
+		w.DaysAway += partialHoliday.Duration
+		w.Holidays[i].PartialHoliday = partialHoliday
+		if w.OverlapStart.IsZero() {
+			w.OverlapStart = partialHoliday.Start
+		}
+		if w.OverlapEnd.Before(partialHoliday.End) {
+			w.OverlapEnd = partialHoliday.End
+		}
+	}
+	return w
+}
+
 // calculate performs the window calculation returning the Trips struct
 // and error for returning by Calculate.
 //
 // The window with the longest trip (`window.DaysAway`) are embedded in
-// the Trips struct.
+// the Trips struct. The windows searched are provided by the rule.
 //
-// The window calculator could be moved to goroutines to speed up
-// processing, although it seems sufficiently fast already.
+// The days away are recorded in a ledger of prefix sums so that the
+// days away in each window are found in constant time, making the
+// calculation linear in the number of days covered by the holidays.
+// Only the window with the longest trip is decorated with the holidays
+// overlapping it.
 func (trips *Trips) calculate() (*Trips, error) {
 
 	// check trips has been properly initialised and there are holidays
 	// to process
-	if trips.MaxStay == 0 || trips.WindowSize == 0 {
-		return trips, errors.New("trip not properly initialised")
+	rule, err := trips.currentRule()
+	if err != nil {
+		return trips, err
 	}
 	if len(trips.OriginalHolidays) < 1 {
 		return trips, errors.New("no holidays provided")
 	}
 
-	// set suitable frame start and end in which to calculate windows
-	windowDuration := durationDays(trips.WindowSize - 1) // remove last day
-	trips.endFrame = trips.endFrame.Add(-windowDuration)
-	if trips.endFrame.Before(trips.startFrame) {
-		trips.endFrame = trips.startFrame
-	}
-
-	// generate a series of windows starting on each day between
-	// trips.startFrame and trips.endFrame.
-	//
-	// For each window, if the windows.DaysAway > Trips.LongestDaysAway,
-	// embed the window in the Trips struct.
+	// count the days away in each of the rule's windows for the trips,
+	// which for a rolling rule start on each day from the first holiday.
 	//
-	// This loop could be moved to a set of goroutines although
-	// peformance for very large windows is still very quick, around
-	// 0.005s for a 720 day/180 stay use case.
-	for d := trips.startFrame; !d.After(trips.endFrame); d = d.Add(durationDays(1)) {
-		w := Window{}
-		w.Start = d
-		w.End = d.Add(windowDuration)
-
-		w.Holidays = make([]Holiday, len(trips.OriginalHolidays))
-		copy(w.Holidays, trips.OriginalHolidays)
-
-		for i, t := range w.Holidays {
-			partialHoliday := t.overlaps(w.Start, w.End)
-			if partialHoliday == nil {
-				continue
-			}
-			partialHoliday.Duration = partialHoliday.days()
-			w.Overlaps++
-			w.DaysAway += partialHoliday.Duration
-			w.Holidays[i].PartialHoliday = partialHoliday
-			if w.OverlapStart.IsZero() {
-				w.OverlapStart = partialHoliday.Start
-			}
-			if w.OverlapEnd.Before(partialHoliday.End) {
-				w.OverlapEnd = partialHoliday.End
-			}
-
-			// set longest trip/window if appropriate
-			if w.DaysAway > trips.LongestDaysAway {
-				trips.LongestDaysAway = w.DaysAway
-				trips.Window = w
-			}
-			if w.DaysAway > trips.MaxStay {
-				trips.Breach = true
-			}
+	// For each window, if the days away > Trips.LongestDaysAway, record
+	// the window for embedding in the Trips struct.
+	var counted []Holiday
+	counted, trips.Exclusions = countedHolidays(rule, trips.OriginalHolidays, trips.Exemptions)
+	l := newLedger(counted)
+	var longestStart, longestEnd time.Time
+	for start, end := range rule.Windows(trips.Start, trips.End) {
+		daysAway := l.count(start, end)
+		if daysAway > trips.LongestDaysAway {
+			trips.LongestDaysAway = daysAway
+			longestStart, longestEnd = start, end
+		}
+		if daysAway > trips.MaxStay {
+			trips.Breach = true
 		}
 	}
+	if trips.LongestDaysAway > 0 {
+		trips.Window = trips.window(longestStart, longestEnd)
+		// report only the days counted by the rule
+		trips.Window.DaysAway = trips.LongestDaysAway
+	}
+
+	// record each breach period
+	if trips.Breach {
+		trips.breaches(rule, l)
+	}
 
 	// enable for trip struct dumping for investigation or test file
 	// creation.
@@ -208,33 +324,17 @@ func (trips *Trips) calculate() (*Trips, error) {
 	return trips, nil
 }
 
-// Calculate initialises a new Trips struct with the package variables
-// WindowSize (the number of days over which to do the calculation) and
-// MaxStay (the length of compound holidays) and then sequentially adds
-// holidays, then runs the calculation, returning the resulting Trips
-// object and embedded window (with the longest DaysAway), and error if
-// any.
-func Calculate(hols []Holiday) (*Trips, error) {
-
-	// initialise Trips
-	trips, err := newTrips()
-	trips.Error = err
-	if trips.Error != nil {
-		return trips, trips.Error
+// Calculate calculates the provided holidays using the default
+// Calculator, which applies the Schengen rule of 90 days in any 180 day
+// window, or a Calculator made with the provided options, such as
+// WithRule. See Calculator.Calculate for details.
+func Calculate(hols []Holiday, options ...Option) (*Trips, error) {
+	if len(options) == 0 {
+		return defaultCalculator.Calculate(hols)
 	}
-
-	// add holidays
-	if len(hols) == 0 {
-		trips.Error = errors.New("no trips were provided to calculate")
-		return trips, trips.Error
+	c, err := NewCalculator(options...)
+	if err != nil {
+		return &Trips{Error: err}, err
 	}
-	for _, h := range hols {
-		trips.Error = trips.addHoliday(h)
-		if trips.Error != nil {
-			return trips, trips.Error
-		}
-	}
-
-	// perform the calculation
-	return trips.calculate()
+	return c.Calculate(hols)
 }
@@ -2,19 +2,20 @@ package trips
 
 import (
 	"encoding/json"
+	"math/rand"
+	"reflect"
 	"testing"
+	"time"
 )
 
 // overlap detection checks
 func TestTripAdditions(t *testing.T) {
 
-	WindowMaxDays = 5
-	CompoundStayMaxDays = 3
-
-	trips, err := newTrips()
+	calc, err := NewCalculator(WithWindowSize(5), WithMaxStay(3))
 	if err != nil {
-		t.Fatalf("could not make trips %v", err)
+		t.Fatalf("could not make calculator %v", err)
 	}
+	trips := newTrips(calc)
 
 	tp := func(s, e string) Holiday {
 		h, err := newHolidayFromStr(s, e)
@@ -52,8 +53,10 @@ func TestTripAdditions(t *testing.T) {
 //	1    2    3         4       <- max stay
 func TestTrips(t *testing.T) {
 
-	WindowMaxDays = 5
-	CompoundStayMaxDays = 4
+	calc, err := NewCalculator(WithWindowSize(5), WithMaxStay(4))
+	if err != nil {
+		t.Fatalf("could not make calculator %v", err)
+	}
 
 	tp := func(s, e string) Holiday {
 		h, err := newHolidayFromStr(s, e)
@@ -72,7 +75,7 @@ func TestTrips(t *testing.T) {
 		tp("2023-01-24", "2023-01-25"),
 	}
 
-	trips, err := Calculate(hols)
+	trips, err := calc.Calculate(hols)
 	if err != nil {
 		t.Fatalf("calculation error %v", err)
 	}
@@ -116,12 +119,14 @@ func TestTrips(t *testing.T) {
 
 }
 
-// the same test as above, but with a lower CompoundStayMaxDays to
+// the same test as above, but with a lower maximum stay to
 // breach
 func TestTripsToBreach(t *testing.T) {
 
-	WindowMaxDays = 5
-	CompoundStayMaxDays = 3
+	calc, err := NewCalculator(WithWindowSize(5), WithMaxStay(3))
+	if err != nil {
+		t.Fatalf("could not make calculator %v", err)
+	}
 
 	tp := func(s, e string) Holiday {
 		h, err := newHolidayFromStr(s, e)
@@ -140,7 +145,7 @@ func TestTripsToBreach(t *testing.T) {
 		tp("2023-01-24", "2023-01-25"),
 	}
 
-	trips, err := Calculate(hols)
+	trips, err := calc.Calculate(hols)
 	if err != nil {
 		t.Fatalf("calculation error %v", err)
 	}
@@ -181,13 +186,11 @@ func TestTripsToBreach(t *testing.T) {
 
 func TestTripsLonger(t *testing.T) {
 
-	WindowMaxDays = 40
-	CompoundStayMaxDays = 35
-
-	trips, err := newTrips()
+	calc, err := NewCalculator(WithWindowSize(40), WithMaxStay(35))
 	if err != nil {
-		t.Fatalf("could not make trips %v", err)
+		t.Fatalf("could not make calculator %v", err)
 	}
+	trips := newTrips(calc)
 
 	adder := func(s, e string) error {
 		h, err := newHolidayFromStr(s, e)
@@ -226,13 +229,11 @@ func TestTripsLonger(t *testing.T) {
 // test performance over a much larger window and larger stay size
 func TestTripsLong(t *testing.T) {
 
-	WindowMaxDays = 720
-	CompoundStayMaxDays = 180
-
-	trips, err := newTrips()
+	calc, err := NewCalculator(WithWindowSize(720), WithMaxStay(180))
 	if err != nil {
-		t.Fatalf("could not make trips %v", err)
+		t.Fatalf("could not make calculator %v", err)
 	}
+	trips := newTrips(calc)
 
 	tp := func(s, e string) Holiday {
 		h, err := newHolidayFromStr(s, e)
@@ -275,3 +276,235 @@ func TestTripsLong(t *testing.T) {
 	}
 
 }
+
+// TestTripsBreaches checks that each separate breach is reported
+func TestTripsBreaches(t *testing.T) {
+
+	tp := func(s, e string) Holiday {
+		h, err := newHolidayFromStr(s, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *h
+	}
+
+	type breachResult struct {
+		start, end string
+		daysAway   int
+		holidays   int
+	}
+
+	testCases := []struct {
+		name     string
+		maxStay  int
+		hols     []Holiday
+		breaches []breachResult
+	}{
+		{
+			name:    "no breach",
+			maxStay: 5,
+			hols: []Holiday{
+				tp("2023-01-01", "2023-01-05"),
+				tp("2023-02-01", "2023-02-05"),
+			},
+			breaches: nil,
+		},
+		{
+			name:    "three breaches",
+			maxStay: 5,
+			hols: []Holiday{
+				tp("2023-01-01", "2023-01-06"),
+				tp("2023-02-01", "2023-02-07"),
+				tp("2023-03-01", "2023-03-03"),
+				tp("2023-03-05", "2023-03-07"),
+			},
+			breaches: []breachResult{
+				{"2023-01-06", "2023-01-06", 6, 1},
+				{"2023-02-06", "2023-02-07", 7, 1},
+				{"2023-03-07", "2023-03-07", 6, 2},
+			},
+		},
+		{
+			name:    "breach continues over days at home",
+			maxStay: 3,
+			hols: []Holiday{
+				tp("2023-01-01", "2023-01-03"),
+				tp("2023-01-05", "2023-01-05"),
+				tp("2023-01-07", "2023-01-07"),
+			},
+			breaches: []breachResult{
+				{"2023-01-05", "2023-01-07", 5, 3},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calc, err := NewCalculator(WithWindowSize(10), WithMaxStay(tc.maxStay))
+			if err != nil {
+				t.Fatal(err)
+			}
+			trips, err := calc.Calculate(tc.hols)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := trips.Breach, len(tc.breaches) > 0; got != want {
+				t.Errorf("breach got %t want %t", got, want)
+			}
+			if got, want := len(trips.Breaches), len(tc.breaches); got != want {
+				t.Fatalf("breaches got %d want %d: %v", got, want, trips.Breaches)
+			}
+			for i, b := range tc.breaches {
+				got := trips.Breaches[i]
+				if dayShortFmt(got.Start) != dayShortFmt(tp(b.start, b.start).Start) ||
+					dayShortFmt(got.End) != dayShortFmt(tp(b.end, b.end).End) {
+					t.Errorf("breach %d got %s want %s to %s", i, got, b.start, b.end)
+				}
+				if got.DaysAway != b.daysAway {
+					t.Errorf("breach %d days away got %d want %d", i, got.DaysAway, b.daysAway)
+				}
+				if len(got.Holidays) != b.holidays {
+					t.Errorf("breach %d holidays got %d want %d", i, len(got.Holidays), b.holidays)
+				}
+			}
+		})
+	}
+}
+
+// calculateLegacy is the original window calculation, which rebuilds
+// and decorates the holidays for every window, retained to check that
+// calculate provides identical results and for benchmarking.
+func calculateLegacy(trips *Trips) *Trips {
+
+	windowDuration := durationDays(trips.WindowSize - 1) // remove last day
+	trips.endFrame = trips.endFrame.Add(-windowDuration)
+	if trips.endFrame.Before(trips.startFrame) {
+		trips.endFrame = trips.startFrame
+	}
+
+	for d := trips.startFrame; !d.After(trips.endFrame); d = d.Add(durationDays(1)) {
+		w := Window{}
+		w.Start = d
+		w.End = d.Add(windowDuration)
+
+		w.Holidays = make([]Holiday, len(trips.OriginalHolidays))
+		copy(w.Holidays, trips.OriginalHolidays)
+
+		for i, t := range w.Holidays {
+			partialHoliday := t.overlaps(w.Start, w.End)
+			if partialHoliday == nil {
+				continue
+			}
+			partialHoliday.Duration = partialHoliday.days()
+			w.Overlaps++
+			w.DaysAway += partialHoliday.Duration
+			w.Holidays[i].PartialHoliday = partialHoliday
+			if w.OverlapStart.IsZero() {
+				w.OverlapStart = partialHoliday.Start
+			}
+			if w.OverlapEnd.Before(partialHoliday.End) {
+				w.OverlapEnd = partialHoliday.End
+			}
+			if w.DaysAway > trips.LongestDaysAway {
+				trips.LongestDaysAway = w.DaysAway
+				trips.Window = w
+			}
+			if w.DaysAway > trips.MaxStay {
+				trips.Breach = true
+			}
+		}
+	}
+	return trips
+}
+
+// syntheticHolidays makes count non-overlapping holidays in a random
+// order, each of up to maxLength days separated by up to maxGap days.
+func syntheticHolidays(seed int64, count, maxLength, maxGap int) []Holiday {
+	rng := rand.New(rand.NewSource(seed))
+	hols := []Holiday{}
+	d := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for range count {
+		start := d.Add(durationDays(rng.Intn(maxGap) + 1))
+		end := start.Add(durationDays(rng.Intn(maxLength)))
+		hols = append(hols, Holiday{Start: start, End: end})
+		d = end
+	}
+	rng.Shuffle(len(hols), func(i, j int) { hols[i], hols[j] = hols[j], hols[i] })
+	return hols
+}
+
+// newSyntheticTrips makes a Trips from the provided holidays.
+func newSyntheticTrips(tb testing.TB, calc *Calculator, hols []Holiday) *Trips {
+	trips := newTrips(calc)
+	for _, h := range hols {
+		if err := trips.addHoliday(h); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return trips
+}
+
+// TestCalculateMatchesLegacy checks that calculate provides the same
+// results as the original calculation over a range of synthetic inputs
+func TestCalculateMatchesLegacy(t *testing.T) {
+
+	for i, tc := range []struct {
+		windowSize, maxStay   int
+		count, length, maxGap int
+	}{
+		{5, 3, 10, 3, 4},
+		{10, 5, 30, 4, 6},
+		{180, 90, 50, 30, 40},
+		{180, 90, 200, 10, 15},
+		{720, 180, 40, 60, 90},
+	} {
+		calc, err := NewCalculator(WithWindowSize(tc.windowSize), WithMaxStay(tc.maxStay))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for seed := range int64(10) {
+			hols := syntheticHolidays(seed, tc.count, tc.length, tc.maxGap)
+			got, err := newSyntheticTrips(t, calc, hols).calculate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := calculateLegacy(newSyntheticTrips(t, calc, hols))
+			if got.Breach != want.Breach || got.LongestDaysAway != want.LongestDaysAway {
+				t.Errorf("case %d seed %d: breach/days got %t/%d want %t/%d",
+					i, seed, got.Breach, got.LongestDaysAway, want.Breach, want.LongestDaysAway)
+			}
+			if !reflect.DeepEqual(got.Window, want.Window) {
+				t.Errorf("case %d seed %d: window got %s want %s", i, seed, got.Window, want.Window)
+			}
+		}
+	}
+}
+
+// benchmarkCalculate benchmarks a calculation function over 20 years
+// of synthetic holidays
+func benchmarkCalculate(b *testing.B, fn func(*Trips)) {
+	calc, err := NewCalculator()
+	if err != nil {
+		b.Fatal(err)
+	}
+	hols := syntheticHolidays(1, 400, 10, 15)
+	b.ResetTimer()
+	for range b.N {
+		b.StopTimer()
+		trips := newSyntheticTrips(b, calc, hols)
+		b.StartTimer()
+		fn(trips)
+	}
+}
+
+func BenchmarkCalculate(b *testing.B) {
+	benchmarkCalculate(b, func(trips *Trips) {
+		_, _ = trips.calculate()
+	})
+}
+
+func BenchmarkCalculateLegacy(b *testing.B) {
+	benchmarkCalculate(b, func(trips *Trips) {
+		_ = calculateLegacy(trips)
+	})
+}
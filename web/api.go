@@ -0,0 +1,395 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/rorycl/timeaway/trips"
+)
+
+// The /api/v1 endpoints use the request and response types below rather
+// than the trips package structs, so that the api does not change with
+// the internals of the calculation. Dates are "2006-01-02" strings.
+// Every error is reported with an APIError envelope and an http status
+// reflecting the problem.
+
+// apiDateFormat is the format of api dates.
+const apiDateFormat = "2006-01-02"
+
+// customRuleName is the name of a rolling rule set out by its
+// parameters in an api request.
+const customRuleName = "custom"
+
+// APITrip is a trip or exempt period in an api request.
+type APITrip struct {
+	Start   string `json:"start"`             // first day of the trip
+	End     string `json:"end"`               // last day of the trip
+	Country string `json:"country,omitempty"` // destination country code, if known
+	Type    string `json:"type,omitempty"`    // "trip" (the default) or "exempt"
+}
+
+// APIRuleRequest selects the rule to calculate with in an api request,
+// either a registered rule by Name, or a rolling rule allowing MaxDays
+// away in any period of WindowDays.
+type APIRuleRequest struct {
+	Name       string `json:"name,omitempty"`
+	WindowDays int    `json:"windowDays,omitempty"`
+	MaxDays    int    `json:"maxDays,omitempty"`
+}
+
+// CalculationRequest is the body of a /api/v1/calculate request. The
+// DefaultRule is used if no rule is provided, and the days remaining
+// are reported on the ReferenceDate, or the last day of the trips if
+// it is not provided.
+type CalculationRequest struct {
+	Trips         []APITrip       `json:"trips"`
+	Rule          *APIRuleRequest `json:"rule,omitempty"`
+	Merge         bool            `json:"merge,omitempty"`         // merge overlapping trips
+	ReferenceDate string          `json:"referenceDate,omitempty"` // date to report days remaining on
+}
+
+// APIRule describes a rule in an api response.
+type APIRule struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Limit       int    `json:"limit"` // the maximum days away
+}
+
+// APIPeriod is a period of days in an api response, with the days away
+// in it.
+type APIPeriod struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	DaysAway int    `json:"daysAway"`
+}
+
+// APITripResult is a calculated trip or exempt period in an api
+// response.
+type APITripResult struct {
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	Country string `json:"country,omitempty"`
+	Days    int    `json:"days"`
+}
+
+// APIExclusion reports days away which were not counted in an api
+// response.
+type APIExclusion struct {
+	Start  string `json:"start"`
+	End    string `json:"end"`
+	Days   int    `json:"days"`
+	Reason string `json:"reason"`
+}
+
+// CalculationResponse is the result of a /api/v1/calculate request.
+type CalculationResponse struct {
+	Rule          APIRule         `json:"rule"`
+	Breach        bool            `json:"breach"`
+	DaysAway      int             `json:"daysAway"`         // the most days away in any window
+	Window        *APIPeriod      `json:"window,omitempty"` // the window with the most days away, if any days are counted
+	Breaches      []APIPeriod     `json:"breaches"`         // each period in breach
+	ReferenceDate string          `json:"referenceDate"`    // the date days are reported on
+	DaysUsed      int             `json:"daysUsed"`         // days away counted on the reference date
+	DaysRemaining int             `json:"daysRemaining"`    // days of the allowance remaining on the reference date
+	Trips         []APITripResult `json:"trips"`            // the trips, after any merging
+	Exemptions    []APITripResult `json:"exemptions"`       // the exempt periods
+	Exclusions    []APIExclusion  `json:"exclusions"`       // days away not counted by the rule
+}
+
+// APIRulesResponse is the result of a /api/v1/rules request.
+type APIRulesResponse struct {
+	Rules []APIRule `json:"rules"`
+}
+
+// The codes of APIErrorDetail.
+const (
+	APIErrorNotFound    = "not_found"
+	APIErrorMethod      = "method_not_allowed"
+	APIErrorTooLarge    = "body_too_large"
+	APIErrorInvalidJSON = "invalid_json"
+	APIErrorInvalid     = "invalid_request"
+	APIErrorTrips       = "invalid_trips"
+	APIErrorRule        = "invalid_rule"
+	APIErrorCalculation = "calculation_failed"
+	APIErrorInternal    = "internal_error"
+	APIErrorTimeout     = "timeout"
+)
+
+// The codes of APITripError.
+const (
+	APITripMissingDate   = "missing_date"
+	APITripReversedDates = "reversed_dates"
+	APITripOverlap       = "overlap"
+	APITripInvalid       = "invalid_trip"
+)
+
+// APITripError describes a problem with one or more trips in an api
+// request, identifying the trips by their index in the request.
+type APITripError struct {
+	Code    string `json:"code"`    // one of the APITrip codes
+	Message string `json:"message"` // a description of the problem
+	Indices []int  `json:"indices"` // the indices of the offending trips
+}
+
+// apiTripErrors returns an APITripError for each trip validation
+// problem reported by err, if any.
+func apiTripErrors(err error) []APITripError {
+	var ve trips.ValidationErrors
+	if !errors.As(err, &ve) {
+		return nil
+	}
+	tripErrors := []APITripError{}
+	for _, e := range ve {
+		code := APITripInvalid
+		switch e.(type) {
+		case trips.MissingDateError:
+			code = APITripMissingDate
+		case trips.ReversedDatesError:
+			code = APITripReversedDates
+		case trips.OverlapError:
+			code = APITripOverlap
+		}
+		tripErrors = append(tripErrors, APITripError{code, e.Error(), e.Indices()})
+	}
+	return tripErrors
+}
+
+// APIErrorDetail describes an api error. Problems with individual trips
+// are reported in Trips.
+type APIErrorDetail struct {
+	Status  int            `json:"status"`  // the http status
+	Code    string         `json:"code"`    // one of the APIError codes
+	Message string         `json:"message"` // a description of the problem
+	Trips   []APITripError `json:"trips,omitempty"`
+}
+
+// newAPIErrorDetail returns the APIErrorDetail with the status, code
+// and the message made from err, including any trip validation
+// problems.
+func newAPIErrorDetail(status int, code string, err error) *APIErrorDetail {
+	return &APIErrorDetail{status, code, err.Error(), apiTripErrors(err)}
+}
+
+// APIError is the envelope of every api error response.
+type APIError struct {
+	Error APIErrorDetail `json:"error"`
+}
+
+// apiErrorSender writes an APIError with the status, code and the
+// message made from err, including any trip validation problems.
+func apiErrorSender(w http.ResponseWriter, status int, code string, err error) {
+	apiWriter(w, status, APIError{*newAPIErrorDetail(status, code, err)})
+}
+
+// apiWriter writes v as json with the http status.
+func apiWriter(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	jBytes, err := tripsJSONMarshal(v)
+	if err != nil {
+		status = http.StatusInternalServerError
+		jBytes, _ = json.Marshal(APIError{APIErrorDetail{status, APIErrorInternal, "json encoding error: " + err.Error(), nil}})
+	}
+	w.WriteHeader(status)
+	if _, err := w.Write(jBytes); err != nil {
+		log.Printf("could not write api response %v", err)
+	}
+}
+
+// apiDecode decodes the json body of r into v, rejecting unknown
+// fields, reporting the http status and code of any error.
+func apiDecode(r *http.Request, v any) (int, string, error) {
+	defer r.Body.Close()
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge, APIErrorTooLarge, fmt.Errorf("request body larger than %d bytes", tooLarge.Limit)
+	case errors.Is(err, io.EOF):
+		return http.StatusBadRequest, APIErrorInvalidJSON, errors.New("request body is empty")
+	case err != nil:
+		return http.StatusBadRequest, APIErrorInvalidJSON, fmt.Errorf("request body is not valid json: %w", err)
+	case decoder.More():
+		return http.StatusBadRequest, APIErrorInvalidJSON, errors.New("request body has more than one json value")
+	}
+	return 0, "", nil
+}
+
+// holidays decodes the trips of the request.
+func (req CalculationRequest) holidays() ([]trips.Holiday, error) {
+	if len(req.Trips) == 0 {
+		return nil, errors.New("no trips were provided")
+	}
+	j, err := json.Marshal(req.Trips)
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := holidayJSONDecoder(j)
+	return holidays, mergeable(err, req.Merge)
+}
+
+// rule returns the rule selected by the request.
+func (req CalculationRequest) rule() (trips.Rule, error) {
+	rr := req.Rule
+	switch {
+	case rr == nil || *rr == APIRuleRequest{}:
+		return calculationRule("")
+	case rr.Name != "" && (rr.WindowDays != 0 || rr.MaxDays != 0):
+		return nil, errors.New("provide either a rule name or windowDays and maxDays")
+	case rr.Name != "":
+		return calculationRule(rr.Name)
+	}
+	return trips.NewRollingRule(customRuleName, rr.WindowDays, rr.MaxDays)
+}
+
+// referenceDate returns the reference date of the request, or the zero
+// time if none was provided.
+func (req CalculationRequest) referenceDate() (time.Time, error) {
+	if req.ReferenceDate == "" {
+		return time.Time{}, nil
+	}
+	d, err := time.Parse(apiDateFormat, req.ReferenceDate)
+	if err != nil {
+		return d, fmt.Errorf("invalid referenceDate %q", req.ReferenceDate)
+	}
+	return d, nil
+}
+
+// newAPIRule describes rule for an api response.
+func newAPIRule(rule trips.Rule) APIRule {
+	return APIRule{rule.Name(), rule.Description(), rule.Limit()}
+}
+
+// apiTripResults describes holidays for an api response.
+func apiTripResults(holidays []trips.Holiday) []APITripResult {
+	results := []APITripResult{}
+	for _, h := range holidays {
+		results = append(results, APITripResult{h.Start.Format(apiDateFormat), h.End.Format(apiDateFormat), h.Country, h.Duration})
+	}
+	return results
+}
+
+// newCalculationResponse describes the calculated trs for an api
+// response, reporting the days remaining on ref, or the last day of
+// the trips if ref is zero.
+func newCalculationResponse(rule trips.Rule, trs *trips.Trips, ref time.Time) CalculationResponse {
+	if ref.IsZero() {
+		ref = trs.End
+	}
+	res := CalculationResponse{
+		Rule:          newAPIRule(rule),
+		Breach:        trs.Breach,
+		DaysAway:      trs.DaysAway,
+		Breaches:      []APIPeriod{},
+		ReferenceDate: ref.Format(apiDateFormat),
+		DaysUsed:      trs.DaysUsed(ref),
+		DaysRemaining: trs.DaysRemaining(ref),
+		Trips:         apiTripResults(trs.OriginalHolidays),
+		Exemptions:    apiTripResults(trs.Exemptions),
+		Exclusions:    []APIExclusion{},
+	}
+	if trs.Window.DaysAway > 0 {
+		res.Window = &APIPeriod{trs.Window.Start.Format(apiDateFormat), trs.Window.End.Format(apiDateFormat), trs.Window.DaysAway}
+	}
+	for _, b := range trs.Breaches {
+		res.Breaches = append(res.Breaches, APIPeriod{b.Start.Format(apiDateFormat), b.End.Format(apiDateFormat), b.DaysAway})
+	}
+	for _, e := range trs.Exclusions {
+		res.Exclusions = append(res.Exclusions, APIExclusion{e.Start.Format(apiDateFormat), e.End.Format(apiDateFormat), e.Days, e.Reason})
+	}
+	return res
+}
+
+// apiCalculate calculates the trips of the request, reporting the http
+// status and code of any error.
+func apiCalculate(req CalculationRequest) (CalculationResponse, int, string, error) {
+	rule, err := req.rule()
+	if err != nil {
+		return CalculationResponse{}, http.StatusUnprocessableEntity, APIErrorRule, err
+	}
+	ref, err := req.referenceDate()
+	if err != nil {
+		return CalculationResponse{}, http.StatusUnprocessableEntity, APIErrorInvalid, err
+	}
+	holidays, err := req.holidays()
+	if err != nil {
+		return CalculationResponse{}, http.StatusUnprocessableEntity, APIErrorTrips, err
+	}
+	trs, err := calculate(holidays, ruleOptions(rule, req.Merge)...)
+	if err != nil {
+		return CalculationResponse{}, http.StatusUnprocessableEntity, APIErrorCalculation, err
+	}
+	return newCalculationResponse(rule, trs, ref), http.StatusOK, "", nil
+}
+
+// APICalculate is the /api/v1/calculate POST endpoint, calculating the
+// trips in a json CalculationRequest and returning a
+// CalculationResponse, such as for
+// `{"trips":[{"start":"2024-07-01","end":"2024-07-14"}],"rule":{"windowDays":180,"maxDays":90},"referenceDate":"2024-09-01"}`.
+func APICalculate(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		apiErrorSender(w, http.StatusMethodNotAllowed, APIErrorMethod, fmt.Errorf("endpoint only accepts POST requests, got %s", r.Method))
+		return
+	}
+	req := CalculationRequest{}
+	if status, code, err := apiDecode(r, &req); err != nil {
+		apiErrorSender(w, status, code, err)
+		return
+	}
+	res, status, code, err := apiCalculate(req)
+	if err != nil {
+		apiErrorSender(w, status, code, err)
+		return
+	}
+	apiWriter(w, http.StatusOK, res)
+}
+
+// APIRules is the /api/v1/rules GET endpoint, listing the registered
+// rules which may be selected by name.
+func APIRules(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		apiErrorSender(w, http.StatusMethodNotAllowed, APIErrorMethod, fmt.Errorf("endpoint only accepts GET requests, got %s", r.Method))
+		return
+	}
+	res := APIRulesResponse{Rules: []APIRule{}}
+	for _, rule := range trips.Rules() {
+		res.Rules = append(res.Rules, newAPIRule(rule))
+	}
+	apiWriter(w, http.StatusOK, res)
+}
+
+// APIOpenAPI is the /api/v1/openapi.json GET endpoint serving the
+// OpenAPI 3 description of the /api/v1 endpoints.
+func APIOpenAPI(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		apiErrorSender(w, http.StatusMethodNotAllowed, APIErrorMethod, fmt.Errorf("endpoint only accepts GET requests, got %s", r.Method))
+		return
+	}
+	doc, err := fs.ReadFile(DirFS.StaticFS, "openapi.json")
+	if err != nil {
+		apiErrorSender(w, http.StatusInternalServerError, APIErrorInternal, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(doc); err != nil {
+		log.Printf("could not write openapi document %v", err)
+	}
+}
+
+// APINotFound reports api paths which are not known.
+func APINotFound(w http.ResponseWriter, r *http.Request) {
+	apiErrorSender(w, http.StatusNotFound, APIErrorNotFound, fmt.Errorf("no api endpoint at %s", r.URL.Path))
+}
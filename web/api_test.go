@@ -0,0 +1,315 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/timeaway/trips"
+)
+
+// apiRouter returns a router of the api endpoints, with the body limit
+// middleware.
+func apiRouter() *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/api/v1/calculate", APICalculate)
+	r.HandleFunc("/api/v1/batch", APIBatch)
+	r.HandleFunc("/api/v1/stream", APIStream)
+	r.HandleFunc("/api/v1/compare", APICompare)
+	r.HandleFunc("/api/v1/rules", APIRules)
+	r.HandleFunc("/api/v1/openapi.json", APIOpenAPI)
+	r.PathPrefix("/api/").HandlerFunc(APINotFound)
+	r.Use(bodyLimitMiddleware)
+	return r
+}
+
+func TestAPIEndpoints(t *testing.T) {
+
+	DirFS = &fileSystem{}
+	DirFS.StaticFS = os.DirFS("static")
+	calculate = trips.Calculate
+	holidayJSONDecoder = trips.HolidaysJSONDecoder
+	tripsJSONMarshal = json.Marshal
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		input      string
+		statusCode int
+		want       []string
+	}{
+		{
+			name:       "calculate",
+			method:     http.MethodPost,
+			url:        "/api/v1/calculate",
+			input:      `{"trips":[{"start":"2023-01-01","end":"2023-04-10","country":"FR"}]}`,
+			statusCode: http.StatusOK,
+			want: []string{
+				`"rule":{"name":"schengen","description":"90 days in any 180 day period","limit":90}`,
+				`"breach":true,"daysAway":100`,
+				`"breaches":[{"start":"2023-04-01","end":"2023-04-10","daysAway":100}]`,
+				`"referenceDate":"2023-04-10","daysUsed":100,"daysRemaining":0`,
+				`"trips":[{"start":"2023-01-01","end":"2023-04-10","country":"FR","days":100}]`,
+				`"exemptions":[],"exclusions":[]`,
+			},
+		},
+		{
+			name:       "no days counted",
+			method:     http.MethodPost,
+			url:        "/api/v1/calculate",
+			input:      `{"trips":[{"start":"2023-01-01","end":"2023-01-10","country":"IE"}]}`,
+			statusCode: http.StatusOK,
+			want: []string{
+				`"breach":false,"daysAway":0,"breaches":[]`,
+				`"exclusions":[{"start":"2023-01-01","end":"2023-01-10","days":10,"reason":"Ireland is not a Schengen member state"}]`,
+			},
+		},
+		{
+			name:       "rule parameters and reference date",
+			method:     http.MethodPost,
+			url:        "/api/v1/calculate",
+			input:      `{"trips":[{"start":"2023-01-01","end":"2023-01-10"}],"rule":{"windowDays":30,"maxDays":20},"referenceDate":"2023-01-25"}`,
+			statusCode: http.StatusOK,
+			want: []string{
+				`"rule":{"name":"custom","description":"20 days in any 30 day period","limit":20}`,
+				`"breach":false`,
+				`"referenceDate":"2023-01-25","daysUsed":10,"daysRemaining":10`,
+			},
+		},
+		{
+			name:       "named rule with merged trips and an exemption",
+			method:     http.MethodPost,
+			url:        "/api/v1/calculate",
+			input:      `{"trips":[{"start":"2023-01-01","end":"2023-01-10"},{"start":"2023-01-10","end":"2023-01-12"},{"start":"2023-01-05","end":"2023-01-06","type":"exempt"}],"rule":{"name":"schengen"},"merge":true}`,
+			statusCode: http.StatusOK,
+			want: []string{
+				`"name":"schengen"`,
+				`"daysAway":10`,
+				`"trips":[{"start":"2023-01-01","end":"2023-01-12","days":12}]`,
+				`"exemptions":[{"start":"2023-01-05","end":"2023-01-06","days":2}]`,
+				`"exclusions":[{"start":"2023-01-05","end":"2023-01-06","days":2,"reason":"covered by a residence permit or long-stay visa"}]`,
+			},
+		},
+		{
+			name:       "invalid trips",
+			method:     http.MethodPost,
+			url:        "/api/v1/calculate",
+			input:      `{"trips":[{"start":"2023-01-01","end":"2023-01-10"},{"start":"2023-01-05","end":"2023-01-12"}]}`,
+			statusCode: http.StatusUnprocessableEntity,
+			want: []string{
+				`{"error":{"status":422,"code":"invalid_trips","message":"trip 2 05/01/2023 to 12/01/2023 overlaps with trip 1 01/01/2023 to 10/01/2023"`,
+				`"trips":[{"code":"overlap","message":"trip 2 05/01/2023 to 12/01/2023 overlaps with trip 1 01/01/2023 to 10/01/2023","indices":[0,1]}]`,
+			},
+		},
+		{
+			name:       "no trips",
+			method:     http.MethodPost,
+			url:        "/api/v1/calculate",
+			input:      `{"trips":[]}`,
+			statusCode: http.StatusUnprocessableEntity,
+			want:       []string{`"code":"invalid_trips","message":"no trips were provided"`},
+		},
+		{
+			name:       "unknown rule",
+			method:     http.MethodPost,
+			url:        "/api/v1/calculate",
+			input:      `{"trips":[{"start":"2023-01-01","end":"2023-01-10"}],"rule":{"name":"none"}}`,
+			statusCode: http.StatusUnprocessableEntity,
+			want:       []string{`"code":"invalid_rule"`},
+		},
+		{
+			name:       "rule name and parameters",
+			method:     http.MethodPost,
+			url:        "/api/v1/calculate",
+			input:      `{"trips":[{"start":"2023-01-01","end":"2023-01-10"}],"rule":{"name":"schengen","maxDays":10}}`,
+			statusCode: http.StatusUnprocessableEntity,
+			want:       []string{`"code":"invalid_rule","message":"provide either a rule name or windowDays and maxDays"`},
+		},
+		{
+			name:       "invalid rule parameters",
+			method:     http.MethodPost,
+			url:        "/api/v1/calculate",
+			input:      `{"trips":[{"start":"2023-01-01","end":"2023-01-10"}],"rule":{"windowDays":10,"maxDays":20}}`,
+			statusCode: http.StatusUnprocessableEntity,
+			want:       []string{`"code":"invalid_rule"`},
+		},
+		{
+			name:       "invalid reference date",
+			method:     http.MethodPost,
+			url:        "/api/v1/calculate",
+			input:      `{"trips":[{"start":"2023-01-01","end":"2023-01-10"}],"referenceDate":"01/02/2023"}`,
+			statusCode: http.StatusUnprocessableEntity,
+			want:       []string{`"code":"invalid_request","message":"invalid referenceDate \"01/02/2023\""`},
+		},
+		{
+			name:       "unknown field",
+			method:     http.MethodPost,
+			url:        "/api/v1/calculate",
+			input:      `[{"Start":"2023-01-01","End":"2023-01-10"}]`,
+			statusCode: http.StatusBadRequest,
+			want:       []string{`"code":"invalid_json"`},
+		},
+		{
+			name:       "empty body",
+			method:     http.MethodPost,
+			url:        "/api/v1/calculate",
+			statusCode: http.StatusBadRequest,
+			want:       []string{`"code":"invalid_json","message":"request body is empty"`},
+		},
+		{
+			name:       "body too large",
+			method:     http.MethodPost,
+			url:        "/api/v1/calculate",
+			input:      `{"trips":[` + strings.Repeat(`{"start":"2023-01-01","end":"2023-01-10"},`, 5000) + `]}`,
+			statusCode: http.StatusRequestEntityTooLarge,
+			want:       []string{`"code":"body_too_large"`},
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			url:        "/api/v1/calculate",
+			statusCode: http.StatusMethodNotAllowed,
+			want:       []string{`{"error":{"status":405,"code":"method_not_allowed"`},
+		},
+		{
+			name:       "stream wrong method",
+			method:     http.MethodGet,
+			url:        "/api/v1/stream",
+			statusCode: http.StatusMethodNotAllowed,
+			want:       []string{`{"error":{"status":405,"code":"method_not_allowed"`},
+		},
+		{
+			name:       "rules",
+			method:     http.MethodGet,
+			url:        "/api/v1/rules",
+			statusCode: http.StatusOK,
+			want:       []string{`{"rules":[{"name":"calendar-year"`, `{"name":"schengen","description":"90 days in any 180 day period","limit":90}`},
+		},
+		{
+			name:       "openapi",
+			method:     http.MethodGet,
+			url:        "/api/v1/openapi.json",
+			statusCode: http.StatusOK,
+			want:       []string{`"openapi": "3.0.3"`},
+		},
+		{
+			name:       "not found",
+			method:     http.MethodGet,
+			url:        "/api/v1/trips",
+			statusCode: http.StatusNotFound,
+			want:       []string{`{"error":{"status":404,"code":"not_found","message":"no api endpoint at /api/v1/trips"}}`},
+		},
+	}
+
+	r := apiRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://example.com"+tt.url, strings.NewReader(tt.input))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := res.StatusCode, tt.statusCode; got != want {
+				t.Errorf("status got %d want %d (%s)", got, want, body)
+			}
+			if got, want := res.Header.Get("Content-Type"), "application/json"; got != want {
+				t.Errorf("content type got %s want %s", got, want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("body does not contain %s:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+// TestOpenAPIDocument checks that the schemas of the OpenAPI document
+// describe the fields of the api types.
+func TestOpenAPIDocument(t *testing.T) {
+
+	b, err := os.ReadFile("static/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := struct {
+		OpenAPI    string
+		Paths      map[string]any
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any
+			}
+		}
+	}{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/calculate", "/batch", "/stream", "/compare", "/rules", "/openapi.json"} {
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("path %s not documented", path)
+		}
+	}
+
+	for schema, v := range map[string]any{
+		"Trip":                APITrip{},
+		"RuleRequest":         APIRuleRequest{},
+		"CalculationRequest":  CalculationRequest{},
+		"Rule":                APIRule{},
+		"Period":              APIPeriod{},
+		"TripResult":          APITripResult{},
+		"Exclusion":           APIExclusion{},
+		"CalculationResponse": CalculationResponse{},
+		"RulesResponse":       APIRulesResponse{},
+		"BatchSet":            BatchSet{},
+		"BatchRequest":        BatchRequest{},
+		"BatchResult":         BatchResult{},
+		"BatchResponse":       BatchResponse{},
+		"StreamResult":        StreamResult{},
+		"Scenario":            APIScenario{},
+		"ComparisonRequest":   ComparisonRequest{},
+		"ScenarioResult":      ScenarioResult{},
+		"ComparisonResponse":  ComparisonResponse{},
+		"TripError":           APITripError{},
+		"ErrorDetail":         APIErrorDetail{},
+		"Error":               APIError{},
+	} {
+		documented := []string{}
+		for p := range doc.Components.Schemas[schema].Properties {
+			documented = append(documented, p)
+		}
+		fields := jsonFields(reflect.TypeOf(v))
+		slices.Sort(documented)
+		slices.Sort(fields)
+		if !slices.Equal(documented, fields) {
+			t.Errorf("schema %s properties %v do not match fields %v", schema, documented, fields)
+		}
+	}
+}
+
+// jsonFields returns the json names of the fields of the struct typ,
+// including those of embedded structs.
+func jsonFields(typ reflect.Type) []string {
+	fields := []string{}
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		if f.Anonymous {
+			fields = append(fields, jsonFields(f.Type)...)
+			continue
+		}
+		fields = append(fields, strings.Split(f.Tag.Get("json"), ",")[0])
+	}
+	return fields
+}
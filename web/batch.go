@@ -0,0 +1,145 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// apiBatchPath is the path of the batch endpoint, which accepts larger
+// and longer running requests than the other endpoints.
+const apiBatchPath = "/api/v1/batch"
+
+var (
+	// BatchWorkers is the number of trip sets in a batch request which
+	// are calculated concurrently
+	BatchWorkers int = runtime.GOMAXPROCS(0)
+
+	// BatchMaxSets is the largest number of trip sets accepted in a
+	// batch request
+	BatchMaxSets int = 10000
+
+	// BatchTimeout is the time allowed to read, calculate and respond
+	// to a batch request, in place of the server timeouts
+	BatchTimeout time.Duration = 2 * time.Minute
+)
+
+// BatchSet is a named set of trips in a batch request, such as the
+// trips of an employee, calculated as for a CalculationRequest.
+type BatchSet struct {
+	Name string `json:"name"`
+	CalculationRequest
+}
+
+// BatchRequest is the body of a /api/v1/batch request.
+type BatchRequest struct {
+	Sets []BatchSet `json:"sets"`
+}
+
+// BatchResult is the result of a set in a batch request, with either
+// the calculation Result or the Error preventing the calculation.
+type BatchResult struct {
+	Name   string               `json:"name"`
+	Result *CalculationResponse `json:"result,omitempty"`
+	Error  *APIErrorDetail      `json:"error,omitempty"`
+}
+
+// BatchResponse is the result of a /api/v1/batch request, with a result
+// for each set in the order of the request.
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// batchResult calculates the trips of a set.
+func batchResult(set BatchSet) BatchResult {
+	result := BatchResult{Name: set.Name}
+	res, status, code, err := apiCalculate(set.CalculationRequest)
+	if err != nil {
+		result.Error = newAPIErrorDetail(status, code, err)
+		return result
+	}
+	result.Result = &res
+	return result
+}
+
+// calculateBatch calculates the trips of each set with a pool of
+// workers, returning the results in the order of the sets, or an error
+// if the context is done first.
+func calculateBatch(ctx context.Context, sets []BatchSet, workers int) ([]BatchResult, error) {
+
+	results := make([]BatchResult, len(sets))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range max(min(workers, len(sets)), 1) {
+		wg.Go(func() {
+			for i := range jobs {
+				results[i] = batchResult(sets[i])
+			}
+		})
+	}
+
+	var err error
+feed:
+	for i := range sets {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return results, err
+}
+
+// APIBatch is the /api/v1/batch POST endpoint, calculating each named
+// set of trips in a json BatchRequest, such as
+// `{"sets":[{"name":"alice","trips":[{"start":"2024-07-01","end":"2024-07-14"}]}]}`,
+// and returning a BatchResponse with the result of each set. The sets
+// are calculated concurrently by BatchWorkers workers. A set which
+// cannot be calculated is reported with an error in its result, without
+// affecting the other sets.
+func APIBatch(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		apiErrorSender(w, http.StatusMethodNotAllowed, APIErrorMethod, fmt.Errorf("endpoint only accepts POST requests, got %s", r.Method))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), BatchTimeout)
+	defer cancel()
+
+	req := BatchRequest{}
+	if status, code, err := apiDecode(r, &req); err != nil {
+		apiErrorSender(w, status, code, err)
+		return
+	}
+	switch {
+	case len(req.Sets) == 0:
+		apiErrorSender(w, http.StatusUnprocessableEntity, APIErrorInvalid, errors.New("no sets were provided"))
+		return
+	case len(req.Sets) > BatchMaxSets:
+		apiErrorSender(w, http.StatusUnprocessableEntity, APIErrorInvalid, fmt.Errorf("no more than %d sets may be calculated in a batch", BatchMaxSets))
+		return
+	}
+	for i, set := range req.Sets {
+		if set.Name == "" {
+			apiErrorSender(w, http.StatusUnprocessableEntity, APIErrorInvalid, fmt.Errorf("set %d has no name", i+1))
+			return
+		}
+	}
+
+	results, err := calculateBatch(ctx, req.Sets, BatchWorkers)
+	if err != nil {
+		apiErrorSender(w, http.StatusServiceUnavailable, APIErrorTimeout, fmt.Errorf("batch not calculated: %w", err))
+		return
+	}
+	apiWriter(w, http.StatusOK, BatchResponse{results})
+}
@@ -0,0 +1,206 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rorycl/timeaway/trips"
+)
+
+func TestAPIBatch(t *testing.T) {
+
+	calculate = trips.Calculate
+	holidayJSONDecoder = trips.HolidaysJSONDecoder
+	tripsJSONMarshal = json.Marshal
+
+	tests := []struct {
+		name       string
+		input      string
+		maxSets    int
+		statusCode int
+		want       []string
+	}{
+		{
+			name: "sets",
+			input: `{"sets":[
+				{"name":"alice","trips":[{"start":"2023-01-01","end":"2023-04-10"}]},
+				{"name":"bob","trips":[{"start":"2023-01-10","end":"2023-01-01"}]},
+				{"name":"carol","trips":[{"start":"2023-01-01","end":"2023-01-10"}],"rule":{"windowDays":30,"maxDays":20},"referenceDate":"2023-01-25"}
+			]}`,
+			statusCode: http.StatusOK,
+			want: []string{
+				`{"results":[{"name":"alice","result":{"rule":{"name":"schengen"`,
+				`"breach":true,"daysAway":100`,
+				`{"name":"bob","error":{"status":422,"code":"invalid_trips","message":"trip 1: start date 10/01/2023 after 01/01/2023","trips":[{"code":"reversed_dates","message":"trip 1: start date 10/01/2023 after 01/01/2023","indices":[0]}]`,
+				`{"name":"carol","result":{"rule":{"name":"custom"`,
+				`"referenceDate":"2023-01-25","daysUsed":10,"daysRemaining":10`,
+			},
+		},
+		{
+			name:       "no sets",
+			input:      `{"sets":[]}`,
+			statusCode: http.StatusUnprocessableEntity,
+			want:       []string{`"code":"invalid_request","message":"no sets were provided"`},
+		},
+		{
+			name:       "unnamed set",
+			input:      `{"sets":[{"name":"alice","trips":[{"start":"2023-01-01","end":"2023-01-10"}]},{"trips":[]}]}`,
+			statusCode: http.StatusUnprocessableEntity,
+			want:       []string{`"message":"set 2 has no name"`},
+		},
+		{
+			name:       "too many sets",
+			input:      `{"sets":[{"name":"alice","trips":[]},{"name":"bob","trips":[]}]}`,
+			maxSets:    1,
+			statusCode: http.StatusUnprocessableEntity,
+			want:       []string{`"message":"no more than 1 sets may be calculated in a batch"`},
+		},
+		{
+			name:       "invalid json",
+			input:      `{"sets":[{"name":"alice","trips":[],"colour":"red"}]}`,
+			statusCode: http.StatusBadRequest,
+			want:       []string{`"code":"invalid_json"`},
+		},
+	}
+
+	r := apiRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.maxSets > 0 {
+				defer func(n int) { BatchMaxSets = n }(BatchMaxSets)
+				BatchMaxSets = tt.maxSets
+			}
+			req := httptest.NewRequest(http.MethodPost, "http://example.com/api/v1/batch", strings.NewReader(tt.input))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := res.StatusCode, tt.statusCode; got != want {
+				t.Errorf("status got %d want %d (%s)", got, want, body)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("body does not contain %s:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+// TestCalculateBatch checks that the sets of a batch are calculated
+// concurrently by no more than the permitted number of workers, and
+// that the results keep the order of the sets.
+func TestCalculateBatch(t *testing.T) {
+
+	var mu sync.Mutex
+	running, most := 0, 0
+	calculate = func(hols []trips.Holiday, options ...trips.Option) (*trips.Trips, error) {
+		mu.Lock()
+		running++
+		most = max(most, running)
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		return trips.Calculate(hols, options...)
+	}
+	defer func() { calculate = trips.Calculate }()
+
+	sets := []BatchSet{}
+	for i := range 50 {
+		sets = append(sets, BatchSet{
+			Name: fmt.Sprintf("set %d", i),
+			CalculationRequest: CalculationRequest{
+				Trips: []APITrip{{Start: "2023-01-01", End: fmt.Sprintf("2023-01-%02d", i%28+1)}},
+			},
+		})
+	}
+	results, err := calculateBatch(context.Background(), sets, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if most > 4 || most < 2 {
+		t.Errorf("concurrent calculations got %d want 2 to 4", most)
+	}
+	for i, r := range results {
+		if got, want := r.Name, sets[i].Name; got != want {
+			t.Fatalf("result %d name got %s want %s", i, got, want)
+		}
+		if got, want := r.Result.DaysAway, i%28+1; got != want {
+			t.Errorf("result %d days away got %d want %d", i, got, want)
+		}
+	}
+
+	// a cancelled batch reports the context error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := calculateBatch(ctx, sets, 4); err == nil {
+		t.Error("expected an error for a cancelled batch")
+	}
+}
+
+// TestBatchLimits checks that batch requests are permitted a larger body
+// and a longer timeout than other requests.
+func TestBatchLimits(t *testing.T) {
+	batch := httptest.NewRequest(http.MethodPost, "http://example.com/api/v1/batch", nil)
+	other := httptest.NewRequest(http.MethodPost, "http://example.com/api/v1/calculate", nil)
+	if got, want := bodyLimit(batch), BatchBodyLimitSize; got != want {
+		t.Errorf("batch body limit got %d want %d", got, want)
+	}
+	if got, want := bodyLimit(other), BodyLimitSize; got != want {
+		t.Errorf("body limit got %d want %d", got, want)
+	}
+	if got, want := requestTimeout(batch), BatchTimeout; got != want {
+		t.Errorf("batch timeout got %s want %s", got, want)
+	}
+	if got := requestTimeout(other); got != 0 {
+		t.Errorf("timeout got %s want 0", got)
+	}
+}
+
+// TestDeadlineMiddleware checks that a batch request may take longer
+// than the server write timeout.
+func TestDeadlineMiddleware(t *testing.T) {
+
+	defer func(d time.Duration) { BatchTimeout = d }(BatchTimeout)
+	BatchTimeout = time.Second
+
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	})
+	server := httptest.NewUnstartedServer(deadlineMiddleware(slow))
+	server.Config.WriteTimeout = 20 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	for path, ok := range map[string]bool{"/api/v1/batch": true, "/api/v1/calculate": false} {
+		res, err := http.Post(server.URL+path, "application/json", nil)
+		if err == nil {
+			body, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+			err = nil
+			if string(body) != "done" {
+				err = fmt.Errorf("body %q", body)
+			}
+		}
+		if got := err == nil; got != ok {
+			t.Errorf("%s completed got %t want %t (%v)", path, got, ok, err)
+		}
+	}
+}
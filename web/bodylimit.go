@@ -9,10 +9,29 @@ import (
 // is permitted to accept
 var BodyLimitSize int64 = 1 << 17 // ~125k
 
+// BatchBodyLimitSize is the largest amount of bytes the body of a batch
+// request to the /api/v1/batch endpoint is permitted to accept
+var BatchBodyLimitSize int64 = 1 << 23 // ~8M
+
+// bodyLimit returns the body size limit of the request, or 0 if the
+// body is not limited as a whole, as for streaming requests which limit
+// each line instead
+func bodyLimit(r *http.Request) int64 {
+	switch r.URL.Path {
+	case apiBatchPath:
+		return BatchBodyLimitSize
+	case apiStreamPath:
+		return 0
+	}
+	return BodyLimitSize
+}
+
 // bodyLimitMiddleware limits request bodies
 func bodyLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		r.Body = http.MaxBytesReader(w, r.Body, BodyLimitSize) // ~125k
+		if limit := bodyLimit(r); limit > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -29,6 +29,7 @@ func bodyMaker(l int) string {
 func TestBodyLimit(t *testing.T) {
 
 	// override the package BodyLimitSize
+	defer func(n int64) { BodyLimitSize = n }(BodyLimitSize)
 	BodyLimitSize = 1 << 3
 
 	// testHandler to report on body errors, if any
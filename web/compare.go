@@ -0,0 +1,418 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rorycl/timeaway/svg"
+	"github.com/rorycl/timeaway/trips"
+)
+
+// scenarioSizeMin and scenarioSizeMax are the fewest and most scenarios
+// which may be compared.
+const (
+	scenarioSizeMin = 2
+	scenarioSizeMax = 10
+)
+
+// A comparison calculates several named scenarios, such as alternative
+// plans for future trips, each made of the trips of a common base
+// history followed by the trips of the scenario. The compare page form
+// and url query name the base trips as for the home page, and the
+// fields of each scenario as for the travellers of the team page, such
+// as "Name.0", "Start.0" and "End.0" for the first scenario.
+
+// scenarioIndices returns the ordered indexes of the scenarios in q,
+// identified by their "Name" fields.
+func scenarioIndices(q url.Values) ([]int, error) {
+	return nameIndices(q, "scenario", scenarioSizeMax)
+}
+
+// baseQuery returns the base trip fields in q, suitable for
+// trips.HolidaysURLDecoder.
+func baseQuery(q url.Values) url.Values {
+	bq := url.Values{}
+	for _, f := range travellerFields {
+		if v, ok := q[f]; ok {
+			bq[f] = v
+		}
+	}
+	return bq
+}
+
+// tripKey identifies a trip when comparing scenarios.
+type tripKey struct {
+	start, end, country string
+	exempt              bool
+}
+
+// holidayKey returns the key of a holiday.
+func holidayKey(h trips.Holiday) tripKey {
+	return tripKey{h.Start.Format(time.DateOnly), h.End.Format(time.DateOnly), h.Country, h.Exempt}
+}
+
+// apiTripKey returns the key of an api trip.
+func apiTripKey(t APITrip) tripKey {
+	return tripKey{t.Start, t.End, t.Country, t.Type == "exempt"}
+}
+
+// differing returns the items of each set which are not in every set,
+// compared by their key, such as the trips which differ between
+// scenarios.
+func differing[T any](sets [][]T, key func(T) tripKey) [][]T {
+	counts := map[tripKey]int{}
+	for _, set := range sets {
+		seen := map[tripKey]bool{}
+		for _, v := range set {
+			if k := key(v); !seen[k] {
+				seen[k] = true
+				counts[k]++
+			}
+		}
+	}
+	diffs := make([][]T, len(sets))
+	for i, set := range sets {
+		diffs[i] = []T{}
+		for _, v := range set {
+			if counts[key(v)] < len(sets) {
+				diffs[i] = append(diffs[i], v)
+			}
+		}
+	}
+	return diffs
+}
+
+// Compare shows the compare page, on which several named scenarios
+// sharing a common base history of trips are calculated and compared.
+// The base trips and scenarios, if any, are read from the url query,
+// otherwise the page starts with two scenarios.
+func Compare(w http.ResponseWriter, r *http.Request) {
+
+	// date about 6 months ago
+	defaultDate := time.Now().Add(time.Hour * -24 * 7 * 26)
+
+	q := r.URL.Query()
+	base, _ := trips.HolidaysURLDecoder(baseQuery(q)) // errors are shown on calculation
+	indices, err := scenarioIndices(q)
+	if err != nil || len(indices) == 0 {
+		indices = []int{0, 1}
+	}
+	scenarios := []travellerForm{}
+	for _, i := range indices {
+		holidays, _ := trips.HolidaysURLDecoder(travellerQuery(q, i))
+		scenarios = append(scenarios, travellerForm{
+			Index:       i,
+			Suffix:      travellerSuffix(i),
+			Noun:        "scenario",
+			Name:        strings.TrimSpace(q.Get("Name" + travellerSuffix(i))),
+			Holidays:    holidays,
+			DefaultDate: defaultDate,
+		})
+	}
+
+	t := template.New("compare.html")
+	t = t.Funcs(webFuncMap)
+	t, err = t.ParseFS(DirFS.TplFS, "compare.html", "partial-traveller.html")
+	if err != nil {
+		log.Printf("compare template parse error %v", err)
+		http.Error(w, "template error; apologies", http.StatusInternalServerError)
+		return
+	}
+
+	ruleName := q.Get("rule")
+	if ruleName == "" {
+		ruleName = DefaultRule
+	}
+	merge, _ := mergeFromQuery(q)
+
+	data := struct {
+		Title       string
+		Base        []trips.Holiday
+		DefaultDate time.Time
+		Scenarios   []travellerForm
+		Next        int
+		Rules       []trips.Rule
+		Rule        string
+		Merge       bool
+	}{
+		"trip scenario comparison",
+		base,
+		defaultDate,
+		scenarios,
+		indices[len(indices)-1] + 1,
+		trips.Rules(),
+		ruleName,
+		merge,
+	}
+	err = t.Execute(w, data)
+	if err != nil {
+		log.Printf("compare template writing error %v", err)
+		http.Error(w, "template writing error.", http.StatusInternalServerError)
+	}
+}
+
+// PartialAddScenario adds the fields of a scenario to the compare page
+// form, with the index given by the "scenario" query parameter,
+// followed by a button to add the next scenario.
+func PartialAddScenario(w http.ResponseWriter, r *http.Request) {
+
+	// date about 6 months ago
+	defaultDate := time.Now().Add(time.Hour * -24 * 7 * 26)
+
+	i, err := strconv.Atoi(r.URL.Query().Get("scenario"))
+	if err != nil || i < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Printf("invalid scenario %q", r.URL.Query().Get("scenario"))
+		return
+	}
+
+	t := template.New("partial-addscenario.html")
+	t = t.Funcs(webFuncMap)
+	t, err = t.ParseFS(DirFS.TplFS, "partial-addscenario.html", "partial-traveller.html")
+	if err != nil {
+		log.Printf("partial add scenario template parse error %v", err)
+		http.Error(w, "template error; apologies", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Scenario travellerForm
+		Next     int
+		Full     bool
+	}{
+		Scenario: travellerForm{Index: i, Suffix: travellerSuffix(i), Noun: "scenario", DefaultDate: defaultDate},
+		Next:     i + 1,
+		Full:     i+1 >= scenarioSizeMax,
+	}
+	err = t.Execute(w, data)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "template writing problem : %s", err.Error())
+	}
+}
+
+// scenarioResult is the calculation of a scenario shown on the compare
+// page.
+type scenarioResult struct {
+	Name          string
+	Trips         *trips.Trips
+	Changed       []trips.Holiday // the trips of the scenario not in every scenario
+	DaysRemaining int             // days remaining on the last day of the trips
+	Error         string          // the reason the trips could not be calculated
+}
+
+// PartialCompare shows the results of a compare page form submission in
+// html, calculating the base trips together with the trips of each
+// scenario with the selected rule. A table compares the breaches, the
+// worst window and the days remaining of each scenario, and the
+// scenarios which could be calculated are shown together in a calendar
+// highlighting the trips which differ between them.
+func PartialCompare(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Print("endpoint only accepts POST requests, got", r.Method)
+		return
+	}
+
+	output := struct {
+		Error       error
+		Description string
+		Scenarios   []scenarioResult
+		Plot        template.HTML
+	}{}
+
+	// writer writes the output
+	writer := func() {
+		t := template.Must(template.ParseFS(DirFS.TplFS, "partial-compare.html"))
+		err := t.Execute(w, output)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "template writing problem : %s", err.Error())
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		output.Error = err
+		writer()
+		return
+	}
+	q := r.PostForm
+	merge, err := mergeFromQuery(q)
+	if err != nil {
+		output.Error = err
+		writer()
+		return
+	}
+	rule, err := calculationRule(q.Get("rule"))
+	if err != nil {
+		output.Error = err
+		writer()
+		return
+	}
+	output.Description = rule.Description()
+	indices, err := scenarioIndices(q)
+	if err == nil && len(indices) < scenarioSizeMin {
+		err = fmt.Errorf("at least %d scenarios are needed for a comparison", scenarioSizeMin)
+	}
+	if err != nil {
+		output.Error = err
+		writer()
+		return
+	}
+
+	// the base trips are decoded on their own so that their problems
+	// are reported once rather than for each scenario
+	base, err := trips.HolidaysURLDecoder(baseQuery(q))
+	if err = mergeable(err, merge); err != nil {
+		output.Error = fmt.Errorf("base trips: %w", err)
+		writer()
+		return
+	}
+
+	// calculate the base trips with those of each scenario
+	names, holidays, errs := []string{}, [][]trips.Holiday{}, []error{}
+	for n, i := range indices {
+		hols, err := trips.HolidaysURLDecoder(travellerQuery(q, i))
+		names = append(names, groupName(q, i, n, "scenario"))
+		holidays = append(holidays, hols)
+		errs = append(errs, mergeable(err, merge))
+	}
+	changed := differing(holidays, holidayKey)
+	scenarios := []svg.Traveller{}
+	for n := range indices {
+		result := scenarioResult{Name: names[n], Changed: changed[n]}
+		all := append(append([]trips.Holiday{}, base...), holidays[n]...)
+		switch {
+		case errs[n] != nil:
+			result.Error = errs[n].Error()
+		case len(all) < 1:
+			result.Error = "no holidays were found"
+		default:
+			// error captured in trs.Error
+			trs, _ := calculate(all, ruleOptions(rule, merge)...)
+			if trs.Error != nil {
+				result.Error = trs.Error.Error()
+				break
+			}
+			result.Trips = trs
+			result.DaysRemaining = trs.DaysRemaining(trs.End)
+			scenarios = append(scenarios, svg.Traveller{Name: result.Name, Trips: trs, Changed: changed[n]})
+		}
+		output.Scenarios = append(output.Scenarios, result)
+	}
+
+	// push htmx browser url to client's browser history
+	query := teamQuery(names, holidays) + "&rule=" + url.QueryEscape(rule.Name())
+	if baseQuery := trips.HolidaysURLEncode(base); baseQuery != "" {
+		query = baseQuery + "&" + query
+	}
+	if merge {
+		query += "&merge=true"
+	}
+	w.Header().Set("HX-Push-Url", BaseURL+"/compare?"+query)
+
+	// svg creation. The Plot output is verbatim svg that should not be
+	// escaped.
+	if len(scenarios) > 0 {
+		var svgPlot strings.Builder
+		err := svg.TeamAsSVG(scenarios, &svgPlot)
+		if err != nil {
+			log.Printf("plotting error: %v", err)
+		}
+		output.Plot = template.HTML(svgPlot.String())
+	}
+	writer()
+}
+
+// APIScenario is a named scenario in a ComparisonRequest.
+type APIScenario struct {
+	Name  string    `json:"name"`
+	Trips []APITrip `json:"trips"`
+}
+
+// ComparisonRequest is the body of a /api/v1/compare request, comparing
+// scenarios each made of the Base trips and the trips of the scenario,
+// calculated as for a CalculationRequest with the Rule, Merge and
+// ReferenceDate of the comparison.
+type ComparisonRequest struct {
+	Base          []APITrip       `json:"base"`
+	Scenarios     []APIScenario   `json:"scenarios"`
+	Rule          *APIRuleRequest `json:"rule,omitempty"`
+	Merge         bool            `json:"merge,omitempty"`
+	ReferenceDate string          `json:"referenceDate,omitempty"`
+}
+
+// ScenarioResult is the result of a scenario in a ComparisonResponse,
+// with either the calculation Result or the Error preventing the
+// calculation. Trip problems reported in the Error identify the trips
+// by their index in the base trips followed by the trips of the
+// scenario.
+type ScenarioResult struct {
+	Name      string               `json:"name"`
+	Differing []APITrip            `json:"differing"` // the trips of the scenario not in every scenario
+	Result    *CalculationResponse `json:"result,omitempty"`
+	Error     *APIErrorDetail      `json:"error,omitempty"`
+}
+
+// ComparisonResponse is the result of a /api/v1/compare request, with a
+// result for each scenario in the order of the request.
+type ComparisonResponse struct {
+	Scenarios []ScenarioResult `json:"scenarios"`
+}
+
+// APICompare is the /api/v1/compare POST endpoint, calculating each
+// named scenario in a json ComparisonRequest with the base trips, such
+// as
+// `{"base":[{"start":"2024-01-02","end":"2024-03-01"}],"scenarios":[{"name":"june","trips":[{"start":"2024-06-01","end":"2024-06-30"}]},{"name":"july","trips":[{"start":"2024-07-01","end":"2024-07-30"}]}]}`,
+// and returning a ComparisonResponse with the result of each scenario
+// and the trips in which it differs from the others.
+func APICompare(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		apiErrorSender(w, http.StatusMethodNotAllowed, APIErrorMethod, fmt.Errorf("endpoint only accepts POST requests, got %s", r.Method))
+		return
+	}
+	req := ComparisonRequest{}
+	if status, code, err := apiDecode(r, &req); err != nil {
+		apiErrorSender(w, status, code, err)
+		return
+	}
+	switch {
+	case len(req.Scenarios) < scenarioSizeMin:
+		apiErrorSender(w, http.StatusUnprocessableEntity, APIErrorInvalid, fmt.Errorf("at least %d scenarios are needed for a comparison", scenarioSizeMin))
+		return
+	case len(req.Scenarios) > scenarioSizeMax:
+		apiErrorSender(w, http.StatusUnprocessableEntity, APIErrorInvalid, fmt.Errorf("no more than %d scenarios may be compared", scenarioSizeMax))
+		return
+	}
+	sets := [][]APITrip{}
+	for i, s := range req.Scenarios {
+		if s.Name == "" {
+			apiErrorSender(w, http.StatusUnprocessableEntity, APIErrorInvalid, fmt.Errorf("scenario %d has no name", i+1))
+			return
+		}
+		sets = append(sets, s.Trips)
+	}
+
+	diffs := differing(sets, apiTripKey)
+	res := ComparisonResponse{Scenarios: []ScenarioResult{}}
+	for i, s := range req.Scenarios {
+		calcReq := CalculationRequest{
+			Trips:         append(append([]APITrip{}, req.Base...), s.Trips...),
+			Rule:          req.Rule,
+			Merge:         req.Merge,
+			ReferenceDate: req.ReferenceDate,
+		}
+		br := batchResult(BatchSet{s.Name, calcReq})
+		res.Scenarios = append(res.Scenarios, ScenarioResult{s.Name, diffs[i], br.Result, br.Error})
+	}
+	apiWriter(w, http.StatusOK, res)
+}
@@ -0,0 +1,260 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rorycl/timeaway/trips"
+)
+
+// TestCompare tests the compare page shows the base trips and scenarios
+// in the url query.
+func TestCompare(t *testing.T) {
+
+	DirFS = &fileSystem{}
+	DirFS.TplFS = os.DirFS("templates")
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name: "new comparison",
+			want: []string{
+				`name="Name.0"`,
+				`name="Name.1"`,
+				`placeholder="scenario name"`,
+				`remove scenario`,
+				`hx-get="./partials/addscenario?scenario=2"`,
+			},
+		},
+		{
+			name:  "scenarios from query",
+			query: "Start=2023-01-01&End=2023-01-10&Name.0=june&Start.0=2023-06-01&End.0=2023-06-30&Name.2=july&Start.2=2023-07-01&End.2=2023-07-30&rule=uk-tax-year",
+			want: []string{
+				`name="Start"
+    value="2023-01-01"`,
+				`name="Name.0" value="june"`,
+				`name="Start.0"
+    value="2023-06-01"`,
+				`name="Name.2" value="july"`,
+				`hx-get="./partials/addscenario?scenario=3"`,
+				`<option value="uk-tax-year" selected>`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/compare?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			Compare(w, r)
+			if got, want := w.Result().StatusCode, http.StatusOK; got != want {
+				t.Errorf("status got %d want %d", got, want)
+			}
+			body := w.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body does not contain %q:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+// TestPartialAddScenario tests adding a scenario to the compare page
+// form.
+func TestPartialAddScenario(t *testing.T) {
+
+	DirFS = &fileSystem{}
+	DirFS.TplFS = os.DirFS("templates")
+
+	tests := []struct {
+		url        string
+		statusCode int
+		want       []string
+	}{
+		{"/partials/addscenario?scenario=2", http.StatusOK, []string{`name="Name.2"`, `<div id="rpl-2"></div>`, `hx-get="./partials/addscenario?scenario=3"`}},
+		{"/partials/addscenario?scenario=9", http.StatusOK, []string{`name="Name.9"`, "No more scenarios can be added."}},
+		{"/partials/addscenario?scenario=x", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.url, nil)
+			w := httptest.NewRecorder()
+			PartialAddScenario(w, r)
+			if got, want := w.Result().StatusCode, tt.statusCode; got != want {
+				t.Errorf("status got %d want %d", got, want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("body does not contain %q:\n%s", want, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+// TestPartialCompare tests the scenario comparison partial.
+func TestPartialCompare(t *testing.T) {
+
+	DirFS = &fileSystem{}
+	DirFS.TplFS = os.DirFS("templates")
+	calculate = trips.Calculate
+
+	tests := []struct {
+		name    string
+		input   string // form body
+		pushURL string
+		want    []string
+	}{
+		{
+			name: "two scenarios",
+			input: "Start=2023-01-01&End=2023-02-28&" +
+				"Name.0=June&Start.0=2023-06-01&End.0=2023-06-30&Start.0=2023-08-01&End.0=2023-08-05&" +
+				"Name.3=March&Start.3=2023-03-01&End.3=2023-04-05&Start.3=2023-08-01&End.3=2023-08-05",
+			pushURL: "/compare?Start=2023-01-01&End=2023-02-28&Name.0=June&Start.0=2023-06-01&End.0=2023-06-30&Start.0=2023-08-01&End.0=2023-08-05&Name.1=March&Start.1=2023-03-01&End.1=2023-04-05&Start.1=2023-08-01&End.1=2023-08-05&rule=schengen",
+			want: []string{
+				"<tr><th></th><th>June</th><th>March</th></tr>",
+				"<tr><th>breach</th>\n    <td>no</td>\n    <td class=\"breached\">yes, 1 time</td>\n</tr>",
+				"<tr><th>most days away</th>\n    <td>88 of 90</td>\n    <td>95 of 90</td>",
+				"<tr><th>differing trips</th>\n    <td>01/06/2023 to 30/06/2023</td>\n    <td>01/03/2023 to 05/04/2023</td>",
+				"<title>changed (June) : 2023-06-01 to 2023-06-30</title>",
+				"<title>holiday (March) : 2023-08-01 to 2023-08-05</title>",
+			},
+		},
+		{
+			name:  "a scenario with an invalid trip",
+			input: "Name.0=A&Start.0=2023-01-01&End.0=2023-01-10&Name.1=&Start.1=2023-03-10&End.1=2023-03-01",
+			want: []string{
+				"<th>scenario 2</th>",
+				"could not be calculated: trip 1: start date 10/03/2023 after 01/03/2023",
+			},
+		},
+		{
+			name: "a scenario with only an exempt period",
+			input: "Name.0=A&Start.0=2023-01-01&End.0=2023-01-10&Type.0=trip&" +
+				"Name.1=B&Start.1=2023-02-01&End.1=2023-02-10&Type.1=exempt",
+			want: []string{
+				"could not be calculated: no trips were provided to calculate, only exempt periods",
+				"<title>holiday (A) : 2023-01-01 to 2023-01-10</title>",
+			},
+		},
+		{
+			name:  "invalid base trips",
+			input: "Start=2023-03-10&End=2023-03-01&Name.0=A&Name.1=B",
+			want:  []string{"base trips: trip 1: start date 10/03/2023 after 01/03/2023"},
+		},
+		{
+			name:  "one scenario",
+			input: "Name.0=A&Start.0=2023-01-01&End.0=2023-01-10",
+			want:  []string{"at least 2 scenarios are needed for a comparison"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://example.com/partials/compare", strings.NewReader(tt.input))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			PartialCompare(w, r)
+			body := w.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body does not contain %q:\n%s", want, body)
+				}
+			}
+			if tt.pushURL == "" {
+				return
+			}
+			if got, want := w.Header().Get("HX-Push-Url"), BaseURL+tt.pushURL; got != want {
+				t.Errorf("push url got\n%s want\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestAPICompare(t *testing.T) {
+
+	calculate = trips.Calculate
+	holidayJSONDecoder = trips.HolidaysJSONDecoder
+	tripsJSONMarshal = json.Marshal
+
+	tests := []struct {
+		name       string
+		input      string
+		statusCode int
+		want       []string
+	}{
+		{
+			name: "scenarios",
+			input: `{"base":[{"start":"2023-01-01","end":"2023-02-28"}],"scenarios":[
+				{"name":"june","trips":[{"start":"2023-06-01","end":"2023-06-30"}]},
+				{"name":"march","trips":[{"start":"2023-03-01","end":"2023-04-05"}]},
+				{"name":"reversed","trips":[{"start":"2023-03-10","end":"2023-03-01"}]}
+			]}`,
+			statusCode: http.StatusOK,
+			want: []string{
+				`{"scenarios":[{"name":"june","differing":[{"start":"2023-06-01","end":"2023-06-30"}],"result":{"rule":{"name":"schengen"`,
+				`"breach":false,"daysAway":88`,
+				`{"name":"march","differing":[{"start":"2023-03-01","end":"2023-04-05"}],"result":`,
+				`"breach":true,"daysAway":95`,
+				`{"name":"reversed","differing":[{"start":"2023-03-10","end":"2023-03-01"}],"error":{"status":422,"code":"invalid_trips","message":"trip 2: start date 10/03/2023 after 01/03/2023"`,
+			},
+		},
+		{
+			name:       "one scenario",
+			input:      `{"base":[],"scenarios":[{"name":"june","trips":[]}]}`,
+			statusCode: http.StatusUnprocessableEntity,
+			want:       []string{`"message":"at least 2 scenarios are needed for a comparison"`},
+		},
+		{
+			name:       "unnamed scenario",
+			input:      `{"base":[],"scenarios":[{"name":"june","trips":[]},{"trips":[]}]}`,
+			statusCode: http.StatusUnprocessableEntity,
+			want:       []string{`"message":"scenario 2 has no name"`},
+		},
+	}
+
+	r := apiRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://example.com/api/v1/compare", strings.NewReader(tt.input))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := res.StatusCode, tt.statusCode; got != want {
+				t.Errorf("status got %d want %d (%s)", got, want, body)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("body does not contain %s:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffering(t *testing.T) {
+	a := APITrip{Start: "2023-01-01", End: "2023-01-10"}
+	b := APITrip{Start: "2023-02-01", End: "2023-02-10"}
+	c := APITrip{Start: "2023-02-01", End: "2023-02-10", Type: "exempt"}
+	diffs := differing([][]APITrip{{a, b}, {a, c}, {a, b, b}}, apiTripKey)
+	for i, want := range [][]APITrip{{b}, {c}, {b, b}} {
+		if got := diffs[i]; !slices.Equal(got, want) {
+			t.Errorf("set %d differing got %v want %v", i, got, want)
+		}
+	}
+}
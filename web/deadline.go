@@ -0,0 +1,49 @@
+// extend the server timeouts for long running and streaming requests
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// requestTimeout returns the time allowed to read and respond to the
+// request in place of the server timeouts, or zero if the server
+// timeouts apply
+func requestTimeout(r *http.Request) time.Duration {
+	switch r.URL.Path {
+	case apiBatchPath:
+		return BatchTimeout
+	case apiStreamPath:
+		return StreamTimeout
+	}
+	return 0
+}
+
+// deadlineMiddleware extends the read and write deadlines of the
+// connection for requests allowed longer than the server timeouts, and
+// permits streaming requests to read the request body after writing the
+// response has started. It needs to be the outermost middleware to
+// reach the connection, since the other middleware wrap the
+// ResponseWriter.
+func deadlineMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if timeout := requestTimeout(r); timeout > 0 {
+			rc := http.NewResponseController(w)
+			deadline := time.Now().Add(timeout)
+			for _, set := range []func(time.Time) error{rc.SetReadDeadline, rc.SetWriteDeadline} {
+				if err := set(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+					log.Printf("could not extend deadline: %v", err)
+				}
+			}
+		}
+		if r.URL.Path == apiStreamPath {
+			err := http.NewResponseController(w).EnableFullDuplex()
+			if err != nil && !errors.Is(err, http.ErrNotSupported) {
+				log.Printf("could not enable full duplex: %v", err)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
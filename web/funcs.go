@@ -1,6 +1,10 @@
 package web
 
-import "time"
+import (
+	"time"
+
+	"github.com/rorycl/timeaway/trips"
+)
 
 // funcs provide some commonly used template functions
 
@@ -15,8 +19,14 @@ func dateStr(d time.Time) string {
 	return d.Format(time.DateOnly)
 }
 
+// countries provides the countries which may be selected for a trip.
+func countries() []trips.Membership {
+	return trips.SchengenCountries()
+}
+
 // webFuncMap provides a map suitable for providing to template.Funcs.
 var webFuncMap map[string]any = map[string]any{
-	"yearsAgo": yearsAgo,
-	"dateStr":  dateStr,
+	"yearsAgo":  yearsAgo,
+	"dateStr":   dateStr,
+	"countries": countries,
 }
@@ -0,0 +1,337 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/timeaway/trips"
+)
+
+// TripStore saves named trip sets for the /sets endpoints and the home
+// page. Saving trip sets is disabled if TripStore is nil.
+var TripStore Store
+
+// errStoreDisabled is reported when no TripStore is configured.
+var errStoreDisabled = errors.New("saving trip sets is not enabled")
+
+// storeErrorStatus returns the http status for a Store error.
+func storeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errStoreDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, ErrTripSetNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrTripSetExists):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// checkTripSet checks the name, rule and trips of a trip set to be
+// saved. Trips which only overlap are accepted if the set merges them.
+func checkTripSet(ts TripSet) error {
+	if err := checkTripSetName(ts.Name); err != nil {
+		return err
+	}
+	if ts.Rule != "" {
+		if _, err := trips.RuleByName(ts.Rule); err != nil {
+			return err
+		}
+	}
+	holidays, err := ts.Holidays()
+	if err = mergeable(err, ts.Merge); err != nil {
+		return err
+	}
+	if len(holidays) < 1 {
+		return errors.New("no holidays were found")
+	}
+	return nil
+}
+
+// decodeTripSet decodes a json trip set from the request body.
+func decodeTripSet(r *http.Request) (TripSet, error) {
+	ts := TripSet{}
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		return ts, err
+	}
+	err = json.Unmarshal(body, &ts)
+	return ts, err
+}
+
+// writeJSONStatus writes v as json with the http status.
+func writeJSONStatus(w http.ResponseWriter, status int, v any) {
+	jBytes, err := tripsJSONMarshal(v)
+	if err != nil {
+		jsonStatusErrorSender(w, http.StatusInternalServerError, "json encoding error:", err)
+		return
+	}
+	w.WriteHeader(status)
+	_, err = w.Write(jBytes)
+	if err != nil {
+		log.Printf("could not write trip set error %v", err)
+	}
+}
+
+// SavedSets is an endpoint for the saved trip sets. A GET request
+// returns every saved trip set as json, and a POST request saves a new
+// trip set POSTed as json in the form
+// `{"name":"summer","rule":"schengen","trips":[{"Start":"2024-07-01","End":"2024-07-14"}]}`,
+// returning the saved set with a created status, or a conflict status if
+// a trip set with the name is already saved.
+func SavedSets(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if TripStore == nil {
+		jsonStatusErrorSender(w, storeErrorStatus(errStoreDisabled), "store error:", errStoreDisabled)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		sets, err := TripStore.List()
+		if err != nil {
+			jsonStatusErrorSender(w, http.StatusInternalServerError, "store error:", err)
+			return
+		}
+		writeJSONStatus(w, http.StatusOK, sets)
+
+	case http.MethodPost:
+		ts, err := decodeTripSet(r)
+		if err != nil {
+			jsonErrorSender(w, "trip set decoding error:", err)
+			return
+		}
+		if err := checkTripSet(ts); err != nil {
+			jsonErrorSender(w, "trip set error:", err)
+			return
+		}
+		ts, err = TripStore.Create(ts)
+		if err != nil {
+			jsonStatusErrorSender(w, storeErrorStatus(err), "store error:", err)
+			return
+		}
+		writeJSONStatus(w, http.StatusCreated, ts)
+
+	default:
+		jsonErrorSender(w, "endpoint only accepts GET and POST requests, got", errors.New(r.Method))
+	}
+}
+
+// SavedSet is an endpoint for the saved trip set named by the url path,
+// such as /sets/summer. A GET request returns the trip set as json, a
+// PUT request saves the trip set PUT as json in the form used by
+// SavedSets under that name, a PATCH request of the form
+// `{"name":"new name"}` renames it and a DELETE request deletes it.
+func SavedSet(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if TripStore == nil {
+		jsonStatusErrorSender(w, storeErrorStatus(errStoreDisabled), "store error:", errStoreDisabled)
+		return
+	}
+	name := mux.Vars(r)["name"]
+
+	// storeError reports a Store error with its status
+	storeError := func(err error) {
+		jsonStatusErrorSender(w, storeErrorStatus(err), "store error:", err)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		ts, err := TripStore.Get(name)
+		if err != nil {
+			storeError(err)
+			return
+		}
+		writeJSONStatus(w, http.StatusOK, ts)
+
+	case http.MethodPut:
+		ts, err := decodeTripSet(r)
+		if err != nil {
+			jsonErrorSender(w, "trip set decoding error:", err)
+			return
+		}
+		ts.Name = name
+		if err := checkTripSet(ts); err != nil {
+			jsonErrorSender(w, "trip set error:", err)
+			return
+		}
+		ts, err = TripStore.Save(ts)
+		if err != nil {
+			storeError(err)
+			return
+		}
+		writeJSONStatus(w, http.StatusOK, ts)
+
+	case http.MethodPatch:
+		ts, err := decodeTripSet(r)
+		if err != nil {
+			jsonErrorSender(w, "trip set decoding error:", err)
+			return
+		}
+		ts, err = TripStore.Rename(name, ts.Name)
+		if err != nil {
+			storeError(err)
+			return
+		}
+		writeJSONStatus(w, http.StatusOK, ts)
+
+	case http.MethodDelete:
+		if err := TripStore.Delete(name); err != nil {
+			storeError(err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		jsonErrorSender(w, "endpoint only accepts GET, PUT, PATCH and DELETE requests, got", errors.New(r.Method))
+	}
+}
+
+// tripSetsWriter writes the list of saved trip sets shown on the home
+// page, with a message and any error to report.
+func tripSetsWriter(w http.ResponseWriter, message string, setErr error) {
+
+	// tripSetView is a saved trip set with a link to the home page
+	type tripSetView struct {
+		Name    string
+		Trips   int
+		Updated time.Time
+		Query   template.URL
+	}
+
+	output := struct {
+		Sets    []tripSetView
+		Message string
+		Error   error
+	}{Message: message, Error: setErr}
+
+	if TripStore == nil {
+		output.Error = errStoreDisabled
+	} else {
+		sets, err := TripStore.List()
+		if err != nil {
+			log.Print("trip set listing error ", err)
+			output.Error = err
+		}
+		for _, ts := range sets {
+			output.Sets = append(output.Sets, tripSetView{ts.Name, len(ts.Trips), ts.Updated, template.URL(ts.Query())})
+		}
+	}
+
+	t := template.Must(template.ParseFS(DirFS.TplFS, "partial-sets.html"))
+	err := t.Execute(w, output)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "template writing problem : %s", err.Error())
+	}
+}
+
+// PartialSets shows the saved trip sets in html.
+func PartialSets(w http.ResponseWriter, r *http.Request) {
+	tripSetsWriter(w, "", nil)
+}
+
+// PartialSetSave saves the trips submitted with the home page form
+// under the name in the "SetName" form field, and shows the saved trip
+// sets. As for SavedSets, a trip set already saved with the name is not
+// replaced.
+func PartialSetSave(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Print("endpoint only accepts POST requests, got", r.Method)
+		return
+	}
+	if TripStore == nil {
+		tripSetsWriter(w, "", errStoreDisabled)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tripSetsWriter(w, "", err)
+		return
+	}
+
+	merge, err := mergeFromQuery(r.PostForm)
+	if err != nil {
+		tripSetsWriter(w, "", err)
+		return
+	}
+	holidays, err := trips.HolidaysURLDecoder(r.PostForm)
+	var invalid trips.ValidationErrors
+	if err != nil && !errors.As(err, &invalid) {
+		tripSetsWriter(w, "", err)
+		return
+	}
+	name := strings.TrimSpace(r.PostForm.Get("SetName"))
+	ts := newTripSet(name, r.PostForm.Get("rule"), merge, holidays)
+	if err := checkTripSet(ts); err != nil {
+		tripSetsWriter(w, "", fmt.Errorf("could not save %q: %w", name, err))
+		return
+	}
+	if _, err := TripStore.Create(ts); err != nil {
+		tripSetsWriter(w, "", fmt.Errorf("could not save %q: %w", name, err))
+		return
+	}
+	tripSetsWriter(w, fmt.Sprintf("Saved %q.", name), nil)
+}
+
+// PartialSetRename renames the trip set named in the "name" form field
+// to the name provided by the htmx prompt, and shows the saved trip
+// sets.
+func PartialSetRename(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Print("endpoint only accepts POST requests, got", r.Method)
+		return
+	}
+	if TripStore == nil {
+		tripSetsWriter(w, "", errStoreDisabled)
+		return
+	}
+	name := r.PostFormValue("name")
+	to := strings.TrimSpace(r.Header.Get("HX-Prompt"))
+	if to == "" {
+		tripSetsWriter(w, "", nil)
+		return
+	}
+	if _, err := TripStore.Rename(name, to); err != nil {
+		tripSetsWriter(w, "", fmt.Errorf("could not rename %q: %w", name, err))
+		return
+	}
+	tripSetsWriter(w, fmt.Sprintf("Renamed %q to %q.", name, to), nil)
+}
+
+// PartialSetDelete deletes the trip set named in the "name" form field
+// and shows the saved trip sets.
+func PartialSetDelete(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Print("endpoint only accepts POST requests, got", r.Method)
+		return
+	}
+	if TripStore == nil {
+		tripSetsWriter(w, "", errStoreDisabled)
+		return
+	}
+	name := r.PostFormValue("name")
+	if err := TripStore.Delete(name); err != nil {
+		tripSetsWriter(w, "", fmt.Errorf("could not delete %q: %w", name, err))
+		return
+	}
+	tripSetsWriter(w, fmt.Sprintf("Deleted %q.", name), nil)
+}
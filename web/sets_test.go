@@ -0,0 +1,305 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/timeaway/trips"
+)
+
+// TestSavedSetsEndpoints tests saving, listing, renaming and deleting
+// trip sets through the json endpoints in turn.
+func TestSavedSetsEndpoints(t *testing.T) {
+
+	tripsJSONMarshal = json.Marshal
+
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	TripStore = store
+	defer func() { TripStore = nil }()
+
+	r := mux.NewRouter()
+	r.HandleFunc("/sets", SavedSets)
+	r.HandleFunc("/sets/{name}", SavedSet)
+
+	steps := []struct {
+		name       string
+		method     string
+		url        string
+		input      string
+		statusCode int
+		want       string
+	}{
+		{
+			name:       "create",
+			method:     http.MethodPost,
+			url:        "/sets",
+			input:      `{"name":"summer","rule":"schengen","trips":[{"Start":"2024-07-01","End":"2024-07-14","Country":"FR"}]}`,
+			statusCode: http.StatusCreated,
+			want:       `"name":"summer","rule":"schengen","trips":[{"Start":"2024-07-01","End":"2024-07-14","Country":"FR"}]`,
+		},
+		{
+			name:       "create existing",
+			method:     http.MethodPost,
+			url:        "/sets",
+			input:      `{"name":"summer","trips":[{"Start":"2024-07-01","End":"2024-07-14"}]}`,
+			statusCode: http.StatusConflict,
+			want:       "trip set already exists",
+		},
+		{
+			name:       "create invalid trips",
+			method:     http.MethodPost,
+			url:        "/sets",
+			input:      `{"name":"winter","trips":[{"Start":"2024-12-10","End":"2024-12-01"}]}`,
+			statusCode: http.StatusBadRequest,
+			want:       `"kind":"reversed dates"`,
+		},
+		{
+			name:       "create overlapping trips to merge",
+			method:     http.MethodPost,
+			url:        "/sets",
+			input:      `{"name":"winter","merge":true,"trips":[{"Start":"2024-12-01","End":"2024-12-10"},{"Start":"2024-12-10","End":"2024-12-12"}]}`,
+			statusCode: http.StatusCreated,
+			want:       `"name":"winter","merge":true`,
+		},
+		{
+			name:       "create unknown rule",
+			method:     http.MethodPost,
+			url:        "/sets",
+			input:      `{"name":"spring","rule":"none","trips":[{"Start":"2024-04-01","End":"2024-04-02"}]}`,
+			statusCode: http.StatusBadRequest,
+			want:       "rule",
+		},
+		{
+			name:       "list",
+			method:     http.MethodGet,
+			url:        "/sets",
+			statusCode: http.StatusOK,
+			want:       `[{"name":"summer"`,
+		},
+		{
+			name:       "replace",
+			method:     http.MethodPut,
+			url:        "/sets/summer",
+			input:      `{"trips":[{"Start":"2024-08-01","End":"2024-08-14"}]}`,
+			statusCode: http.StatusOK,
+			want:       `"name":"summer","trips":[{"Start":"2024-08-01","End":"2024-08-14"}]`,
+		},
+		{
+			name:       "rename",
+			method:     http.MethodPatch,
+			url:        "/sets/summer",
+			input:      `{"name":"august"}`,
+			statusCode: http.StatusOK,
+			want:       `"name":"august"`,
+		},
+		{
+			name:       "rename to existing",
+			method:     http.MethodPatch,
+			url:        "/sets/august",
+			input:      `{"name":"winter"}`,
+			statusCode: http.StatusConflict,
+			want:       "trip set already exists",
+		},
+		{
+			name:       "get renamed",
+			method:     http.MethodGet,
+			url:        "/sets/august",
+			statusCode: http.StatusOK,
+			want:       `"Start":"2024-08-01"`,
+		},
+		{
+			name:       "get old name",
+			method:     http.MethodGet,
+			url:        "/sets/summer",
+			statusCode: http.StatusNotFound,
+			want:       "trip set not found",
+		},
+		{
+			name:       "delete",
+			method:     http.MethodDelete,
+			url:        "/sets/august",
+			statusCode: http.StatusNoContent,
+		},
+		{
+			name:       "delete again",
+			method:     http.MethodDelete,
+			url:        "/sets/august",
+			statusCode: http.StatusNotFound,
+			want:       "trip set not found",
+		},
+	}
+
+	for _, st := range steps {
+		t.Run(st.name, func(t *testing.T) {
+			req := httptest.NewRequest(st.method, "http://example.com"+st.url, strings.NewReader(st.input))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := res.StatusCode, st.statusCode; got != want {
+				t.Errorf("status got %d want %d (%s)", got, want, body)
+			}
+			if !strings.Contains(string(body), st.want) {
+				t.Errorf("body does not contain %s:\n%s", st.want, body)
+			}
+		})
+	}
+}
+
+// TestSavedSetsDisabled tests the trip set endpoints without a store.
+func TestSavedSetsDisabled(t *testing.T) {
+	TripStore = nil
+	w := httptest.NewRecorder()
+	SavedSets(w, httptest.NewRequest(http.MethodGet, "http://example.com/sets", nil))
+	if got, want := w.Result().StatusCode, http.StatusNotImplemented; got != want {
+		t.Errorf("status got %d want %d", got, want)
+	}
+}
+
+// TestPartialSets tests saving, renaming and deleting trip sets from the
+// home page.
+func TestPartialSets(t *testing.T) {
+
+	DirFS = &fileSystem{}
+	DirFS.TplFS = os.DirFS("templates")
+
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	TripStore = store
+	defer func() { TripStore = nil }()
+
+	form := func(vals url.Values) io.Reader {
+		return strings.NewReader(vals.Encode())
+	}
+
+	steps := []struct {
+		name   string
+		fn     func(w http.ResponseWriter, r *http.Request)
+		form   url.Values
+		prompt string
+		want   []string
+	}{
+		{
+			name: "empty list",
+			fn:   PartialSets,
+			want: []string{"No trips have been saved yet."},
+		},
+		{
+			name: "save",
+			fn:   PartialSetSave,
+			form: url.Values{
+				"Start": {"2024-07-01"}, "End": {"2024-07-14"}, "Country": {"FR"}, "Type": {"trip"},
+				"rule": {"schengen"}, "merge": {"false"}, "SetName": {" summer "},
+			},
+			want: []string{
+				"Saved &#34;summer&#34;.",
+				`<a href="./?Start=2024-07-01&amp;End=2024-07-14&amp;Country=FR&amp;rule=schengen">summer</a>`,
+			},
+		},
+		{
+			name: "save existing name",
+			fn:   PartialSetSave,
+			form: url.Values{
+				"Start": {"2024-08-01"}, "End": {"2024-08-14"}, "SetName": {"summer"},
+			},
+			want: []string{
+				"could not save &#34;summer&#34;: trip set already exists",
+				`<a href="./?Start=2024-07-01&amp;End=2024-07-14&amp;Country=FR&amp;rule=schengen">summer</a>`,
+			},
+		},
+		{
+			name: "save without a name",
+			fn:   PartialSetSave,
+			form: url.Values{"Start": {"2024-07-01"}, "End": {"2024-07-14"}},
+			want: []string{"trip set name cannot be empty"},
+		},
+		{
+			name: "save overlapping trips",
+			fn:   PartialSetSave,
+			form: url.Values{
+				"Start": {"2024-07-01", "2024-07-14"}, "End": {"2024-07-14", "2024-07-15"}, "SetName": {"overlap"},
+			},
+			want: []string{"could not save &#34;overlap&#34;", "overlaps with trip 1"},
+		},
+		{
+			name:   "rename",
+			fn:     PartialSetRename,
+			form:   url.Values{"name": {"summer"}},
+			prompt: "july",
+			want:   []string{"Renamed &#34;summer&#34; to &#34;july&#34;.", ">july</a>"},
+		},
+		{
+			name:   "rename cancelled",
+			fn:     PartialSetRename,
+			form:   url.Values{"name": {"july"}},
+			prompt: "",
+			want:   []string{">july</a>"},
+		},
+		{
+			name: "delete",
+			fn:   PartialSetDelete,
+			form: url.Values{"name": {"july"}},
+			want: []string{"Deleted &#34;july&#34;.", "No trips have been saved yet."},
+		},
+		{
+			name: "delete missing",
+			fn:   PartialSetDelete,
+			form: url.Values{"name": {"july"}},
+			want: []string{"could not delete &#34;july&#34;: trip set not found"},
+		},
+	}
+
+	for _, st := range steps {
+		t.Run(st.name, func(t *testing.T) {
+			method := http.MethodPost
+			if st.form == nil {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "http://example.com/partials/sets", form(st.form))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.Header.Set("HX-Prompt", st.prompt)
+			w := httptest.NewRecorder()
+			st.fn(w, r)
+			body := w.Body.String()
+			for _, want := range st.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body does not contain %q:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+// TestTripSetHolidays tests converting holidays to and from a trip set.
+func TestTripSetHolidays(t *testing.T) {
+	holidays, err := trips.HolidaysJSONDecoder([]byte(
+		`[{"Start":"2024-07-01","End":"2024-07-14","Country":"FR"},{"Start":"2024-08-01","End":"2024-09-01","Type":"exempt"}]`,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newTripSet("summer", "", false, holidays)
+	got, err := ts.Holidays()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Country != "FR" || !got[1].Exempt || !got[1].End.Equal(holidays[1].End) {
+		t.Errorf("holidays got %v want %v", got, holidays)
+	}
+}
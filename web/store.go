@@ -0,0 +1,317 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rorycl/timeaway/trips"
+)
+
+// ErrTripSetNotFound is returned by a Store for a trip set which has
+// not been saved.
+var ErrTripSetNotFound = errors.New("trip set not found")
+
+// ErrTripSetExists is returned by a Store when creating a trip set, or
+// renaming a trip set, with the name of another saved trip set.
+var ErrTripSetExists = errors.New("trip set already exists")
+
+// tripSetNameMaxLength is the longest permissible trip set name in
+// characters
+const tripSetNameMaxLength = 100
+
+// tripSetFileNameMaxLength is the longest permissible escaped trip set
+// name in bytes, leaving room for the ".json" extension within the 255
+// byte file name limit of common file systems
+const tripSetFileNameMaxLength = 250
+
+// SavedTrip is a trip in a saved TripSet, in the same form as the json
+// POSTed to the Trips endpoint.
+type SavedTrip struct {
+	Start   string `json:"Start"`             // start date, such as 2024-01-01
+	End     string `json:"End"`               // end date
+	Country string `json:"Country,omitempty"` // destination country code
+	Type    string `json:"Type,omitempty"`    // "trip" or "exempt"
+}
+
+// TripSet is a named set of trips saved in a Store, together with the
+// rule and overlap handling to calculate them with.
+type TripSet struct {
+	Name    string      `json:"name"`            // the unique name of the set
+	Rule    string      `json:"rule,omitempty"`  // the rule name, or the DefaultRule if empty
+	Merge   bool        `json:"merge,omitempty"` // if overlapping trips are merged
+	Trips   []SavedTrip `json:"trips"`           // the trips in the set
+	Updated time.Time   `json:"updated"`         // when the set was last saved
+}
+
+// newTripSet makes a TripSet from holidays.
+func newTripSet(name, rule string, merge bool, holidays []trips.Holiday) TripSet {
+	ts := TripSet{Name: name, Rule: rule, Merge: merge, Trips: []SavedTrip{}}
+	for _, h := range holidays {
+		ts.Trips = append(ts.Trips, SavedTrip{
+			Start:   h.Start.Format("2006-01-02"),
+			End:     h.End.Format("2006-01-02"),
+			Country: h.Country,
+			Type:    h.Type(),
+		})
+	}
+	return ts
+}
+
+// Holidays decodes the trips in the set, returning any
+// trips.ValidationErrors with the holidays.
+func (ts TripSet) Holidays() ([]trips.Holiday, error) {
+	j, err := json.Marshal(ts.Trips)
+	if err != nil {
+		return nil, err
+	}
+	return trips.HolidaysJSONDecoder(j)
+}
+
+// Query returns the url query parameters of the Home page showing the
+// trip set, as made by trips.HolidaysURLEncode.
+func (ts TripSet) Query() string {
+	holidays, _ := ts.Holidays() // invalid trips are shown to be corrected
+	q := trips.HolidaysURLEncode(holidays)
+	if ts.Rule != "" {
+		q += "&rule=" + url.QueryEscape(ts.Rule)
+	}
+	if ts.Merge {
+		q += "&merge=" + strconv.FormatBool(ts.Merge)
+	}
+	return q
+}
+
+// checkTripSetName checks that name is suitable for a trip set, being
+// neither empty nor too long, including once escaped as a file name by
+// FileStore, and without slashes or control characters.
+func checkTripSetName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return errors.New("trip set name cannot be empty")
+	case name != strings.TrimSpace(name):
+		return errors.New("trip set name cannot start or end with spaces")
+	case utf8.RuneCountInString(name) > tripSetNameMaxLength:
+		return fmt.Errorf("trip set name longer than %d characters", tripSetNameMaxLength)
+	case len(url.PathEscape(name)) > tripSetFileNameMaxLength:
+		return errors.New("trip set name too long to save; use fewer accented or non-latin characters")
+	case strings.ContainsAny(name, `/\`):
+		return errors.New("trip set name cannot contain slashes")
+	case strings.ContainsFunc(name, unicode.IsControl):
+		return errors.New("trip set name cannot contain control characters")
+	}
+	return nil
+}
+
+// Store saves named trip sets.
+type Store interface {
+	// List returns the saved trip sets ordered by name.
+	List() ([]TripSet, error)
+	// Get returns the trip set named name, or ErrTripSetNotFound.
+	Get(name string) (TripSet, error)
+	// Save saves the trip set, replacing any with the same name, and
+	// returns it with its updated time.
+	Save(ts TripSet) (TripSet, error)
+	// Create saves a new trip set as for Save, returning
+	// ErrTripSetExists if a trip set with the same name is saved.
+	Create(ts TripSet) (TripSet, error)
+	// Rename renames the trip set named from, returning
+	// ErrTripSetNotFound if it is not saved, or ErrTripSetExists if a
+	// trip set named to is.
+	Rename(from, to string) (TripSet, error)
+	// Delete deletes the trip set named name, or returns
+	// ErrTripSetNotFound.
+	Delete(name string) error
+}
+
+// FileStore is a Store saving each trip set as a json document in a
+// local directory.
+type FileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// NewFileStore returns a FileStore saving trip sets in dir, which is
+// made if it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if strings.TrimSpace(dir) == "" {
+		return nil, errors.New("store directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("store directory error: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path returns the path of the document of the trip set named name,
+// escaping the name to make a safe file name.
+func (fs *FileStore) path(name string) string {
+	return filepath.Join(fs.dir, url.PathEscape(name)+".json")
+}
+
+// read reads the trip set named name.
+func (fs *FileStore) read(name string) (TripSet, error) {
+	ts := TripSet{}
+	b, err := os.ReadFile(fs.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ts, ErrTripSetNotFound
+	}
+	if err != nil {
+		return ts, err
+	}
+	err = json.Unmarshal(b, &ts)
+	return ts, err
+}
+
+// write writes the trip set, replacing the file atomically so that a
+// partly written document is never read.
+func (fs *FileStore) write(ts TripSet) error {
+	b, err := json.MarshalIndent(ts, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(fs.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), fs.path(ts.Name))
+}
+
+// create writes a new trip set, the file being created exclusively so
+// that an existing trip set is never replaced.
+func (fs *FileStore) create(ts TripSet) error {
+	b, err := json.MarshalIndent(ts, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fs.path(ts.Name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%w: %s", ErrTripSetExists, ts.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// List returns the saved trip sets ordered by name.
+func (fs *FileStore) List() ([]TripSet, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	entries, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return nil, err
+	}
+	sets := []TripSet{}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		name, err := url.PathUnescape(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			continue // not a trip set document
+		}
+		ts, err := fs.read(name)
+		if err != nil {
+			return nil, fmt.Errorf("trip set %q: %w", name, err)
+		}
+		sets = append(sets, ts)
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i].Name < sets[j].Name
+	})
+	return sets, nil
+}
+
+// Get returns the trip set named name.
+func (fs *FileStore) Get(name string) (TripSet, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.read(name)
+}
+
+// Save saves the trip set, replacing any with the same name.
+func (fs *FileStore) Save(ts TripSet) (TripSet, error) {
+	if err := checkTripSetName(ts.Name); err != nil {
+		return ts, err
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	ts.Updated = time.Now().UTC().Truncate(time.Second)
+	return ts, fs.write(ts)
+}
+
+// Create saves a new trip set, returning ErrTripSetExists if a trip set
+// with the same name is saved.
+func (fs *FileStore) Create(ts TripSet) (TripSet, error) {
+	if err := checkTripSetName(ts.Name); err != nil {
+		return ts, err
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	ts.Updated = time.Now().UTC().Truncate(time.Second)
+	return ts, fs.create(ts)
+}
+
+// Rename renames the trip set named from to the name to.
+func (fs *FileStore) Rename(from, to string) (TripSet, error) {
+	if err := checkTripSetName(to); err != nil {
+		return TripSet{}, err
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	ts, err := fs.read(from)
+	if err != nil {
+		return ts, err
+	}
+	if from == to {
+		return ts, nil
+	}
+	if _, err := os.Stat(fs.path(to)); err == nil {
+		return ts, ErrTripSetExists
+	}
+	ts.Name = to
+	ts.Updated = time.Now().UTC().Truncate(time.Second)
+	if err := fs.write(ts); err != nil {
+		return ts, err
+	}
+	return ts, os.Remove(fs.path(from))
+}
+
+// Delete deletes the trip set named name.
+func (fs *FileStore) Delete(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	err := os.Remove(fs.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrTripSetNotFound
+	}
+	return err
+}
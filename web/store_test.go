@@ -0,0 +1,137 @@
+package web
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+
+	dir := filepath.Join(t.TempDir(), "sets")
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summer := TripSet{
+		Name:  "summer / holidays?",
+		Rule:  "schengen",
+		Trips: []SavedTrip{{Start: "2024-07-01", End: "2024-07-14", Country: "FR"}},
+	}
+	if _, err := store.Save(summer); err == nil {
+		t.Fatal("expected an error for a name with a slash")
+	}
+	summer.Name = "summer holidays?"
+	saved, err := store.Save(summer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Updated.IsZero() {
+		t.Error("updated time not set")
+	}
+	_, err = store.Create(TripSet{Name: "autumn", Trips: []SavedTrip{{Start: "2024-10-01", End: "2024-10-02"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(TripSet{Name: "autumn"}); !errors.Is(err, ErrTripSetExists) {
+		t.Errorf("create existing got %v want %v", err, ErrTripSetExists)
+	}
+	if got, err := store.Get("autumn"); err != nil || len(got.Trips) != 1 {
+		t.Errorf("existing set replaced by create: %+v %v", got, err)
+	}
+
+	// other files in the directory are ignored
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sets, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, s := range sets {
+		names = append(names, s.Name)
+	}
+	if got, want := strings.Join(names, ","), "autumn,summer holidays?"; got != want {
+		t.Errorf("list got %s want %s", got, want)
+	}
+
+	got, err := store.Get("summer holidays?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Rule != "schengen" || len(got.Trips) != 1 || got.Trips[0].Country != "FR" {
+		t.Errorf("get got %+v", got)
+	}
+	if got, want := got.Query(), "Start=2024-07-01&End=2024-07-14&Country=FR&rule=schengen"; got != want {
+		t.Errorf("query got %s want %s", got, want)
+	}
+	if _, err := store.Get("winter"); !errors.Is(err, ErrTripSetNotFound) {
+		t.Errorf("get missing got %v want %v", err, ErrTripSetNotFound)
+	}
+
+	if _, err := store.Rename("autumn", "summer holidays?"); !errors.Is(err, ErrTripSetExists) {
+		t.Errorf("rename to existing got %v want %v", err, ErrTripSetExists)
+	}
+	if _, err := store.Rename("winter", "spring"); !errors.Is(err, ErrTripSetNotFound) {
+		t.Errorf("rename missing got %v want %v", err, ErrTripSetNotFound)
+	}
+	renamed, err := store.Rename("autumn", "fall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := renamed.Name, "fall"; got != want {
+		t.Errorf("renamed got %s want %s", got, want)
+	}
+	if _, err := store.Get("autumn"); !errors.Is(err, ErrTripSetNotFound) {
+		t.Errorf("renamed set still found: %v", err)
+	}
+
+	if err := store.Delete("fall"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("fall"); !errors.Is(err, ErrTripSetNotFound) {
+		t.Errorf("delete missing got %v want %v", err, ErrTripSetNotFound)
+	}
+	sets, err = store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(sets), 1; got != want {
+		t.Errorf("sets after delete got %d want %d", got, want)
+	}
+}
+
+func TestCheckTripSetName(t *testing.T) {
+	for name, ok := range map[string]bool{
+		"summer":                 true,
+		"été 2024":               true,
+		"":                       false,
+		" summer":                false,
+		"a/b":                    false,
+		`a\b`:                    false,
+		"a\tb":                   false,
+		strings.Repeat("a", 101): false,
+		strings.Repeat("é", 41):  true,  // 246 bytes escaped
+		strings.Repeat("é", 42):  false, // 252 bytes escaped
+		strings.Repeat("旅", 30):  false,
+	} {
+		if got := checkTripSetName(name) == nil; got != ok {
+			t.Errorf("name %q ok got %t want %t", name, got, ok)
+		}
+	}
+
+	// the longest permissible names can be saved
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{strings.Repeat("a", 100), strings.Repeat("é", 41), strings.Repeat("旅", 27)} {
+		if _, err := store.Create(TripSet{Name: name}); err != nil {
+			t.Errorf("could not create %q: %v", name, err)
+		}
+	}
+}
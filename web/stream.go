@@ -0,0 +1,218 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// apiStreamPath is the path of the streaming endpoint, which reads and
+// writes newline delimited json without limiting the body as a whole.
+const apiStreamPath = "/api/v1/stream"
+
+var (
+	// StreamLineLimitSize is the largest amount of bytes permitted in
+	// each line of a stream of trip sets
+	StreamLineLimitSize int = 1 << 17 // ~125k
+
+	// StreamTimeout is the time allowed to read, calculate and respond
+	// to a streaming request, in place of the server timeouts
+	StreamTimeout time.Duration = 30 * time.Minute
+)
+
+// StreamResult is the result of a line of a stream of trip sets, such
+// as `{"line":3,"name":"alice","result":{...}}`. Results are written as
+// soon as each set is calculated, so may not be in the order of the
+// lines.
+type StreamResult struct {
+	Line int `json:"line"` // the line of the set, from 1
+	BatchResult
+}
+
+// StreamStats counts the results of a stream of trip sets.
+type StreamStats struct {
+	Sets     int // sets read
+	Breaches int // sets breaching their rule
+	Errors   int // sets which could not be calculated
+}
+
+// streamLine is a line of a stream of trip sets.
+type streamLine struct {
+	line  int
+	input []byte
+}
+
+// result decodes and calculates the set on the line.
+func (s streamLine) result() StreamResult {
+	set := BatchSet{}
+	decoder := json.NewDecoder(bytes.NewReader(s.input))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&set)
+	switch {
+	case err != nil:
+		err = fmt.Errorf("line is not a valid json set: %w", err)
+		return StreamResult{s.line, BatchResult{Error: &APIErrorDetail{http.StatusBadRequest, APIErrorInvalidJSON, err.Error(), nil}}}
+	case decoder.More():
+		err = errors.New("line has more than one json value")
+		return StreamResult{s.line, BatchResult{Error: &APIErrorDetail{http.StatusBadRequest, APIErrorInvalidJSON, err.Error(), nil}}}
+	case set.Name == "":
+		err = errors.New("set has no name")
+		return StreamResult{s.line, BatchResult{Error: &APIErrorDetail{http.StatusUnprocessableEntity, APIErrorInvalid, err.Error(), nil}}}
+	}
+	return StreamResult{s.line, batchResult(set)}
+}
+
+// readStream sends each non-blank line read from in to lines until in
+// is exhausted or the context is done.
+func readStream(ctx context.Context, in io.Reader, lines chan<- streamLine) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, min(bufio.MaxScanTokenSize, StreamLineLimitSize)), StreamLineLimitSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		input := bytes.TrimSpace(scanner.Bytes())
+		if len(input) == 0 {
+			continue
+		}
+		select {
+		case lines <- streamLine{line, bytes.Clone(input)}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return fmt.Errorf("line %d longer than %d bytes: %w", line+1, StreamLineLimitSize, scanner.Err())
+	}
+	return scanner.Err()
+}
+
+// StreamBatch reads newline delimited json trip sets from in, each of
+// the form of a BatchSet, and writes a StreamResult line to out for each
+// set as soon as it is calculated by one of the workers. Only the lines
+// being calculated are held in memory. Problems with a line are reported
+// in its result; an error is returned if in cannot be read, out cannot
+// be written to or the context is done.
+func StreamBatch(ctx context.Context, in io.Reader, out io.Writer, workers int) (StreamStats, error) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lines := make(chan streamLine)
+	results := make(chan StreamResult)
+	readErr := make(chan error, 1)
+
+	go func() {
+		defer close(lines)
+		readErr <- readStream(ctx, in, lines)
+	}()
+
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Go(func() {
+			for l := range lines {
+				select {
+				case results <- l.result():
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	stats := StreamStats{}
+	encoder := json.NewEncoder(out)
+	for res := range results {
+		stats.Sets++
+		switch {
+		case res.Error != nil:
+			stats.Errors++
+		case res.Result.Breach:
+			stats.Breaches++
+		}
+		if err := encoder.Encode(res); err != nil {
+			cancel()
+			wg.Wait()
+			return stats, fmt.Errorf("write error: %w", err)
+		}
+	}
+	if err := <-readErr; err != nil {
+		return stats, fmt.Errorf("read error: %w", err)
+	}
+	return stats, nil
+}
+
+// flushWriter flushes each write to the client.
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (f flushWriter) Write(b []byte) (int, error) {
+	n, err := f.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	if err := f.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return n, err
+	}
+	return n, nil
+}
+
+// APIStream is the /api/v1/stream POST endpoint, reading newline
+// delimited json trip sets from the request body, each of the form of a
+// set in a BatchRequest, such as
+// `{"name":"alice","trips":[{"start":"2024-07-01","end":"2024-07-14"}]}`,
+// and responding with a newline delimited StreamResult for each set as
+// soon as it is calculated. Each line is limited to StreamLineLimitSize
+// rather than the body as a whole. A problem reading the request after
+// the response has started is reported with a final APIError line.
+func APIStream(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		apiErrorSender(w, http.StatusMethodNotAllowed, APIErrorMethod, fmt.Errorf("endpoint only accepts POST requests, got %s", r.Method))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), StreamTimeout)
+	defer cancel()
+	defer r.Body.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("could not start stream %v", err)
+		return
+	}
+	out := flushWriter{w, rc}
+
+	stats, err := StreamBatch(ctx, r.Body, out, BatchWorkers)
+	if err == nil {
+		return
+	}
+	code := APIErrorInvalid
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, bufio.ErrTooLong):
+		code, status = APIErrorTooLarge, http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded):
+		code, status = APIErrorTimeout, http.StatusServiceUnavailable
+	}
+	log.Printf("stream stopped after %d sets: %v", stats.Sets, err)
+	if err := json.NewEncoder(out).Encode(APIError{APIErrorDetail{status, code, err.Error(), nil}}); err != nil {
+		log.Printf("could not write stream error %v", err)
+	}
+}
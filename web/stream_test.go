@@ -0,0 +1,247 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/timeaway/trips"
+)
+
+// streamResult is a decoded StreamResult, with the trip problems of an
+// error left undecoded.
+type streamResult struct {
+	Line   int
+	Name   string
+	Result *CalculationResponse
+	Error  *struct {
+		Status  int
+		Code    string
+		Message string
+		Trips   []json.RawMessage
+	}
+}
+
+// streamResults decodes the result lines of a stream, by line.
+func streamResults(t *testing.T, output string) map[int]streamResult {
+	t.Helper()
+	results := map[int]streamResult{}
+	for l := range strings.Lines(output) {
+		res := streamResult{}
+		if err := json.Unmarshal([]byte(l), &res); err != nil {
+			t.Fatalf("could not decode result line %q: %v", l, err)
+		}
+		results[res.Line] = res
+	}
+	return results
+}
+
+func TestStreamBatch(t *testing.T) {
+
+	calculate = trips.Calculate
+	holidayJSONDecoder = trips.HolidaysJSONDecoder
+	tripsJSONMarshal = json.Marshal
+
+	input := strings.Join([]string{
+		`{"name":"alice","trips":[{"start":"2023-01-01","end":"2023-04-10"}]}`,
+		``,
+		`{"name":"bob","trips":[{"start":"2023-01-10","end":"2023-01-01"}]}`,
+		`{"name":"carol","trips":[{"start":"2023-01-01","end":"2023-01-10"}],"rule":{"name":"uk-tax-year"}}`,
+		`{"trips":[{"start":"2023-01-01","end":"2023-01-10"}]}`,
+		`{"name":"dave",`,
+	}, "\n")
+
+	var out strings.Builder
+	stats, err := StreamBatch(context.Background(), strings.NewReader(input), &out, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats, (StreamStats{Sets: 5, Breaches: 1, Errors: 3}); got != want {
+		t.Errorf("stats got %+v want %+v", got, want)
+	}
+
+	results := streamResults(t, out.String())
+	lines := []int{}
+	for l := range results {
+		lines = append(lines, l)
+	}
+	slices.Sort(lines)
+	if got, want := lines, []int{1, 3, 4, 5, 6}; !slices.Equal(got, want) {
+		t.Fatalf("result lines got %v want %v", got, want)
+	}
+	for _, tt := range []struct {
+		line  int
+		name  string
+		check func(streamResult) bool
+	}{
+		{1, "alice", func(r streamResult) bool { return r.Result.Breach && r.Result.DaysAway == 100 }},
+		{3, "bob", func(r streamResult) bool { return r.Error.Code == APIErrorTrips && len(r.Error.Trips) == 1 }},
+		{4, "carol", func(r streamResult) bool { return r.Result.Rule.Name == "uk-tax-year" }},
+		{5, "", func(r streamResult) bool { return r.Error.Message == "set has no name" }},
+		{6, "", func(r streamResult) bool { return r.Error.Code == APIErrorInvalidJSON }},
+	} {
+		r := results[tt.line]
+		if got, want := r.Name, tt.name; got != want {
+			t.Errorf("line %d name got %q want %q", tt.line, got, want)
+		}
+		if !tt.check(r) {
+			t.Errorf("line %d unexpected result %+v", tt.line, r)
+		}
+	}
+}
+
+// failWriter fails every write.
+type failWriter struct{}
+
+func (failWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("closed")
+}
+
+func TestStreamBatchErrors(t *testing.T) {
+
+	defer func(n int) { StreamLineLimitSize = n }(StreamLineLimitSize)
+	StreamLineLimitSize = 100
+
+	set := `{"name":"alice","trips":[{"start":"2023-01-01","end":"2023-01-10"}]}`
+	long := `{"name":"bob","trips":[` + strings.Repeat(`{"start":"2023-01-01","end":"2023-01-10"},`, 5) + `]}`
+
+	var out strings.Builder
+	stats, err := StreamBatch(context.Background(), strings.NewReader(set+"\n"+long+"\n"+set), &out, 2)
+	if !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("expected a too long error, got %v", err)
+	}
+	if got, want := stats.Sets, 1; got != want {
+		t.Errorf("sets got %d want %d", got, want)
+	}
+
+	_, err = StreamBatch(context.Background(), strings.NewReader(set+"\n"+set), failWriter{}, 2)
+	if err == nil || !strings.Contains(err.Error(), "write error") {
+		t.Errorf("expected a write error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = StreamBatch(ctx, strings.NewReader(set+"\n"+set), &out, 2)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancelled error, got %v", err)
+	}
+}
+
+func TestAPIStream(t *testing.T) {
+
+	calculate = trips.Calculate
+	holidayJSONDecoder = trips.HolidaysJSONDecoder
+	tripsJSONMarshal = json.Marshal
+
+	// a body much larger than the body limit of other requests
+	var body strings.Builder
+	for i := range 2000 {
+		fmt.Fprintf(&body, `{"name":"set %d","trips":[{"start":"2023-01-01","end":"2023-01-%02d"}]}`+"\n", i, i%28+1)
+	}
+	if int64(body.Len()) < BodyLimitSize {
+		t.Fatalf("body of %d bytes too small", body.Len())
+	}
+
+	r := apiRouter()
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/api/v1/stream", strings.NewReader(body.String()))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	res := w.Result()
+	if got, want := res.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("status got %d want %d", got, want)
+	}
+	if got, want := res.Header.Get("Content-Type"), "application/x-ndjson"; got != want {
+		t.Errorf("content type got %s want %s", got, want)
+	}
+	results := streamResults(t, w.Body.String())
+	if got, want := len(results), 2000; got != want {
+		t.Fatalf("results got %d want %d", got, want)
+	}
+	for line, r := range results {
+		if got, want := r.Name, fmt.Sprintf("set %d", line-1); got != want {
+			t.Errorf("line %d name got %q want %q", line, got, want)
+		}
+		if got, want := r.Result.DaysAway, (line-1)%28+1; got != want {
+			t.Errorf("line %d days away got %d want %d", line, got, want)
+		}
+	}
+
+	// a line which is too long is reported with a final error line
+	defer func(n int) { StreamLineLimitSize = n }(StreamLineLimitSize)
+	StreamLineLimitSize = 10
+	req = httptest.NewRequest(http.MethodPost, "http://example.com/api/v1/stream", strings.NewReader(body.String()))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got, want := w.Body.String(), `{"error":{"status":413,"code":"body_too_large","message":"read error: line 1 longer than 10 bytes`; !strings.HasPrefix(got, want) {
+		t.Errorf("body got %s want prefix %s", got, want)
+	}
+}
+
+// TestAPIStreamInterleaved checks that the result of each set is
+// received before the next set is sent.
+func TestAPIStreamInterleaved(t *testing.T) {
+
+	calculate = trips.Calculate
+	holidayJSONDecoder = trips.HolidaysJSONDecoder
+	tripsJSONMarshal = json.Marshal
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/v1/stream", APIStream)
+	r.Use(deadlineMiddleware)
+	r.Use(bodyLimitMiddleware)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pr, pw := io.Pipe()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/v1/stream", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type response struct {
+		res *http.Response
+		err error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		res, err := http.DefaultClient.Do(req)
+		responses <- response{res, err}
+	}()
+
+	var reader *bufio.Reader
+	for i := range 3 {
+		if _, err := fmt.Fprintf(pw, `{"name":"set %d","trips":[{"start":"2023-01-01","end":"2023-01-10"}]}`+"\n", i); err != nil {
+			t.Fatal(err)
+		}
+		if reader == nil {
+			resp := <-responses
+			if resp.err != nil {
+				t.Fatal(resp.err)
+			}
+			defer resp.res.Body.Close()
+			reader = bufio.NewReader(resp.res.Body)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := line, fmt.Sprintf(`{"line":%d,"name":"set %d"`, i+1, i); !strings.HasPrefix(got, want) {
+			t.Errorf("result got %s want prefix %s", got, want)
+		}
+	}
+	pw.Close()
+	if rest, err := io.ReadAll(reader); err != nil || len(rest) > 0 {
+		t.Errorf("unexpected end of stream %q %v", rest, err)
+	}
+}
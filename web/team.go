@@ -0,0 +1,343 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rorycl/timeaway/svg"
+	"github.com/rorycl/timeaway/trips"
+)
+
+// teamSizeMax is the largest number of travellers on the team page.
+const teamSizeMax = 20
+
+// The team page form and url query name the fields of each traveller
+// with the suffix of the traveller's index, such as "Name.0", "Start.0"
+// and "End.0" for the first traveller. The index of a traveller only
+// serves to group its fields and need not be consecutive.
+
+// travellerSuffix returns the field name suffix of the traveller with
+// index i.
+func travellerSuffix(i int) string {
+	return "." + strconv.Itoa(i)
+}
+
+// travellerFields are the fields of each traveller other than the name.
+var travellerFields = []string{"Start", "End", "Country", "Type"}
+
+// teamIndices returns the ordered indexes of the travellers in q,
+// identified by their "Name" fields.
+func teamIndices(q url.Values) ([]int, error) {
+	return nameIndices(q, "traveller", teamSizeMax)
+}
+
+// nameIndices returns the ordered indexes of the named groups of fields
+// in q, such as travellers, identified by their "Name" fields, allowing
+// no more than most groups.
+func nameIndices(q url.Values, noun string, most int) ([]int, error) {
+	indices := []int{}
+	for k := range q {
+		n, ok := strings.CutPrefix(k, "Name.")
+		if !ok {
+			continue
+		}
+		i, err := strconv.Atoi(n)
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("invalid %s field %q", noun, k)
+		}
+		indices = append(indices, i)
+	}
+	if len(indices) > most {
+		return nil, fmt.Errorf("no more than %d %ss may be calculated together", most, noun)
+	}
+	slices.Sort(indices)
+	return indices, nil
+}
+
+// travellerQuery returns the trip fields in q of the traveller with
+// index i without their suffix, suitable for
+// trips.HolidaysURLDecoder.
+func travellerQuery(q url.Values, i int) url.Values {
+	tq := url.Values{}
+	for _, f := range travellerFields {
+		if v, ok := q[f+travellerSuffix(i)]; ok {
+			tq[f] = v
+		}
+	}
+	return tq
+}
+
+// travellerName returns the name of the traveller with index i in q,
+// numbering travellers without a name by their position n.
+func travellerName(q url.Values, i, n int) string {
+	return groupName(q, i, n, "traveller")
+}
+
+// groupName returns the name of the group of fields with index i in q,
+// naming groups without a name by the noun and their position n.
+func groupName(q url.Values, i, n int, noun string) string {
+	name := strings.TrimSpace(q.Get("Name" + travellerSuffix(i)))
+	if name == "" {
+		name = fmt.Sprintf("%s %d", noun, n+1)
+	}
+	return name
+}
+
+// teamQuery returns the url query of the team page for the holidays of
+// each named traveller, in the form made by trips.HolidaysURLEncode
+// with the traveller suffix added to each field.
+func teamQuery(names []string, holidays [][]trips.Holiday) string {
+	parts := []string{}
+	for i, name := range names {
+		suffix := travellerSuffix(i)
+		parts = append(parts, "Name"+suffix+"="+url.QueryEscape(name))
+		q, _ := url.ParseQuery(trips.HolidaysURLEncode(holidays[i]))
+		for j := range q["Start"] {
+			for _, f := range travellerFields {
+				if len(q[f]) > j {
+					parts = append(parts, f+suffix+"="+url.QueryEscape(q[f][j]))
+				}
+			}
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// travellerForm describes the form fields of a traveller on the team
+// page, or of another named group of trips, such as a scenario, as
+// described by the Noun.
+type travellerForm struct {
+	Index       int
+	Suffix      string
+	Noun        string
+	Name        string
+	Holidays    []trips.Holiday
+	DefaultDate time.Time
+}
+
+// Team shows the team page, on which the trips of several named
+// travellers are calculated together. The travellers, if any, are
+// read from the url query, otherwise the page starts with two.
+func Team(w http.ResponseWriter, r *http.Request) {
+
+	// date about 6 months ago
+	defaultDate := time.Now().Add(time.Hour * -24 * 7 * 26)
+
+	q := r.URL.Query()
+	indices, err := teamIndices(q)
+	if err != nil || len(indices) == 0 {
+		indices = []int{0, 1}
+	}
+	travellers := []travellerForm{}
+	for _, i := range indices {
+		holidays, _ := trips.HolidaysURLDecoder(travellerQuery(q, i)) // errors are shown on calculation
+		travellers = append(travellers, travellerForm{
+			Index:       i,
+			Suffix:      travellerSuffix(i),
+			Noun:        "traveller",
+			Name:        strings.TrimSpace(q.Get("Name" + travellerSuffix(i))),
+			Holidays:    holidays,
+			DefaultDate: defaultDate,
+		})
+	}
+
+	t := template.New("team.html")
+	t = t.Funcs(webFuncMap)
+	t, err = t.ParseFS(DirFS.TplFS, "team.html", "partial-traveller.html")
+	if err != nil {
+		log.Printf("team template parse error %v", err)
+		http.Error(w, "template error; apologies", http.StatusInternalServerError)
+		return
+	}
+
+	ruleName := q.Get("rule")
+	if ruleName == "" {
+		ruleName = DefaultRule
+	}
+	merge, _ := mergeFromQuery(q)
+
+	data := struct {
+		Title      string
+		Travellers []travellerForm
+		Next       int
+		Rules      []trips.Rule
+		Rule       string
+		Merge      bool
+	}{
+		"team trip calculator",
+		travellers,
+		indices[len(indices)-1] + 1,
+		trips.Rules(),
+		ruleName,
+		merge,
+	}
+	err = t.Execute(w, data)
+	if err != nil {
+		log.Printf("team template writing error %v", err)
+		http.Error(w, "template writing error.", http.StatusInternalServerError)
+	}
+}
+
+// PartialAddTraveller adds the fields of a traveller to the team page
+// form, with the index given by the "traveller" query parameter,
+// followed by a button to add the next traveller.
+func PartialAddTraveller(w http.ResponseWriter, r *http.Request) {
+
+	// date about 6 months ago
+	defaultDate := time.Now().Add(time.Hour * -24 * 7 * 26)
+
+	i, err := strconv.Atoi(r.URL.Query().Get("traveller"))
+	if err != nil || i < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Printf("invalid traveller %q", r.URL.Query().Get("traveller"))
+		return
+	}
+
+	t := template.New("partial-addtraveller.html")
+	t = t.Funcs(webFuncMap)
+	t, err = t.ParseFS(DirFS.TplFS, "partial-addtraveller.html", "partial-traveller.html")
+	if err != nil {
+		log.Printf("partial add traveller template parse error %v", err)
+		http.Error(w, "template error; apologies", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Traveller travellerForm
+		Next      int
+		Full      bool
+	}{
+		Traveller: travellerForm{Index: i, Suffix: travellerSuffix(i), Noun: "traveller", DefaultDate: defaultDate},
+		Next:      i + 1,
+		Full:      i+1 >= teamSizeMax,
+	}
+	err = t.Execute(w, data)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "template writing problem : %s", err.Error())
+	}
+}
+
+// travellerResult is the calculation of the trips of a traveller shown
+// on the team page.
+type travellerResult struct {
+	Name          string
+	Trips         *trips.Trips
+	DaysRemaining int    // days remaining on the last day of the trips
+	Error         string // the reason the trips could not be calculated
+}
+
+// PartialTeam shows the results of a team page form submission in
+// html, calculating the trips of each traveller independently with the
+// selected rule. A summary table reports each traveller's breaches and
+// days remaining, and the trips of the travellers whose trips could be
+// calculated are shown together in a calendar.
+func PartialTeam(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Print("endpoint only accepts POST requests, got", r.Method)
+		return
+	}
+
+	output := struct {
+		Error       error
+		Description string
+		Travellers  []travellerResult
+		Plot        template.HTML
+	}{}
+
+	// writer writes the output
+	writer := func() {
+		t := template.Must(template.ParseFS(DirFS.TplFS, "partial-team.html"))
+		err := t.Execute(w, output)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "template writing problem : %s", err.Error())
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		output.Error = err
+		writer()
+		return
+	}
+	q := r.PostForm
+	merge, err := mergeFromQuery(q)
+	if err != nil {
+		output.Error = err
+		writer()
+		return
+	}
+	rule, err := calculationRule(q.Get("rule"))
+	if err != nil {
+		output.Error = err
+		writer()
+		return
+	}
+	output.Description = rule.Description()
+	indices, err := teamIndices(q)
+	if err == nil && len(indices) == 0 {
+		err = errors.New("no travellers were found")
+	}
+	if err != nil {
+		output.Error = err
+		writer()
+		return
+	}
+
+	// calculate the trips of each traveller
+	names, holidays := []string{}, [][]trips.Holiday{}
+	team := []svg.Traveller{}
+	for n, i := range indices {
+		result := travellerResult{Name: travellerName(q, i, n)}
+		hols, err := trips.HolidaysURLDecoder(travellerQuery(q, i))
+		err = mergeable(err, merge)
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case len(hols) < 1:
+			result.Error = "no holidays were found"
+		default:
+			// error captured in trs.Error
+			trs, _ := calculate(hols, ruleOptions(rule, merge)...)
+			if trs.Error != nil {
+				result.Error = trs.Error.Error()
+				break
+			}
+			result.Trips = trs
+			result.DaysRemaining = trs.DaysRemaining(trs.End)
+			team = append(team, svg.Traveller{Name: result.Name, Trips: trs})
+		}
+		output.Travellers = append(output.Travellers, result)
+		names = append(names, result.Name)
+		holidays = append(holidays, hols)
+	}
+
+	// push htmx browser url to client's browser history
+	query := teamQuery(names, holidays) + "&rule=" + url.QueryEscape(rule.Name())
+	if merge {
+		query += "&merge=true"
+	}
+	w.Header().Set("HX-Push-Url", BaseURL+"/team?"+query)
+
+	// svg creation. The Plot output is verbatim svg that should not be
+	// escaped.
+	if len(team) > 0 {
+		var svgPlot strings.Builder
+		err := svg.TeamAsSVG(team, &svgPlot)
+		if err != nil {
+			log.Printf("plotting error: %v", err)
+		}
+		output.Plot = template.HTML(svgPlot.String())
+	}
+	writer()
+}
@@ -0,0 +1,254 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rorycl/timeaway/trips"
+)
+
+// TestTeam tests the team page shows the travellers in the url query.
+func TestTeam(t *testing.T) {
+
+	DirFS = &fileSystem{}
+	DirFS.TplFS = os.DirFS("templates")
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name: "new team",
+			want: []string{
+				`name="Name.0"`,
+				`name="Name.1"`,
+				`hx-get="./partials/addtrip?traveller=1"`,
+				`hx-get="./partials/addtraveller?traveller=2"`,
+			},
+		},
+		{
+			name:  "travellers from query",
+			query: "Name.0=Alice&Start.0=2023-01-01&End.0=2023-01-10&Name.3=Bob&Start.3=2023-02-01&End.3=2023-02-10&Country.3=IE&rule=uk-tax-year",
+			want: []string{
+				`name="Name.0" value="Alice"`,
+				`name="Start.0"
+    value="2023-01-01"`,
+				`name="Name.3" value="Bob"`,
+				`<option value="IE" selected>Ireland</option>`,
+				`hx-get="./partials/addtraveller?traveller=4"`,
+				`<option value="uk-tax-year" selected>`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/team?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			Team(w, r)
+			if got, want := w.Result().StatusCode, http.StatusOK; got != want {
+				t.Errorf("status got %d want %d", got, want)
+			}
+			body := w.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body does not contain %q:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+// TestTeamPartials tests adding travellers and their trips to the team
+// page form.
+func TestTeamPartials(t *testing.T) {
+
+	DirFS = &fileSystem{}
+	DirFS.TplFS = os.DirFS("templates")
+
+	tests := []struct {
+		name       string
+		fn         func(w http.ResponseWriter, r *http.Request)
+		url        string
+		statusCode int
+		want       []string
+	}{
+		{
+			name:       "add traveller",
+			fn:         PartialAddTraveller,
+			url:        "/partials/addtraveller?traveller=2",
+			statusCode: http.StatusOK,
+			want:       []string{`name="Name.2"`, `<div id="rpl-2"></div>`, `hx-get="./partials/addtraveller?traveller=3"`},
+		},
+		{
+			name:       "add last traveller",
+			fn:         PartialAddTraveller,
+			url:        "/partials/addtraveller?traveller=19",
+			statusCode: http.StatusOK,
+			want:       []string{`name="Name.19"`, "No more travellers can be added."},
+		},
+		{
+			name:       "add invalid traveller",
+			fn:         PartialAddTraveller,
+			url:        "/partials/addtraveller?traveller=x",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "add traveller trip",
+			fn:         PartialAddTrip,
+			url:        "/partials/addtrip?traveller=2",
+			statusCode: http.StatusOK,
+			want:       []string{`name="Start.2"`, `name="Country.2"`, `<div id="rpl-2"></div>`},
+		},
+		{
+			name:       "add trip",
+			fn:         PartialAddTrip,
+			url:        "/partials/addtrip",
+			statusCode: http.StatusOK,
+			want:       []string{`name="Start"`, `<div id="rpl"></div>`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.url, nil)
+			w := httptest.NewRecorder()
+			tt.fn(w, r)
+			if got, want := w.Result().StatusCode, tt.statusCode; got != want {
+				t.Errorf("status got %d want %d", got, want)
+			}
+			body := w.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body does not contain %q:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+// TestPartialTeam tests the team report partial.
+func TestPartialTeam(t *testing.T) {
+
+	DirFS = &fileSystem{}
+	DirFS.TplFS = os.DirFS("templates")
+	calculate = trips.Calculate
+
+	tests := []struct {
+		name    string
+		input   string // form body
+		pushURL string
+		want    []string
+	}{
+		{
+			name: "two travellers",
+			input: "Name.0=Alice&Start.0=2023-01-01&End.0=2023-04-10&" +
+				"Name.4=Bob&Start.4=2023-06-01&End.4=2023-06-14&Country.4=FR",
+			pushURL: "/team?Name.0=Alice&Start.0=2023-01-01&End.0=2023-04-10&Name.1=Bob&Start.1=2023-06-01&End.1=2023-06-14&Country.1=FR&rule=schengen",
+			want: []string{
+				"compared with the 90 days in any 180 day period rule",
+				"<tr class=\"breached\">\n    <td>Alice</td>\n    <td>1</td>\n    <td>100 of 90</td>\n    <td>1</td>\n    <td>0</td>\n    <td>10/04/2023</td>",
+				"<tr>\n    <td>Bob</td>\n    <td>1</td>\n    <td>14 of 90</td>\n    <td>0</td>\n    <td>76</td>\n    <td>14/06/2023</td>",
+				"<title>holiday (Alice) : 2023-01-01 to 2023-04-10</title>",
+				"<title>holiday (Bob) : 2023-06-01 to 2023-06-14</title>",
+			},
+		},
+		{
+			name:    "unnamed traveller with an invalid trip",
+			input:   "Name.0=Alice&Start.0=2023-01-01&End.0=2023-01-10&Name.1=&Start.1=2023-03-10&End.1=2023-03-01&merge=true",
+			pushURL: "/team?Name.0=Alice&Start.0=2023-01-01&End.0=2023-01-10&Name.1=traveller+2&Start.1=2023-03-10&End.1=2023-03-01&rule=schengen&merge=true",
+			want: []string{
+				"<td>traveller 2</td>\n    <td colspan=\"5\">could not be calculated: trip 1: start date 10/03/2023 after 01/03/2023",
+				"<title>holiday (Alice) : 2023-01-01 to 2023-01-10</title>",
+			},
+		},
+		{
+			name: "traveller with only an exempt period",
+			input: "Name.0=Alice&Start.0=2023-01-01&End.0=2023-01-10&Type.0=trip&" +
+				"Name.1=Bob&Start.1=2023-02-01&End.1=2023-02-10&Type.1=exempt",
+			want: []string{
+				"<td>Bob</td>\n    <td colspan=\"5\">could not be calculated: no trips were provided to calculate, only exempt periods",
+				"<title>holiday (Alice) : 2023-01-01 to 2023-01-10</title>",
+			},
+		},
+		{
+			name:  "traveller without trips",
+			input: "Name.0=Alice",
+			want:  []string{"could not be calculated: no holidays were found"},
+		},
+		{
+			name:  "no travellers",
+			input: "Start=2023-01-01&End=2023-01-10",
+			want:  []string{"no travellers were found"},
+		},
+		{
+			name:  "unknown rule",
+			input: "Name.0=Alice&Start.0=2023-01-01&End.0=2023-01-10&rule=unknown",
+			want:  []string{`rule &#34;unknown&#34; not known`},
+		},
+		{
+			name:  "invalid traveller",
+			input: "Name.x=Alice",
+			want:  []string{`invalid traveller field &#34;Name.x&#34;`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://example.com/partials/team", strings.NewReader(tt.input))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			PartialTeam(w, r)
+			body := w.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body does not contain %q:\n%s", want, body)
+				}
+			}
+			if tt.pushURL == "" {
+				return
+			}
+			if got, want := w.Header().Get("HX-Push-Url"), BaseURL+tt.pushURL; got != want {
+				t.Errorf("push url got\n%s want\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestTeamQuery tests the team page query reads back the travellers.
+func TestTeamQuery(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse(time.DateOnly, s)
+		return d
+	}
+	holidays := [][]trips.Holiday{
+		{{Start: day("2023-01-01"), End: day("2023-01-10"), Country: "FR"}, {Start: day("2023-02-01"), End: day("2023-02-02")}},
+		{{Start: day("2023-03-01"), End: day("2023-03-31"), Exempt: true}},
+	}
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/team?"+teamQuery([]string{"Alice & Bob", "Carol"}, holidays), nil)
+	q := r.URL.Query()
+	indices, err := teamIndices(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(indices), 2; got != want {
+		t.Fatalf("travellers got %d want %d", got, want)
+	}
+	if got, want := travellerName(q, indices[0], 0), "Alice & Bob"; got != want {
+		t.Errorf("name got %q want %q", got, want)
+	}
+	for n, i := range indices {
+		hols, err := trips.HolidaysURLDecoder(travellerQuery(q, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := trips.HolidaysURLEncode(hols), trips.HolidaysURLEncode(holidays[n]); got != want {
+			t.Errorf("traveller %d holidays got %s want %s", n, got, want)
+		}
+	}
+}
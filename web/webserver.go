@@ -1,6 +1,7 @@
 package web
 
 import (
+	"bytes"
 	_ "embed"
 	"encoding/json"
 	"errors"
@@ -9,11 +10,16 @@ import (
 	"io"
 	"io/fs"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -35,6 +41,31 @@ var (
 
 	// BaseURL is the base url for redirects, etc.
 	BaseURL string = ""
+
+	// TimelineMaxHorizon is the largest number of days past the end of
+	// the trips for which a timeline may be requested
+	TimelineMaxHorizon int = 3660
+
+	// DefaultRule is the name of the rule used for calculations when no
+	// rule is selected
+	DefaultRule string = trips.DefaultRuleName
+
+	// CalendarRefresh is how often calendar apps subscribed to the
+	// /calendar.ics feed are asked to refresh it
+	CalendarRefresh time.Duration = 12 * time.Hour
+
+	// DefaultMerge sets if overlapping trips are merged when the
+	// "merge" parameter is not provided
+	DefaultMerge bool = false
+
+	// SuggestionsShown is the most suggested changes shown for trips in
+	// breach of their rule
+	SuggestionsShown int = 5
+
+	// ReportForecast is the number of days after the last trip for
+	// which the report calendar forecasts the allowance remaining when
+	// the "forecast" parameter is not provided, or 0 for no forecast
+	ReportForecast int = 0
 )
 
 // development/testing vars
@@ -43,9 +74,19 @@ var (
 	// holidayJSONDecoder sets the holiday POST decoder
 	holidayJSONDecoder func([]byte) ([]trips.Holiday, error) = trips.HolidaysJSONDecoder
 
+	// holidayCSVDecoder sets the holiday POST decoder for csv content
+	holidayCSVDecoder func([]byte, ...trips.CSVOption) ([]trips.Holiday, error) = trips.HolidaysCSVDecoder
+
+	// holidayICSDecoder sets the holiday POST decoder for iCalendar content
+	holidayICSDecoder func([]byte, ...trips.ICSOption) ([]trips.Holiday, error) = trips.HolidaysICSDecoder
+
 	// calculate sets the calculation method in use to allow swapping
 	// out for testing
-	calculate func([]trips.Holiday) (*trips.Trips, error) = trips.Calculate
+	calculate func([]trips.Holiday, ...trips.Option) (*trips.Trips, error) = trips.Calculate
+
+	// planStay sets the stay planning method in use to allow swapping
+	// out for testing
+	planStay func([]trips.Holiday, time.Time, ...trips.Option) (*trips.Plan, error) = trips.PlanStay
 
 	// tripsJSONMarshall sets the holiday marshaller
 	tripsJSONMarshal func(v any) ([]byte, error) = json.Marshal
@@ -116,13 +157,41 @@ func Serve(addr, port, baseURL string) {
 	r.HandleFunc("/partials/report", PartialReport)
 	r.HandleFunc("/partials/nocontent", PartialNoContent)
 	r.HandleFunc("/partials/addtrip", PartialAddTrip)
+	r.HandleFunc("/partials/plan", PartialPlan)
+	r.HandleFunc("/partials/upload", PartialUpload)
+	r.HandleFunc("/partials/sets", PartialSets)
+	r.HandleFunc("/partials/sets/save", PartialSetSave)
+	r.HandleFunc("/partials/sets/rename", PartialSetRename)
+	r.HandleFunc("/partials/sets/delete", PartialSetDelete)
+	r.HandleFunc("/partials/team", PartialTeam)
+	r.HandleFunc("/partials/addtraveller", PartialAddTraveller)
+	r.HandleFunc("/partials/compare", PartialCompare)
+	r.HandleFunc("/partials/addscenario", PartialAddScenario)
 
 	// main routes
 	r.HandleFunc("/", Home)
 	r.HandleFunc("/home", Home)
+	r.HandleFunc("/team", Team)
+	r.HandleFunc("/compare", Compare)
 	r.HandleFunc("/trips", Trips)
+	r.HandleFunc("/plan", Plan)
+	r.HandleFunc("/timeline", Timeline)
+	r.HandleFunc("/timeline.csv", TimelineCSV)
+	r.HandleFunc("/trips.ics", TripsICS)
+	r.HandleFunc("/calendar.ics", CalendarICS)
+	r.HandleFunc("/sets", SavedSets)
+	r.HandleFunc("/sets/{name}", SavedSet)
 	r.HandleFunc("/health", Health)
 
+	// api routes, reporting unknown api paths with an api error
+	r.HandleFunc("/api/v1/calculate", APICalculate)
+	r.HandleFunc("/api/v1/batch", APIBatch)
+	r.HandleFunc("/api/v1/stream", APIStream)
+	r.HandleFunc("/api/v1/compare", APICompare)
+	r.HandleFunc("/api/v1/rules", APIRules)
+	r.HandleFunc("/api/v1/openapi.json", APIOpenAPI)
+	r.PathPrefix("/api/").HandlerFunc(APINotFound)
+
 	// logging converts gorilla's handlers.CombinedLoggingHandler to a
 	// func(http.Handler) http.Handler to satisfy type MiddlewareFunc
 	logging := func(handler http.Handler) http.Handler {
@@ -141,6 +210,7 @@ func Serve(addr, port, baseURL string) {
 	}
 
 	// attach middleware
+	r.Use(deadlineMiddleware)
 	r.Use(bodyLimitMiddleware)
 	r.Use(logging)
 	r.Use(compressor)
@@ -165,6 +235,17 @@ func Serve(addr, port, baseURL string) {
 	}
 }
 
+// forecastChoices returns the forecast horizons offered on the home
+// page, including the selected forecast if it is not one of them.
+func forecastChoices(forecast int) []int {
+	choices := []int{90, 180, 365}
+	if forecast > 0 && !slices.Contains(choices, forecast) {
+		choices = append(choices, forecast)
+		slices.Sort(choices)
+	}
+	return choices
+}
+
 // Home is the home page
 func Home(w http.ResponseWriter, r *http.Request) {
 
@@ -186,18 +267,38 @@ func Home(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the selected rule and overlap handling
+	ruleName := r.URL.Query().Get("rule")
+	if ruleName == "" {
+		ruleName = DefaultRule
+	}
+	merge, _ := mergeFromQuery(r.URL.Query())
+	forecast, _ := forecastFromQuery(r.URL.Query())
+
 	data := struct {
 		Title       string
 		Address     string
 		Port        string
 		InputDates  []trips.Holiday
 		DefaultDate time.Time
+		Rules       []trips.Rule
+		Rule        string
+		Merge       bool
+		Forecast    int
+		Forecasts   []int
+		Saving      bool
 	}{
 		"trip calculator",
 		ServerAddress,
 		ServerPort,
 		holidays,
 		defaultDate,
+		trips.Rules(),
+		ruleName,
+		merge,
+		forecast,
+		forecastChoices(forecast),
+		TripStore != nil,
 	}
 	err = t.Execute(w, data)
 	if err != nil {
@@ -208,23 +309,19 @@ func Home(w http.ResponseWriter, r *http.Request) {
 
 // Trips is a POST endpoint for JSON queries, receiving json dates,
 // turning this data into Holidays and then performing a calculation on
-// the data, finally returning the json result.
+// the data, finally returning the json result. The rule to calculate
+// with may be selected by name with the "rule" query parameter. The
+// results of every registered rule are reported in "results". Overlapping
+// trips are merged if the "merge" query parameter is true. Trips may
+// also be POSTed as csv with a text/csv content type, or as iCalendar
+// with a text/calendar content type; see decodeBody.
 func Trips(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	// short cut error retuener
+	// short cut error returner
 	errSender := func(note string, err error) {
-		w.WriteHeader(http.StatusBadRequest)
-		j, _ := json.Marshal(struct {
-			Error string
-		}{
-			Error: note + " " + err.Error(),
-		})
-		_, err = w.Write(j)
-		if err != nil {
-			log.Printf("could not write trips json error %v", err)
-		}
+		jsonErrorSender(w, note, err)
 	}
 
 	if r.Method != "POST" {
@@ -233,6 +330,17 @@ func Trips(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rule, err := calculationRule(r.URL.Query().Get("rule"))
+	if err != nil {
+		errSender("rule error:", err)
+		return
+	}
+	merge, err := mergeFromQuery(r.URL.Query())
+	if err != nil {
+		errSender("query error", err)
+		return
+	}
+
 	// read body
 	body, err := io.ReadAll(r.Body)
 	defer r.Body.Close()
@@ -244,14 +352,14 @@ func Trips(w http.ResponseWriter, r *http.Request) {
 		log.Println("body content:", string(body))
 	}
 
-	// extract holidays from POSTed json
-	holidays, err := holidayJSONDecoder(body)
-	if err != nil {
-		errSender("form json decoding error", err)
+	// extract holidays from POSTed json or csv
+	holidays, err := decodeBody(r, body)
+	if err = mergeable(err, merge); err != nil {
+		errSender(decodingNote(r), err)
 		return
 	}
 	if len(holidays) < 1 {
-		errSender("no holidays were found", nil)
+		errSender("no holidays were found", errors.New("empty list"))
 		return
 	}
 	if inDevelopment {
@@ -259,7 +367,7 @@ func Trips(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// perform the calculation
-	trs, err := calculate(holidays)
+	trs, err := calculate(holidays, append(ruleOptions(rule, merge), trips.WithRules(trips.Rules()...))...)
 	if err != nil {
 		errSender("calculation error: ", err)
 		return
@@ -284,6 +392,520 @@ func Trips(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// decodeBody decodes the holidays in a POSTed body as csv if the request
+// has a text/csv content type, as iCalendar if it has a text/calendar
+// content type, or else as json. See csvOptionsFromQuery and
+// icsOptionsFromQuery for the query parameters describing csv and
+// iCalendar input.
+func decodeBody(r *http.Request, body []byte) ([]trips.Holiday, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return decodeMediaType(mediaType, r.URL.Query(), body)
+}
+
+// decodingNote returns the note reporting a problem decoding the body
+// of r with decodeBody, naming the format decoded.
+func decodingNote(r *http.Request) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return "form csv decoding error"
+	case "text/calendar":
+		return "form ics decoding error"
+	}
+	return "form json decoding error"
+}
+
+// decodeMediaType decodes the holidays in body as csv, iCalendar or
+// json by media type, with the options for csv and iCalendar described
+// by the query or form parameters q.
+func decodeMediaType(mediaType string, q url.Values, body []byte) ([]trips.Holiday, error) {
+	switch mediaType {
+	case "text/csv":
+		options, err := csvOptionsFromQuery(q)
+		if err != nil {
+			return nil, err
+		}
+		return holidayCSVDecoder(body, options...)
+	case "text/calendar":
+		options, err := icsOptionsFromQuery(q)
+		if err != nil {
+			return nil, err
+		}
+		return holidayICSDecoder(body, options...)
+	}
+	return holidayJSONDecoder(body)
+}
+
+// icsOptionsFromQuery returns the options for decoding iCalendar from
+// the url query or form parameter "inclusiveEnd", which if true treats
+// the end dates of all-day events as the last day away.
+func icsOptionsFromQuery(q url.Values) ([]trips.ICSOption, error) {
+	options := []trips.ICSOption{}
+	i := q.Get("inclusiveEnd")
+	if i == "" {
+		return options, nil
+	}
+	inclusive, err := strconv.ParseBool(i)
+	if err != nil {
+		return nil, fmt.Errorf("invalid inclusiveEnd %q", i)
+	}
+	if inclusive {
+		options = append(options, trips.WithICSInclusiveEnd())
+	}
+	return options, nil
+}
+
+// csvOptionsFromQuery returns the options for decoding csv from the url
+// query or form parameters "startColumn", "endColumn", "countryColumn"
+// and "typeColumn" naming the csv columns, "dateFormat" setting the date
+// layouts to try, which may be repeated, and "delimiter" setting the
+// field delimiter, which may be "tab".
+func csvOptionsFromQuery(q url.Values) ([]trips.CSVOption, error) {
+	options := []trips.CSVOption{}
+	start, end := q.Get("startColumn"), q.Get("endColumn")
+	if start != "" || end != "" {
+		if start == "" {
+			start = "start"
+		}
+		if end == "" {
+			end = "end"
+		}
+		options = append(options, trips.WithCSVColumns(start, end))
+	}
+	if c := q.Get("countryColumn"); c != "" {
+		options = append(options, trips.WithCSVCountryColumn(c))
+	}
+	if c := q.Get("typeColumn"); c != "" {
+		options = append(options, trips.WithCSVTypeColumn(c))
+	}
+	if f := q["dateFormat"]; len(f) > 0 {
+		options = append(options, trips.WithCSVDateFormats(f...))
+	}
+	switch d := q.Get("delimiter"); {
+	case d == "":
+	case d == "tab":
+		options = append(options, trips.WithCSVComma('\t'))
+	case utf8.RuneCountInString(d) == 1:
+		r, _ := utf8.DecodeRuneInString(d)
+		options = append(options, trips.WithCSVComma(r))
+	default:
+		return nil, fmt.Errorf("invalid delimiter %q", d)
+	}
+	return options, nil
+}
+
+// mergeFromQuery reports if overlapping trips are to be merged from the
+// "merge" url query or form parameter, or DefaultMerge if it is not set.
+func mergeFromQuery(q url.Values) (bool, error) {
+	m := q.Get("merge")
+	if m == "" {
+		return DefaultMerge, nil
+	}
+	merge, err := strconv.ParseBool(m)
+	if err != nil {
+		return false, fmt.Errorf("invalid merge %q", m)
+	}
+	return merge, nil
+}
+
+// forecastFromQuery returns the number of days after the last trip for
+// which the allowance remaining is forecast from the "forecast" url
+// query or form parameter, or ReportForecast if it is not set. 0 means
+// no forecast.
+func forecastFromQuery(q url.Values) (int, error) {
+	f := q.Get("forecast")
+	if f == "" {
+		return ReportForecast, nil
+	}
+	forecast, err := strconv.Atoi(f)
+	if err != nil || forecast < 0 || forecast > TimelineMaxHorizon {
+		return 0, fmt.Errorf("invalid forecast %q: must be between 0 and %d days", f, TimelineMaxHorizon)
+	}
+	return forecast, nil
+}
+
+// mergeable clears a holiday decoding error reporting only overlapping
+// trips if the trips are to be merged.
+func mergeable(err error, merge bool) error {
+	var invalid trips.ValidationErrors
+	if merge && errors.As(err, &invalid) && invalid.Mergeable() {
+		return nil
+	}
+	return err
+}
+
+// ruleOptions returns the options for calculating with rule, merging
+// overlapping trips if merge is set.
+func ruleOptions(rule trips.Rule, merge bool) []trips.Option {
+	options := []trips.Option{trips.WithRule(rule)}
+	if merge {
+		options = append(options, trips.WithMergeOverlaps())
+	}
+	return options
+}
+
+// calculationRule returns the registered rule with the provided name,
+// or the DefaultRule if name is empty.
+func calculationRule(name string) (trips.Rule, error) {
+	if name == "" {
+		name = DefaultRule
+	}
+	return trips.RuleByName(name)
+}
+
+// jsonErrorSender writes a json error message made from note and err
+// with a bad request status. Trip validation problems are also reported
+// individually in an "Errors" array, identifying the offending trips by
+// their index.
+func jsonErrorSender(w http.ResponseWriter, note string, err error) {
+	jsonStatusErrorSender(w, http.StatusBadRequest, note, err)
+}
+
+// jsonStatusErrorSender writes a json error message as for
+// jsonErrorSender with the provided http status.
+func jsonStatusErrorSender(w http.ResponseWriter, status int, note string, err error) {
+	w.WriteHeader(status)
+	var ve trips.ValidationErrors
+	errors.As(err, &ve)
+	j, _ := json.Marshal(struct {
+		Error  string
+		Errors trips.ValidationErrors `json:",omitempty"`
+	}{
+		Error:  note + " " + err.Error(),
+		Errors: ve,
+	})
+	_, err = w.Write(j)
+	if err != nil {
+		log.Printf("could not write json error %v", err)
+	}
+}
+
+// Plan is a POST endpoint for JSON queries, receiving a json proposed
+// entry date and any holidays already taken or planned, returning the
+// longest permissible stay from the entry date as json. The POSTed json
+// takes the form `{"Entry":"2023-07-01","Holidays":[{"Start":...,"End":...}]}`.
+// The rule may be selected with the "rule" query parameter, and
+// overlapping trips merged with the "merge" query parameter.
+func Plan(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// short cut error returner
+	errSender := func(note string, err error) {
+		jsonErrorSender(w, note, err)
+	}
+
+	if r.Method != "POST" {
+		err := errors.New(r.Method)
+		errSender("endpoint only accepts POST requests, got", err)
+		return
+	}
+
+	rule, err := calculationRule(r.URL.Query().Get("rule"))
+	if err != nil {
+		errSender("rule error:", err)
+		return
+	}
+	merge, err := mergeFromQuery(r.URL.Query())
+	if err != nil {
+		errSender("query error", err)
+		return
+	}
+
+	// read body
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		errSender("body reading error", err)
+		return
+	}
+
+	// extract the entry date and holidays, if any, from POSTed json
+	var input struct {
+		Entry    string
+		Holidays json.RawMessage
+	}
+	err = json.Unmarshal(body, &input)
+	if err != nil {
+		errSender("json decoding error", err)
+		return
+	}
+	entry, err := time.Parse("2006-01-02", input.Entry)
+	if err != nil {
+		errSender("entry date error", err)
+		return
+	}
+	holidays := []trips.Holiday{}
+	if len(input.Holidays) > 0 {
+		holidays, err = holidayJSONDecoder(input.Holidays)
+		if err = mergeable(err, merge); err != nil {
+			errSender("holiday json decoding error", err)
+			return
+		}
+	}
+
+	// plan the stay
+	plan, err := planStay(holidays, entry, ruleOptions(rule, merge)...)
+	if err != nil {
+		errSender("planning error:", err)
+		return
+	}
+
+	jBytes, err := tripsJSONMarshal(plan)
+	if err != nil {
+		errSender("json encoding error:", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(jBytes)
+	if err != nil {
+		log.Printf("could not write plan error %v", err)
+	}
+}
+
+// horizonFromQuery returns the timeline horizon in days from the
+// "horizon" url query parameter, or 0 if it is not set.
+func horizonFromQuery(q url.Values) (int, error) {
+	h := q.Get("horizon")
+	if h == "" {
+		return 0, nil
+	}
+	horizon, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, fmt.Errorf("invalid horizon %q", h)
+	}
+	if horizon < 0 || horizon > TimelineMaxHorizon {
+		return 0, fmt.Errorf("horizon must be between 0 and %d days", TimelineMaxHorizon)
+	}
+	return horizon, nil
+}
+
+// Timeline is a POST endpoint for JSON queries, receiving json or csv
+// dates in the same form as the Trips endpoint and returning json reporting the
+// days used and remaining allowance for each day from the start to the
+// end of the trips, extended by the optional "horizon" query parameter
+// in days. The rule may be selected with the "rule" query parameter, and
+// overlapping trips merged with the "merge" query parameter.
+func Timeline(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// short cut error returner
+	errSender := func(note string, err error) {
+		jsonErrorSender(w, note, err)
+	}
+
+	if r.Method != "POST" {
+		err := errors.New(r.Method)
+		errSender("endpoint only accepts POST requests, got", err)
+		return
+	}
+
+	horizon, err := horizonFromQuery(r.URL.Query())
+	if err != nil {
+		errSender("query error", err)
+		return
+	}
+	rule, err := calculationRule(r.URL.Query().Get("rule"))
+	if err != nil {
+		errSender("rule error:", err)
+		return
+	}
+	merge, err := mergeFromQuery(r.URL.Query())
+	if err != nil {
+		errSender("query error", err)
+		return
+	}
+
+	// read body
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		errSender("body reading error", err)
+		return
+	}
+
+	// extract holidays from POSTed json or csv
+	holidays, err := decodeBody(r, body)
+	if err = mergeable(err, merge); err != nil {
+		errSender(decodingNote(r), err)
+		return
+	}
+	if len(holidays) < 1 {
+		errSender("no holidays were found", errors.New("empty list"))
+		return
+	}
+
+	// perform the calculation
+	trs, err := calculate(holidays, ruleOptions(rule, merge)...)
+	if err != nil {
+		errSender("calculation error:", err)
+		return
+	}
+	timeline, err := trs.Timeline(horizon)
+	if err != nil {
+		errSender("timeline error:", err)
+		return
+	}
+
+	jBytes, err := tripsJSONMarshal(timeline)
+	if err != nil {
+		errSender("json encoding error:", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(jBytes)
+	if err != nil {
+		log.Printf("could not write timeline error %v", err)
+	}
+}
+
+// TimelineCSV is a GET endpoint returning the timeline for the holidays
+// provided as url parameters in the same form as the Home page as a CSV
+// download.
+func TimelineCSV(w http.ResponseWriter, r *http.Request) {
+
+	horizon, err := horizonFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule, err := calculationRule(r.URL.Query().Get("rule"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	merge, err := mergeFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	holidays, err := trips.HolidaysURLDecoder(r.URL.Query())
+	if err = mergeable(err, merge); err != nil {
+		http.Error(w, "holiday decoding error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(holidays) < 1 {
+		http.Error(w, "no holidays were found", http.StatusBadRequest)
+		return
+	}
+
+	trs, err := calculate(holidays, ruleOptions(rule, merge)...)
+	if err != nil {
+		http.Error(w, "calculation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	timeline, err := trs.Timeline(horizon)
+	if err != nil {
+		http.Error(w, "timeline error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// buffer the csv so that errors can still be reported
+	var csv strings.Builder
+	err = trips.TimelineAsCSV(timeline, &csv)
+	if err != nil {
+		http.Error(w, "csv error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="timeline.csv"`)
+	_, err = io.WriteString(w, csv.String())
+	if err != nil {
+		log.Printf("could not write timeline csv error %v", err)
+	}
+}
+
+// TripsICS is a GET endpoint returning the calculation of the holidays
+// provided as url parameters in the same form as the Home page as an
+// iCalendar (.ics) download, with events for each trip and for the days
+// on which the allowance was exhausted and from which it is safe to
+// return.
+func TripsICS(w http.ResponseWriter, r *http.Request) {
+
+	trs, err := queryTrips(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// buffer the calendar so that errors can still be reported
+	var ics strings.Builder
+	err = trips.TripsAsICS(trs, &ics)
+	if err != nil {
+		http.Error(w, "calendar error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="trips.ics"`)
+	_, err = io.WriteString(w, ics.String())
+	if err != nil {
+		log.Printf("could not write trips ics error %v", err)
+	}
+}
+
+// CalendarICS is a GET endpoint returning a calendar feed, for calendar
+// apps to subscribe to, of the holidays provided as url parameters in
+// the same form as the Home page. The feed has events for each trip, for
+// the window with the most days away and for the day from which the full
+// allowance is again available, and is recalculated each time calendar
+// apps refresh it.
+func CalendarICS(w http.ResponseWriter, r *http.Request) {
+
+	trs, err := queryTrips(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// buffer the calendar so that errors can still be reported
+	var ics strings.Builder
+	err = trips.TripsAsICSFeed(trs, &ics, "timeaway trips ("+trs.Rule+")", CalendarRefresh)
+	if err != nil {
+		http.Error(w, "calendar error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(CalendarRefresh.Seconds())))
+	_, err = io.WriteString(w, ics.String())
+	if err != nil {
+		log.Printf("could not write calendar ics error %v", err)
+	}
+}
+
+// queryTrips calculates the holidays provided as url parameters in the
+// same form as the Home page, with the rule and merge parameters.
+func queryTrips(q url.Values) (*trips.Trips, error) {
+	rule, err := calculationRule(q.Get("rule"))
+	if err != nil {
+		return nil, err
+	}
+	merge, err := mergeFromQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	holidays, err := trips.HolidaysURLDecoder(q)
+	if err = mergeable(err, merge); err != nil {
+		return nil, fmt.Errorf("holiday decoding error: %w", err)
+	}
+	if len(holidays) < 1 {
+		return nil, errors.New("no holidays were found")
+	}
+
+	trs, err := calculate(holidays, ruleOptions(rule, merge)...)
+	if err != nil {
+		return nil, fmt.Errorf("calculation error: %w", err)
+	}
+	return trs, nil
+}
+
 // HealthCheck shows if the service is up
 func Health(w http.ResponseWriter, r *http.Request) {
 	enc := json.NewEncoder(w)
@@ -335,7 +957,9 @@ func PartialNoContent(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte(""))
 }
 
-// PartialAddTrip adds a trip button row
+// PartialAddTrip adds a trip button row, for the traveller with the
+// index in the "traveller" query parameter on the team page if set, or
+// the scenario with that index on the compare page.
 func PartialAddTrip(w http.ResponseWriter, r *http.Request) {
 
 	// date about 6 months ago
@@ -351,7 +975,22 @@ func PartialAddTrip(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := struct{ DefaultDate time.Time }{defaultDate}
+	// the trip fields and placeholder of a traveller on the team page
+	// are suffixed by the traveller's index
+	data := struct {
+		DefaultDate time.Time
+		Suffix      string
+		Target      string
+	}{defaultDate, "", "rpl"}
+	if tr := r.URL.Query().Get("traveller"); tr != "" {
+		i, err := strconv.Atoi(tr)
+		if err != nil || i < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Printf("invalid traveller %q", tr)
+			return
+		}
+		data.Suffix, data.Target = travellerSuffix(i), fmt.Sprintf("rpl-%d", i)
+	}
 	err = t.Execute(w, data)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -385,10 +1024,22 @@ func PartialReport(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	merge, err := mergeFromQuery(urlVals)
+	if err != nil {
+		_, _ = w.Write([]byte(err.Error()))
+		log.Print("merge error", err)
+		return
+	}
 	holidays, err := trips.HolidaysURLDecoder(urlVals)
 	if inDevelopment {
 		log.Printf("holidays GET : %+v err : %v", holidays, err)
 	}
+	err = mergeable(err, merge)
+	var invalid trips.ValidationErrors
+	if errors.As(err, &invalid) {
+		invalidWriter(w, invalid)
+		return
+	}
 	if err != nil {
 		_, _ = w.Write([]byte(err.Error()))
 		log.Print("form data decoding error", err)
@@ -402,20 +1053,44 @@ func PartialReport(w http.ResponseWriter, r *http.Request) {
 	if inDevelopment {
 		log.Println("holidays", holidays)
 	}
+	rule, err := calculationRule(urlVals.Get("rule"))
+	if err != nil {
+		_, _ = w.Write([]byte(err.Error()))
+		log.Print("rule error", err)
+		return
+	}
+	forecast, err := forecastFromQuery(urlVals)
+	if err != nil {
+		_, _ = w.Write([]byte(err.Error()))
+		log.Print("forecast error", err)
+		return
+	}
 
 	// perform the calculation
 	var trs *trips.Trips
 
 	// push htmx browser url to client's browser history
-	w.Header().Set("HX-Push-Url", BaseURL+"/?"+trips.HolidaysURLEncode(holidays))
+	query := trips.HolidaysURLEncode(holidays) + "&rule=" + url.QueryEscape(rule.Name())
+	if merge {
+		query += "&merge=true"
+	}
+	homeQuery := query
+	if forecast > 0 {
+		homeQuery += "&forecast=" + strconv.Itoa(forecast)
+	}
+	w.Header().Set("HX-Push-Url", BaseURL+"/?"+homeQuery)
 
 	// error captured in trs.Error
-	trs, _ = calculate(holidays)
+	trs, _ = calculate(holidays, append(ruleOptions(rule, merge), trips.WithRules(trips.Rules()...))...)
 
 	// svg creation
 	var svgPlot strings.Builder
 	if trs.Error == nil {
-		err := svg.TripsAsSVG(trs, &svgPlot)
+		svgOpts := []svg.Option{}
+		if forecast > 0 {
+			svgOpts = append(svgOpts, svg.WithForecast(forecast))
+		}
+		err := svg.TripsAsSVG(trs, &svgPlot, svgOpts...)
 		if err != nil {
 			log.Printf("plotting error: %v", err)
 		}
@@ -425,11 +1100,228 @@ func PartialReport(w http.ResponseWriter, r *http.Request) {
 	// build output. The Plot output is verbatim svg that should not be
 	// escaped.
 	output := struct {
-		Trips *trips.Trips
-		Plot  template.HTML
-	}{trs, template.HTML(plot)}
+		Trips       *trips.Trips
+		Plot        template.HTML
+		Query       template.URL
+		Invalid     trips.ValidationErrors
+		Rows        []int
+		Suggestions []suggestionLink
+	}{Trips: trs, Plot: template.HTML(plot), Query: template.URL(query)}
+	if trs.Error == nil && trs.Breach {
+		output.Suggestions = suggestionLinks(trs, rule, merge, forecast)
+	}
+
+	t := template.Must(template.ParseFS(DirFS.TplFS, "partial-report.html"))
+	err = t.Execute(w, output)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "template writing problem : %s", err.Error())
+	}
+}
+
+// suggestionLink is a suggested change to trips in breach of their
+// rule, with the home page query of the changed trips.
+type suggestionLink struct {
+	Text  string
+	Query template.URL
+}
+
+// suggestionLinks returns links to the home page with the trips changed
+// as suggested by trips.Suggest, at most SuggestionsShown of them, so
+// that a suggestion can be tried with a single click.
+func suggestionLinks(trs *trips.Trips, rule trips.Rule, merge bool, forecast int) []suggestionLink {
+	suggestions, err := trs.Suggest()
+	if err != nil {
+		log.Printf("suggestion error: %v", err)
+		return nil
+	}
+	links := []suggestionLink{}
+	for _, s := range suggestions[:min(len(suggestions), SuggestionsShown)] {
+		query := trips.HolidaysURLEncode(s.Holidays) + "&rule=" + url.QueryEscape(rule.Name())
+		if merge {
+			query += "&merge=true"
+		}
+		if forecast > 0 {
+			query += "&forecast=" + strconv.Itoa(forecast)
+		}
+		links = append(links, suggestionLink{s.String(), template.URL(query)})
+	}
+	return links
+}
+
+// PartialUpload loads the trips in a csv or iCalendar (.ics) file
+// uploaded from the home page into the home page form by redirecting to
+// the home page with the trips as url parameters. Trips with validation
+// problems are loaded to be corrected in the form; other problems are
+// reported in html.
+func PartialUpload(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Print("endpoint only accepts POST requests, got", r.Method)
+		return
+	}
+
+	// uploadError reports a problem with the upload
+	uploadError := func(err error) {
+		log.Print("upload error", err)
+		output := struct {
+			Trips   *trips.Trips
+			Invalid trips.ValidationErrors
+			Rows    []int
+		}{Trips: &trips.Trips{Error: err}}
+		t := template.Must(template.ParseFS(DirFS.TplFS, "partial-report.html"))
+		if err := t.Execute(w, output); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "template writing problem : %s", err.Error())
+		}
+	}
+
+	err := r.ParseMultipartForm(BodyLimitSize)
+	if err != nil {
+		uploadError(fmt.Errorf("upload error: %w", err))
+		return
+	}
+	f, header, err := r.FormFile("file")
+	if err != nil {
+		uploadError(errors.New("please choose a csv or ics file to upload"))
+		return
+	}
+	defer f.Close()
+	body, err := io.ReadAll(f)
+	if err != nil {
+		uploadError(fmt.Errorf("upload reading error: %w", err))
+		return
+	}
+
+	// iCalendar files are recognised by their extension or content
+	mediaType := "text/csv"
+	if strings.EqualFold(path.Ext(header.Filename), ".ics") || bytes.HasPrefix(body, []byte("BEGIN:VCALENDAR")) {
+		mediaType = "text/calendar"
+	}
+	holidays, err := decodeMediaType(mediaType, r.Form, body)
+	var invalid trips.ValidationErrors
+	if err != nil && !errors.As(err, &invalid) {
+		uploadError(err)
+		return
+	}
+
+	// keep the rule, overlap handling and forecast chosen on the home
+	// page, as does PartialReport
+	rule, err := calculationRule(r.Form.Get("rule"))
+	if err != nil {
+		uploadError(err)
+		return
+	}
+	merge, err := mergeFromQuery(r.Form)
+	if err != nil {
+		uploadError(err)
+		return
+	}
+	forecast, err := forecastFromQuery(r.Form)
+	if err != nil {
+		uploadError(err)
+		return
+	}
+	query := trips.HolidaysURLEncode(holidays) + "&rule=" + url.QueryEscape(rule.Name())
+	if merge {
+		query += "&merge=true"
+	}
+	if forecast > 0 {
+		query += "&forecast=" + strconv.Itoa(forecast)
+	}
+	w.Header().Set("HX-Redirect", BaseURL+"/?"+query)
+	w.WriteHeader(http.StatusOK)
+}
+
+// invalidRows returns the positions of the trip rows in the home page
+// form, counting from 1, which have the problems reported in invalid.
+func invalidRows(invalid trips.ValidationErrors) []int {
+	rows := []int{}
+	for _, i := range invalid.Indices() {
+		rows = append(rows, i+1)
+	}
+	return rows
+}
+
+// invalidWriter reports every problem with the trips submitted with the
+// home page form, highlighting the offending trip rows.
+func invalidWriter(w http.ResponseWriter, invalid trips.ValidationErrors) {
+	output := struct {
+		Trips   *trips.Trips
+		Invalid trips.ValidationErrors
+		Rows    []int
+	}{&trips.Trips{Error: invalid}, invalid, invalidRows(invalid)}
 
 	t := template.Must(template.ParseFS(DirFS.TplFS, "partial-report.html"))
+	err := t.Execute(w, output)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "template writing problem : %s", err.Error())
+	}
+}
+
+// PartialPlan shows the longest permissible stay from the proposed
+// entry date submitted with the trips form in html
+func PartialPlan(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Print("endpoint only accepts POST requests, got", r.Method)
+		return
+	}
+
+	// read body
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Print("body reading error", err)
+		return
+	}
+
+	// extract Holidays and the entry date from POSTed htmx form; the
+	// holidays may be empty
+	urlVals, err := url.ParseQuery(string(body))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Print("form parsing error", err)
+		return
+	}
+
+	output := struct {
+		Plan  *trips.Plan
+		Rule  trips.Rule
+		Error error
+		Rows  []int
+	}{}
+
+	merge, err := mergeFromQuery(urlVals)
+	if err != nil {
+		output.Error = err
+	}
+	holidays, err := trips.HolidaysURLDecoder(urlVals)
+	if err = mergeable(err, merge); err != nil && output.Error == nil {
+		output.Error = err
+	}
+	entry, err := time.Parse("2006-01-02", urlVals.Get("Entry"))
+	if err != nil && output.Error == nil {
+		output.Error = errors.New("please provide a valid arrival date")
+	}
+	rule, err := calculationRule(urlVals.Get("rule"))
+	if err != nil && output.Error == nil {
+		output.Error = err
+	}
+	output.Rule = rule
+	if output.Error == nil {
+		output.Plan, output.Error = planStay(holidays, entry, ruleOptions(rule, merge)...)
+	}
+	var invalid trips.ValidationErrors
+	if errors.As(output.Error, &invalid) {
+		output.Rows = invalidRows(invalid)
+	}
+
+	t := template.Must(template.ParseFS(DirFS.TplFS, "partial-plan.html"))
 	err = t.Execute(w, output)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -4,12 +4,16 @@ package web
 // https://bignerdranch.com/blog/using-the-httptest-package-in-golang/
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -24,14 +28,14 @@ func TestHome(t *testing.T) {
 	DirFS = &fileSystem{}
 	DirFS.TplFS = os.DirFS("templates")
 
-	r := httptest.NewRequest(http.MethodGet, "http://example.com/home", nil)
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/home?Start=2023-01-01&End=2023-01-10&Country=IE&forecast=120", nil)
 	w := httptest.NewRecorder()
 
 	Home(w, r)
 
 	res := w.Result()
 	defer res.Body.Close()
-	_, err := io.ReadAll(res.Body)
+	body, err := io.ReadAll(res.Body)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -39,6 +43,12 @@ func TestHome(t *testing.T) {
 	if want, got := 200, res.StatusCode; want != got {
 		t.Errorf("expected status %d, got %d", want, got)
 	}
+	if want := `<option value="IE" selected>Ireland</option>`; !strings.Contains(string(body), want) {
+		t.Errorf("body does not contain %q", want)
+	}
+	if want := `<option value="120" selected>`; !strings.Contains(string(body), want) {
+		t.Errorf("body does not contain %q", want)
+	}
 }
 
 // Test Health page returns a 200
@@ -108,7 +118,7 @@ func TestTripsEndpoint(t *testing.T) {
 	}
 
 	// calculate is the main method for calculations
-	calculate = func([]trips.Holiday) (*trips.Trips, error) {
+	calculate = func([]trips.Holiday, ...trips.Option) (*trips.Trips, error) {
 		hs := trips.Trips{}
 		return &hs, nil
 	}
@@ -122,6 +132,7 @@ func TestTripsEndpoint(t *testing.T) {
 	tt := []struct {
 		name       string
 		method     string
+		query      string
 		input      string // json
 		statusCode int
 	}{
@@ -131,6 +142,20 @@ func TestTripsEndpoint(t *testing.T) {
 			input:      `[{"Start":"2022-12-01","End":"2022-12-02"}]`,
 			statusCode: http.StatusOK,
 		},
+		{
+			name:       "succeed post with rule",
+			method:     http.MethodPost,
+			query:      "?rule=uk-tax-year",
+			input:      `[{"Start":"2022-12-01","End":"2022-12-02"}]`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "fail unknown rule",
+			method:     http.MethodPost,
+			query:      "?rule=unknown",
+			input:      `[{"Start":"2022-12-01","End":"2022-12-02"}]`,
+			statusCode: http.StatusBadRequest,
+		},
 		{
 			name:       "fail no POST body",
 			method:     http.MethodPost,
@@ -149,7 +174,7 @@ func TestTripsEndpoint(t *testing.T) {
 		t.Logf("%+v\n", tc)
 		t.Run(tc.name, func(t *testing.T) {
 
-			r := httptest.NewRequest(tc.method, "http://example.com/trips", strings.NewReader(tc.input))
+			r := httptest.NewRequest(tc.method, "http://example.com/trips"+tc.query, strings.NewReader(tc.input))
 			w := httptest.NewRecorder()
 
 			Trips(w, r)
@@ -169,6 +194,402 @@ func TestTripsEndpoint(t *testing.T) {
 	}
 }
 
+// TestTripsValidationErrors tests that every trip validation problem is
+// reported in the json error response.
+func TestTripsValidationErrors(t *testing.T) {
+
+	holidayJSONDecoder = trips.HolidaysJSONDecoder
+
+	input := `[{"Start":"2023-01-01","End":"2023-01-10"},{"Start":"2023-01-05","End":"2023-01-12"},{"Start":"2023-03-10"}]`
+	r := httptest.NewRequest(http.MethodPost, "http://example.com/trips", strings.NewReader(input))
+	w := httptest.NewRecorder()
+	Trips(w, r)
+
+	res := w.Result()
+	defer res.Body.Close()
+	if got, want := res.StatusCode, http.StatusBadRequest; got != want {
+		t.Errorf("status got %d want %d", got, want)
+	}
+	resp := struct {
+		Error  string
+		Errors []struct {
+			Kind    string `json:"kind"`
+			Indices []int  `json:"indices"`
+			Message string `json:"message"`
+		}
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(resp.Errors), 2; got != want {
+		t.Fatalf("errors got %d want %d: %+v", got, want, resp)
+	}
+	if got, want := resp.Errors[0].Kind, "overlap"; got != want {
+		t.Errorf("first kind got %s want %s", got, want)
+	}
+	if got, want := resp.Errors[1].Indices, []int{2}; !slices.Equal(got, want) {
+		t.Errorf("second indices got %v want %v", got, want)
+	}
+	if got, want := resp.Errors[1].Message, "trip 3: end date not set"; got != want {
+		t.Errorf("second message got %q want %q", got, want)
+	}
+
+	// overlapping trips may be merged
+	calculate = trips.Calculate
+	tripsJSONMarshal = json.Marshal
+	input = `[{"Start":"2023-01-01","End":"2023-01-10"},{"Start":"2023-01-05","End":"2023-01-12"}]`
+	r = httptest.NewRequest(http.MethodPost, "http://example.com/trips?merge=true", strings.NewReader(input))
+	w = httptest.NewRecorder()
+	Trips(w, r)
+	res = w.Result()
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := res.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("merge status got %d want %d: %s", got, want, body)
+	}
+	if want := `"indices":[0,1]`; !strings.Contains(string(body), want) {
+		t.Errorf("merge body does not contain %s: %s", want, body)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "http://example.com/trips?merge=maybe", strings.NewReader(input))
+	w = httptest.NewRecorder()
+	Trips(w, r)
+	if got, want := w.Result().StatusCode, http.StatusBadRequest; got != want {
+		t.Errorf("invalid merge status got %d want %d", got, want)
+	}
+}
+
+// TestTripsCSV tests POSTing trips as csv or iCalendar to the trips
+// endpoint
+func TestTripsCSV(t *testing.T) {
+
+	holidayJSONDecoder = trips.HolidaysJSONDecoder
+	holidayCSVDecoder = trips.HolidaysCSVDecoder
+	holidayICSDecoder = trips.HolidaysICSDecoder
+	calculate = trips.Calculate
+	tripsJSONMarshal = json.Marshal
+
+	tt := []struct {
+		name        string
+		query       string
+		contentType string
+		input       string
+		statusCode  int
+		want        string
+	}{
+		{
+			name:        "csv",
+			contentType: "text/csv",
+			input:       "start,end,country\n2023-01-01,2023-01-10,FR\n",
+			statusCode:  http.StatusOK,
+			want:        `"country":"FR","duration":10`,
+		},
+		{
+			name:        "configured csv",
+			query:       "?startColumn=Departed&endColumn=Returned&dateFormat=01/02/2006&delimiter=tab",
+			contentType: "text/csv; charset=utf-8",
+			input:       "Departed\tReturned\n01/31/2023\t02/02/2023\n",
+			statusCode:  http.StatusOK,
+			want:        `"daysAway":3`,
+		},
+		{
+			name:        "bad delimiter",
+			query:       "?delimiter=ab",
+			contentType: "text/csv",
+			input:       "start,end\n2023-01-01,2023-01-10\n",
+			statusCode:  http.StatusBadRequest,
+			want:        `form csv decoding error invalid delimiter`,
+		},
+		{
+			name:        "csv sent as json",
+			contentType: "application/json",
+			input:       "start,end\n2023-01-01,2023-01-10\n",
+			statusCode:  http.StatusBadRequest,
+		},
+		{
+			name:        "invalid csv trips",
+			contentType: "text/csv",
+			input:       "start,end\n2023-01-10,2023-01-01\n",
+			statusCode:  http.StatusBadRequest,
+			want:        `"kind":"reversed dates"`,
+		},
+		{
+			name:        "ics",
+			contentType: "text/calendar",
+			input:       "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20230101\r\nDTEND;VALUE=DATE:20230111\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			statusCode:  http.StatusOK,
+			want:        `"daysAway":10`,
+		},
+		{
+			name:        "ics inclusive end",
+			query:       "?inclusiveEnd=true",
+			contentType: "text/calendar",
+			input:       "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20230101\r\nDTEND;VALUE=DATE:20230111\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			statusCode:  http.StatusOK,
+			want:        `"daysAway":11`,
+		},
+		{
+			name:        "ics bad inclusive end",
+			query:       "?inclusiveEnd=maybe",
+			contentType: "text/calendar",
+			input:       "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n",
+			statusCode:  http.StatusBadRequest,
+			want:        `form ics decoding error invalid inclusiveEnd`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://example.com/trips"+tc.query, strings.NewReader(tc.input))
+			r.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+			Trips(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := res.StatusCode, tc.statusCode; got != want {
+				t.Errorf("status got %d want %d (%s)", got, want, body)
+			}
+			if !strings.Contains(string(body), tc.want) {
+				t.Errorf("body does not contain %s: %s", tc.want, body)
+			}
+		})
+	}
+}
+
+// TestPartialUpload tests loading trips from an uploaded csv file into
+// the home page
+func TestPartialUpload(t *testing.T) {
+
+	DirFS = &fileSystem{}
+	DirFS.TplFS = os.DirFS("templates")
+	holidayCSVDecoder = trips.HolidaysCSVDecoder
+	holidayICSDecoder = trips.HolidaysICSDecoder
+
+	upload := func(field, filename, content string, fields map[string]string) *http.Request {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for k, v := range fields {
+			if err := mw.WriteField(k, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		fw, err := mw.CreateFormFile(field, filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.WriteString(fw, content)
+		if err := mw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest(http.MethodPost, "http://example.com/partials/upload", &buf)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		return r
+	}
+
+	tt := []struct {
+		name     string
+		field    string
+		filename string
+		input    string
+		fields   map[string]string
+		redirect string
+		want     string
+	}{
+		{
+			name:     "upload",
+			field:    "file",
+			filename: "trips.csv",
+			input:    "start,end,country\n2023-01-01,2023-01-10,FR\n2023-01-05,2023-01-06,\n",
+			redirect: "/?Start=2023-01-01&End=2023-01-10&Country=FR&Start=2023-01-05&End=2023-01-06&Country=&rule=schengen",
+		},
+		{
+			name:     "upload with settings",
+			field:    "file",
+			filename: "trips.csv",
+			input:    "start,end\n2023-01-01,2023-01-10\n2023-01-10,2023-01-12\n",
+			fields:   map[string]string{"rule": "uk-tax-year", "merge": "true", "forecast": "90"},
+			redirect: "/?Start=2023-01-01&End=2023-01-10&Start=2023-01-10&End=2023-01-12&rule=uk-tax-year&merge=true&forecast=90",
+		},
+		{
+			name:     "upload with unknown rule",
+			field:    "file",
+			filename: "trips.csv",
+			input:    "start,end\n2023-01-01,2023-01-10\n",
+			fields:   map[string]string{"rule": "unknown"},
+			want:     `rule &#34;unknown&#34; not known`,
+		},
+		{
+			name:     "bad csv",
+			field:    "file",
+			filename: "trips.csv",
+			input:    "from,to\n2023-01-01,2023-01-10\n",
+			want:     `csv start column &#34;start&#34; not found`,
+		},
+		{
+			name:     "ics",
+			field:    "file",
+			filename: "calendar.ICS",
+			input:    "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20230101\r\nDTEND;VALUE=DATE:20230111\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			redirect: "/?Start=2023-01-01&End=2023-01-10&rule=schengen",
+		},
+		{
+			name:     "ics without extension",
+			field:    "file",
+			filename: "export",
+			input:    "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTEND;VALUE=DATE:20230111\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			want:     "event 1: no DTSTART",
+		},
+		{
+			name:     "no file",
+			field:    "other",
+			filename: "trips.csv",
+			input:    "start,end\n2023-01-01,2023-01-10\n",
+			want:     "please choose a csv or ics file to upload",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			PartialUpload(w, upload(tc.field, tc.filename, tc.input, tc.fields))
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := res.Header.Get("HX-Redirect"), tc.redirect; got != want {
+				t.Errorf("redirect got %q want %q", got, want)
+			}
+			if !strings.Contains(string(body), tc.want) {
+				t.Errorf("body does not contain %q:\n%s", tc.want, body)
+			}
+		})
+	}
+}
+
+// TestPlanEndpoint tests the JSON stay planning endpoint; note that the
+// main webserver package level func vars are swapped out.
+func TestPlanEndpoint(t *testing.T) {
+
+	holidayJSONDecoder = trips.HolidaysJSONDecoder
+	tripsJSONMarshal = json.Marshal
+
+	// planStay returns a fixed plan
+	planStay = func(hols []trips.Holiday, entry time.Time, _ ...trips.Option) (*trips.Plan, error) {
+		return &trips.Plan{Entry: entry, Exit: entry.Add(24 * time.Hour), Days: 2}, nil
+	}
+
+	tt := []struct {
+		name       string
+		method     string
+		input      string // json
+		statusCode int
+	}{
+		{
+			name:       "succeed post",
+			method:     http.MethodPost,
+			input:      `{"Entry":"2023-01-01","Holidays":[{"Start":"2022-12-01","End":"2022-12-02"}]}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "succeed post without holidays",
+			method:     http.MethodPost,
+			input:      `{"Entry":"2023-01-01"}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "fail no entry date",
+			method:     http.MethodPost,
+			input:      `{"Holidays":[{"Start":"2022-12-01","End":"2022-12-02"}]}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "fail bad holidays",
+			method:     http.MethodPost,
+			input:      `{"Entry":"2023-01-01","Holidays":[{"Start":"2022-12-03","End":"2022-12-02"}]}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "fail due to GET",
+			method:     http.MethodGet,
+			input:      ``,
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(tc.method, "http://example.com/plan", strings.NewReader(tc.input))
+			w := httptest.NewRecorder()
+
+			Plan(w, r)
+
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.statusCode != res.StatusCode {
+				t.Errorf("expected status %d, got %d (%s)", tc.statusCode, res.StatusCode, string(body))
+			}
+			if res.StatusCode == http.StatusOK && !strings.Contains(string(body), `"days":2`) {
+				t.Errorf("unexpected plan body %s", string(body))
+			}
+		})
+	}
+}
+
+// TestPartialPlan tests the htmx stay planner partial
+func TestPartialPlan(t *testing.T) {
+
+	DirFS = &fileSystem{}
+	DirFS.TplFS = os.DirFS("templates")
+	planStay = trips.PlanStay
+
+	tt := []struct {
+		name  string
+		input string // form body
+		want  string
+	}{
+		{"plan", "Start=2023-01-01&End=2023-03-31&Entry=2023-06-30", "a stay of <b>90</b> days"},
+		{"rule description", "Start=2023-01-01&End=2023-03-31&Entry=2023-06-30", "breaching the 90 days in any 180 day period rule"},
+		{"calendar year rule", "Start=2023-01-01&End=2023-03-31&Entry=2023-06-30&rule=calendar-year", "<b>93</b> days, without breaching the 183 days in a year starting 1 January rule"},
+		{"no trips", "Entry=2023-06-30", "a stay of <b>90</b> days"},
+		{"no entry", "Start=2023-01-01&End=2023-03-31", "valid arrival date"},
+		{"exhausted", "Start=2023-01-01&End=2023-03-31&Entry=2023-04-01", "no stay is permissible"},
+		{"overlap", "Start=2023-01-01&End=2023-03-31&Start=2023-03-01&End=2023-03-02&Entry=2023-06-30", "p:nth-of-type(2) { background-color"},
+		{"merged", "Start=2023-01-01&End=2023-03-31&Start=2023-03-01&End=2023-03-02&Entry=2023-06-30&merge=true", "a stay of <b>90</b> days"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://example.com/partials/plan", strings.NewReader(tc.input))
+			w := httptest.NewRecorder()
+			PartialPlan(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(body), tc.want) {
+				t.Errorf("body does not contain %q:\n%s", tc.want, string(body))
+			}
+		})
+	}
+}
+
 // TestPartialEndpoints tests the partials used for htmx partial
 // rendering
 func TestPartialEndpoints(t *testing.T) {
@@ -190,6 +611,8 @@ func TestPartialEndpoints(t *testing.T) {
 		{"PartialNoContent", http.MethodGet, PartialNoContent, "/partials/nocontent", 200},
 		{"PartialAddTrip", http.MethodGet, PartialAddTrip, "/partials/addtrip", 200},
 		{"PartialReport", http.MethodGet, PartialReport, "/partials/report", http.StatusBadRequest},
+		{"PartialPlan", http.MethodGet, PartialPlan, "/partials/plan", http.StatusBadRequest},
+		{"PartialUpload", http.MethodGet, PartialUpload, "/partials/upload", http.StatusBadRequest},
 	}
 
 	for _, tc := range testCases {
@@ -204,3 +627,260 @@ func TestPartialEndpoints(t *testing.T) {
 		})
 	}
 }
+
+// TestPartialReport tests the htmx report partial
+func TestPartialReport(t *testing.T) {
+
+	DirFS = &fileSystem{}
+	DirFS.TplFS = os.DirFS("templates")
+	calculate = trips.Calculate
+
+	tt := []struct {
+		name  string
+		input string // form body
+		want  []string
+	}{
+		{
+			name:  "no breach",
+			input: "Start=2023-01-01&End=2023-01-10",
+			want: []string{
+				"do <b>not</b> breach the 90 days in any 180 day period rule",
+				`href="./timeline.csv?Start=2023-01-01&amp;End=2023-01-10&amp;rule=schengen&amp;horizon=180"`,
+				"<td>uk-tax-year</td>",
+				"<td>consecutive-90</td>",
+			},
+		},
+		{
+			name:  "tax year rule",
+			input: "Start=2023-01-01&End=2023-01-10&rule=uk-tax-year",
+			want: []string{
+				"do <b>not</b> breach the 183 days in a year starting 6 April rule",
+				`rule=uk-tax-year&amp;horizon=180`,
+			},
+		},
+		{
+			name:  "excluded country",
+			input: "Start=2023-01-01&End=2023-01-10&Country=IE&Start=2023-02-01&End=2023-02-02&Country=FR",
+			want: []string{
+				"Some days away were not counted towards the schengen rule",
+				"(10 days) as Ireland is not a Schengen member state.",
+				"Wednesday 01/02/2023 to Thursday 02/02/2023 in FR (2 days)",
+				"Country=IE&amp;Start=2023-02-01",
+			},
+		},
+		{
+			name:  "breach suggestions",
+			input: "Start=2023-01-01&End=2023-03-21&Start=2023-05-01&End=2023-05-15",
+			want: []string{
+				"The trips would not breach the rule if you were to:",
+				`<li><a href="./?Start=2023-01-01&amp;End=2023-03-21&amp;Start=2023-05-01&amp;End=2023-05-10&amp;rule=schengen">shorten the trip 01/05/2023 to 15/05/2023 by 5 days to end on 10/05/2023</a></li>`,
+				`<li><a href="./?Start=2023-01-01&amp;End=2023-03-21&amp;rule=schengen">drop the trip 01/05/2023 to 15/05/2023 (15 days)</a></li>`,
+				"move the trip 01/05/2023 to 15/05/2023 50 days later to 20/06/2023 to 04/07/2023",
+			},
+		},
+		{
+			name: "exempt period",
+			input: "Start=2023-01-01&End=2023-01-10&Type=trip&" +
+				"Start=2023-01-05&End=2023-02-28&Type=exempt&forecast=180",
+			want: []string{
+				"The exempt periods in this calculation are:",
+				"Thursday 05/01/2023 to Tuesday 28/02/2023 (55 days)",
+				"(6 days) as covered by a residence permit or long-stay visa.",
+				"<title>exempt: 2023-01-05 to 2023-01-10</title>",
+				"<title>forecast (partial, 86 to 89 days remaining) : 2023-01-11 to 2023-07-02</title>",
+			},
+		},
+		{
+			name: "invalid trips",
+			input: "Start=2023-01-01&End=2023-01-10&Start=2023-01-05&End=2023-01-12&" +
+				"Start=2023-03-10&End=2023-03-01",
+			want: []string{
+				"The trips could not be calculated as:",
+				"trip 2 05/01/2023 to 12/01/2023 overlaps with trip 1 01/01/2023 to 10/01/2023",
+				"trip 3: start date 10/03/2023 after 01/03/2023",
+				"p:nth-of-type(1) { background-color",
+				"p:nth-of-type(3) { background-color",
+			},
+		},
+		{
+			name: "merged trips",
+			input: "Start=2023-01-01&End=2023-01-10&Country=FR&Start=2023-01-10&End=2023-01-12&Country=ES&" +
+				"merge=true",
+			want: []string{
+				"Some trips overlapped and were merged:",
+				"Sunday 01/01/2023 to Thursday 12/01/2023\n    (12 days) from the\n    01/01/2023 to 10/01/2023, 10/01/2023 to 12/01/2023 trips.",
+				"only <b>12</b> days away",
+				"Country=ES&amp;rule=schengen&amp;merge=true&amp;horizon=180",
+			},
+		},
+		{
+			name:  "only an exempt period",
+			input: "Start=2023-01-01&End=2023-01-10&Type=exempt",
+			want: []string{
+				"An error occurred:<br />\nno trips were provided to calculate, only exempt periods</p>",
+			},
+		},
+		{
+			name:  "invalid forecast",
+			input: "Start=2023-01-01&End=2023-01-10&forecast=-1",
+			want: []string{
+				`invalid forecast "-1"`,
+			},
+		},
+		{
+			name:  "unknown rule",
+			input: "Start=2023-01-01&End=2023-01-10&rule=unknown",
+			want: []string{
+				`rule "unknown" not known`,
+			},
+		},
+		{
+			name: "two breaches",
+			input: "Start=2023-01-01&End=2023-03-31&Start=2023-04-10&End=2023-04-12&" +
+				"Start=2023-10-01&End=2023-12-29&Start=2024-01-05&End=2024-01-06",
+			want: []string{
+				"in breach 2 times",
+				"<tr class=\"breached\">\n    <td>schengen</td>",
+				"Monday 10/04/2023 to Wednesday 12/04/2023",
+				"Friday 05/01/2024 to Saturday 06/01/2024",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://example.com/partials/report", strings.NewReader(tc.input))
+			w := httptest.NewRecorder()
+			PartialReport(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("body does not contain %q:\n%s", want, string(body))
+				}
+			}
+		})
+	}
+}
+
+// TestTimelineEndpoints tests the JSON and CSV timeline endpoints, and
+// the iCalendar trips and calendar feed endpoints
+func TestTimelineEndpoints(t *testing.T) {
+
+	holidayJSONDecoder = trips.HolidaysJSONDecoder
+	calculate = trips.Calculate
+	tripsJSONMarshal = json.Marshal
+
+	tt := []struct {
+		name        string
+		method      string
+		url         string
+		input       string
+		fn          func(w http.ResponseWriter, r *http.Request)
+		statusCode  int
+		contentType string
+		want        string
+	}{
+		{
+			name:        "json",
+			method:      http.MethodPost,
+			url:         "http://example.com/timeline?horizon=2",
+			input:       `[{"Start":"2023-01-01","End":"2023-01-02"}]`,
+			fn:          Timeline,
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			want:        `{"date":"2023-01-04T00:00:00Z","away":false,"daysUsed":2,"daysRemaining":88}]`,
+		},
+		{
+			name:        "json bad horizon",
+			method:      http.MethodPost,
+			url:         "http://example.com/timeline?horizon=-1",
+			input:       `[{"Start":"2023-01-01","End":"2023-01-02"}]`,
+			fn:          Timeline,
+			statusCode:  http.StatusBadRequest,
+			contentType: "application/json",
+			want:        "horizon must be between",
+		},
+		{
+			name:        "csv",
+			method:      http.MethodGet,
+			url:         "http://example.com/timeline.csv?Start=2023-01-01&End=2023-01-02&horizon=1",
+			fn:          TimelineCSV,
+			statusCode:  http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			want:        "date,away,days_used,days_remaining\n2023-01-01,true,1,89\n2023-01-02,true,2,88\n2023-01-03,false,2,88\n",
+		},
+		{
+			name:        "csv no holidays",
+			method:      http.MethodGet,
+			url:         "http://example.com/timeline.csv",
+			fn:          TimelineCSV,
+			statusCode:  http.StatusBadRequest,
+			contentType: "text/plain; charset=utf-8",
+			want:        "no holidays were found",
+		},
+		{
+			name:        "ics",
+			method:      http.MethodGet,
+			url:         "http://example.com/trips.ics?Start=2023-01-01&End=2023-01-02&Country=FR",
+			fn:          TripsICS,
+			statusCode:  http.StatusOK,
+			contentType: "text/calendar; charset=utf-8",
+			want:        "DTSTART;VALUE=DATE:20230101\r\nDTEND;VALUE=DATE:20230103\r\nSUMMARY:Trip (FR)\r\n",
+		},
+		{
+			name:        "ics bad rule",
+			method:      http.MethodGet,
+			url:         "http://example.com/trips.ics?Start=2023-01-01&End=2023-01-02&rule=none",
+			fn:          TripsICS,
+			statusCode:  http.StatusBadRequest,
+			contentType: "text/plain; charset=utf-8",
+			want:        "rule",
+		},
+		{
+			name:        "calendar feed",
+			method:      http.MethodGet,
+			url:         "http://example.com/calendar.ics?Start=2023-01-01&End=2023-01-02",
+			fn:          CalendarICS,
+			statusCode:  http.StatusOK,
+			contentType: "text/calendar; charset=utf-8",
+			want:        "SUMMARY:Allowance reset\r\n",
+		},
+		{
+			name:        "calendar feed no holidays",
+			method:      http.MethodGet,
+			url:         "http://example.com/calendar.ics",
+			fn:          CalendarICS,
+			statusCode:  http.StatusBadRequest,
+			contentType: "text/plain; charset=utf-8",
+			want:        "no holidays were found",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.input))
+			w := httptest.NewRecorder()
+			tc.fn(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := res.StatusCode, tc.statusCode; got != want {
+				t.Errorf("status got %d want %d", got, want)
+			}
+			if got, want := res.Header.Get("Content-Type"), tc.contentType; got != want {
+				t.Errorf("content type got %s want %s", got, want)
+			}
+			if !strings.Contains(string(body), tc.want) {
+				t.Errorf("body does not contain %q:\n%s", tc.want, string(body))
+			}
+		})
+	}
+}